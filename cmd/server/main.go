@@ -10,6 +10,7 @@ import (
 	"github.com/mercan/ecommerce/internal/helpers"
 	"github.com/mercan/ecommerce/internal/repositories/rabbitmq"
 	"github.com/mercan/ecommerce/internal/routes"
+	"github.com/mercan/ecommerce/internal/services"
 )
 
 // main is the entry point of the application
@@ -23,6 +24,9 @@ func main() {
 		JSONDecoder:   json.Unmarshal,
 	})
 
+	// Load the JWT keyring at startup so that key errors surface before serving requests
+	helpers.GetJWTKeyring()
+
 	// Use recover and logger middlewares
 	app.Use(recoverMiddleware.New())
 	app.Use(logger.New(helpers.LoggerConfig()))
@@ -30,15 +34,42 @@ func main() {
 	// Defer closing the RabbitMQ channel when the main function ends
 	defer rabbitmq.Close()
 
-	// Setup RabbitMQ Consumers for email and phone verification queues
+	// Setup RabbitMQ Consumers for email and phone queues
 	emailQueue := rabbitmq.NewEmailQueueManager()
 	go emailQueue.ConsumeEmailVerificationQueue()
+	go emailQueue.ConsumeForgotPasswordQueue()
+	go emailQueue.ConsumeSecurityNotificationQueue()
+	go emailQueue.ConsumeMagicLinkQueue()
 	phoneQueue := rabbitmq.NewPhoneQueueManager()
 	go phoneQueue.ConsumePhoneVerificationQueue()
+	go phoneQueue.ConsumeLoginOTPQueue()
+	accountQueue := rabbitmq.NewAccountQueueManager()
+	go accountQueue.ConsumeDataExportQueue()
+
+	// Hard delete accounts whose deletion grace period is over
+	go services.NewAccountService().RunDeletionScheduler()
 
 	// Setup User Routes
 	routes.SetupUserRoutes(app)
 
+	// Setup Account Routes
+	routes.SetupAccountRoutes(app)
+
+	// Setup Admin Routes
+	routes.SetupAdminRoutes(app)
+
+	// Setup Product Routes
+	routes.SetupProductRoutes(app)
+
+	// Setup Category Routes
+	routes.SetupCategoryRoutes(app)
+
+	// Setup Media Routes
+	routes.SetupMediaRoutes(app)
+
+	// Setup Well-Known Routes
+	routes.SetupWellKnownRoutes(app)
+
 	// Listen on the configured server port
 	if err := app.Listen(":" + config.GetServerConfig().Port); err != nil {
 		panic(err)
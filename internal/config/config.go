@@ -1,7 +1,11 @@
 package config
 
 import (
+	"errors"
 	"github.com/spf13/viper"
+	"io/fs"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -15,12 +19,22 @@ type Config struct {
 	Twilio     TwilioConfig
 	Sendgrid   SendgridConfig
 	Time       TimeConfig
+	Lockout    LockoutConfig
+	OAuth      OAuthConfig
+	Password   PasswordConfig
+	Challenge  ChallengeConfig
+	WebAuthn   WebAuthnConfig
+	Search     SearchConfig
+	Media      MediaConfig
 }
 
+// Environment has no default, development conveniences like a throwaway JWT signing key are only
+// enabled when it is explicitly set to "development".
 type ServerConfig struct {
 	AppName     string
 	Environment string
 	Port        string
+	BaseURL     string
 }
 
 type CloudinaryConfig struct {
@@ -39,10 +53,14 @@ type MongoDBConfig struct {
 }
 
 type MongoDBCollectionConfig struct {
-	Users    string
-	Products string
-	Orders   string
-	Payments string
+	Users       string
+	Products    string
+	Orders      string
+	Payments    string
+	AuditEvents string
+	APIKeys     string
+	Categories  string
+	Media       string
 }
 
 type RedisConfig struct {
@@ -58,16 +76,24 @@ type RabbitMQConfig struct {
 	Password string
 
 	// Queue names
-	EmailVerificationQueue string
-	PhoneVerificationQueue string
+	EmailVerificationQueue    string
+	PhoneVerificationQueue    string
+	ForgotPasswordQueue       string
+	SecurityNotificationQueue string
+	MagicLinkQueue            string
+	LoginOTPQueue             string
+	DataExportQueue           string
 }
 
 type JWTConfig struct {
-	Secret            string
-	Expiration        time.Duration
-	RefreshExpiration time.Duration
-	UserRole          string
-	StoreRole         string
+	KeysDir      string
+	SigningKeyID string
+	// Expiration is the lifetime of access tokens in minutes
+	Expiration              time.Duration
+	RefreshExpiration       time.Duration
+	ImpersonationExpiration time.Duration
+	UserRole                string
+	StoreRole               string
 }
 
 type TwilioConfig struct {
@@ -78,16 +104,121 @@ type TwilioConfig struct {
 }
 
 type SendgridConfig struct {
-	APIKey                   string
-	FromEmail                string
-	VerificationTemplateID   string
-	ForgotPasswordTemplateID string
+	APIKey                         string
+	FromEmail                      string
+	VerificationTemplateID         string
+	ForgotPasswordTemplateID       string
+	SecurityNotificationTemplateID string
+	MagicLinkTemplateID            string
+	DataExportTemplateID           string
 }
 
 type TimeConfig struct {
 	EmailExpireTime          time.Duration
 	PhoneExpireTime          time.Duration
 	ForgotPasswordExpireTime time.Duration
+	MFAPendingExpireTime     time.Duration
+	MagicLinkExpireTime      time.Duration
+	LoginOTPExpireTime       time.Duration
+	OAuthStateExpireTime     time.Duration
+	EmailChangeRevertTime    time.Duration
+	AccountDeletionGrace     time.Duration
+	AccountDeletionInterval  time.Duration
+	KnownDeviceExpireTime    time.Duration
+	NewDeviceReportTime      time.Duration
+	WebAuthnChallengeTime    time.Duration
+	CategoryTreeCacheTime    time.Duration
+}
+
+type LockoutConfig struct {
+	MaxLoginAttempts        int64
+	DelayThreshold          int64
+	MaxDelay                time.Duration
+	FailureWindow           time.Duration
+	LockoutDuration         time.Duration
+	MaxVerificationAttempts int64
+}
+
+// PasswordConfig selects the password hashing algorithm and its cost, and the password policy.
+// Argon2Memory is in KiB.
+type PasswordConfig struct {
+	Algorithm         string
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+
+	MinLength        int
+	MaxLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	HistorySize      int
+	// BreachedListPath is a file of SHA-1 hashes of breached passwords sorted by hash, one per line.
+	// The breached password check is disabled when it is empty.
+	BreachedListPath string
+}
+
+// OAuthConfig holds the social login providers. The endpoint URLs default to the real
+// identity providers and can be overridden, e.g. to point at a local stub provider.
+type OAuthConfig struct {
+	Google OAuthProviderConfig
+	GitHub OAuthProviderConfig
+}
+
+type OAuthProviderConfig struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	// Issuer and JWKSURL are used by OpenID Connect providers to verify ID tokens
+	Issuer  string
+	JWKSURL string
+	// APIURL is used by plain OAuth2 providers to fetch the user profile
+	APIURL string
+}
+
+// ChallengeConfig selects the challenge that clients of the public auth endpoints have to solve once
+// they look automated. Provider is "hashcash", "hcaptcha" or "turnstile", the CAPTCHA providers need
+// SiteKey and SecretKey and VerifyURL overrides their verification endpoint. A client is challenged
+// once it has made RequestThreshold requests or FailureThreshold failed requests to an endpoint within
+// Window, a threshold of zero challenges every request. HashcashDifficulty is in bits.
+type ChallengeConfig struct {
+	Provider           string
+	SiteKey            string
+	SecretKey          string
+	VerifyURL          string
+	HashcashDifficulty int
+	ExpireTime         time.Duration
+	RequestThreshold   int64
+	FailureThreshold   int64
+	Window             time.Duration
+}
+
+// SearchConfig selects the engine behind product search, "mongodb" searches the products collection
+// with its text index and is used for unknown backends
+type SearchConfig struct {
+	Backend string
+}
+
+// MediaConfig selects where uploaded images are stored. Storage is "cloudinary" or "local", Cloudinary
+// needs CloudinaryConfig and local storage is only used in its place in development. Local files are
+// written to LocalDir and served from LocalURL, /uploads of this server by default. MaxUploadSize is
+// in bytes.
+type MediaConfig struct {
+	Storage       string
+	LocalDir      string
+	LocalURL      string
+	MaxUploadSize int64
+}
+
+// WebAuthnConfig identifies the relying party to authenticators. RPID is the domain that passkeys are
+// bound to and Origin the origin the browser reports, both default to the host of BaseURL.
+type WebAuthnConfig struct {
+	RPID   string
+	RPName string
+	Origin string
 }
 
 func LoadConfig() *Config {
@@ -97,19 +228,87 @@ func LoadConfig() *Config {
 	viper.AddConfigPath(".")
 	viper.AutomaticEnv()
 
-	if err := viper.ReadInConfig(); err != nil {
+	// The .env file is optional, every setting can be given as an environment variable instead
+	if err := viper.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		panic(err)
 	}
 
+	// JWT_EXPIRES_IN was in hours, reading it as minutes would silently shorten the access tokens
+	if viper.IsSet("JWT_EXPIRES_IN") {
+		panic("JWT_EXPIRES_IN is no longer supported, set JWT_ACCESS_EXPIRES_IN_MINUTES instead")
+	}
+
 	// Set default values
-	viper.SetDefault("ENVIRONMENT", "development")
 	viper.SetDefault("PORT", "8080")
+	viper.SetDefault("BASE_URL", "http://localhost:"+viper.GetString("PORT"))
+	viper.SetDefault("MONGODB_COLLECTION_AUDIT_EVENTS", "audit_events")
+	viper.SetDefault("MONGODB_COLLECTION_API_KEYS", "api_keys")
+	viper.SetDefault("MONGODB_COLLECTION_PRODUCTS", "products")
+	viper.SetDefault("MONGODB_COLLECTION_CATEGORIES", "categories")
+	viper.SetDefault("MONGODB_COLLECTION_MEDIA", "media")
+	viper.SetDefault("JWT_KEYS_DIR", "keys")
+	viper.SetDefault("JWT_SIGNING_KEY_ID", "default")
+	viper.SetDefault("JWT_ACCESS_EXPIRES_IN_MINUTES", 15)
+	viper.SetDefault("JWT_IMPERSONATION_EXPIRES_IN", 10)        // minutes
+	viper.SetDefault("JWT_REFRESH_EXPIRES_IN", 720)             // hours
+	viper.SetDefault("MFA_PENDING_EXPIRE_TIME", 300)            // seconds
+	viper.SetDefault("MAGIC_LINK_EXPIRE_TIME", 900)             // seconds
+	viper.SetDefault("LOGIN_OTP_EXPIRE_TIME", 300)              // seconds
+	viper.SetDefault("OAUTH_STATE_EXPIRE_TIME", 600)            // seconds
+	viper.SetDefault("EMAIL_CHANGE_REVERT_EXPIRE_TIME", 604800) // seconds
+	viper.SetDefault("ACCOUNT_DELETION_GRACE_PERIOD", 720)      // hours
+	viper.SetDefault("ACCOUNT_DELETION_CHECK_INTERVAL", 60)     // minutes
+	viper.SetDefault("KNOWN_DEVICE_EXPIRE_TIME", 7776000)       // seconds
+	viper.SetDefault("NEW_DEVICE_REPORT_EXPIRE_TIME", 604800)   // seconds
+	viper.SetDefault("WEBAUTHN_CHALLENGE_EXPIRE_TIME", 300)     // seconds
+	viper.SetDefault("CATEGORY_TREE_CACHE_TIME", 3600)          // seconds
+	viper.SetDefault("WEBAUTHN_RP_ID", hostname(viper.GetString("BASE_URL")))
+	viper.SetDefault("WEBAUTHN_RP_NAME", viper.GetString("APP_NAME"))
+	viper.SetDefault("WEBAUTHN_ORIGIN", viper.GetString("BASE_URL"))
+	viper.SetDefault("OAUTH_GOOGLE_AUTH_URL", "https://accounts.google.com/o/oauth2/v2/auth")
+	viper.SetDefault("OAUTH_GOOGLE_TOKEN_URL", "https://oauth2.googleapis.com/token")
+	viper.SetDefault("OAUTH_GOOGLE_ISSUER", "https://accounts.google.com")
+	viper.SetDefault("OAUTH_GOOGLE_JWKS_URL", "https://www.googleapis.com/oauth2/v3/certs")
+	viper.SetDefault("OAUTH_GITHUB_AUTH_URL", "https://github.com/login/oauth/authorize")
+	viper.SetDefault("OAUTH_GITHUB_TOKEN_URL", "https://github.com/login/oauth/access_token")
+	viper.SetDefault("OAUTH_GITHUB_API_URL", "https://api.github.com")
+	viper.SetDefault("PASSWORD_HASH_ALGORITHM", "argon2id")
+	viper.SetDefault("PASSWORD_ARGON2_MEMORY", 19456) // KiB
+	viper.SetDefault("PASSWORD_ARGON2_ITERATIONS", 2)
+	viper.SetDefault("PASSWORD_ARGON2_PARALLELISM", 1)
+	viper.SetDefault("PASSWORD_BCRYPT_COST", 12)
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 10)
+	viper.SetDefault("PASSWORD_MAX_LENGTH", 128)
+	viper.SetDefault("PASSWORD_REQUIRE_UPPERCASE", true)
+	viper.SetDefault("PASSWORD_REQUIRE_LOWERCASE", true)
+	viper.SetDefault("PASSWORD_REQUIRE_DIGIT", true)
+	viper.SetDefault("PASSWORD_REQUIRE_SYMBOL", false)
+	viper.SetDefault("PASSWORD_HISTORY_SIZE", 5)
+	viper.SetDefault("PASSWORD_BREACHED_LIST_PATH", "") // disabled unless a list is configured
+	viper.SetDefault("LOCKOUT_MAX_LOGIN_ATTEMPTS", 10)
+	viper.SetDefault("LOCKOUT_DELAY_THRESHOLD", 3)
+	viper.SetDefault("LOCKOUT_MAX_DELAY", 60)       // seconds
+	viper.SetDefault("LOCKOUT_FAILURE_WINDOW", 900) // seconds
+	viper.SetDefault("LOCKOUT_DURATION", 900)       // seconds
+	viper.SetDefault("LOCKOUT_MAX_VERIFICATION_ATTEMPTS", 5)
+	viper.SetDefault("CHALLENGE_PROVIDER", "hashcash")
+	viper.SetDefault("CHALLENGE_HASHCASH_DIFFICULTY", 20)
+	viper.SetDefault("CHALLENGE_EXPIRE_TIME", 300) // seconds
+	viper.SetDefault("CHALLENGE_REQUEST_THRESHOLD", 20)
+	viper.SetDefault("CHALLENGE_FAILURE_THRESHOLD", 3)
+	viper.SetDefault("CHALLENGE_WINDOW", 900) // seconds
+	viper.SetDefault("SEARCH_BACKEND", "mongodb")
+	viper.SetDefault("MEDIA_STORAGE", "cloudinary")
+	viper.SetDefault("MEDIA_LOCAL_DIR", "uploads")
+	viper.SetDefault("MEDIA_LOCAL_URL", viper.GetString("BASE_URL")+"/uploads")
+	viper.SetDefault("MEDIA_MAX_UPLOAD_SIZE", 5242880) // bytes
 
 	return &Config{
 		Server: ServerConfig{
 			AppName:     viper.GetString("APP_NAME"),
 			Environment: viper.GetString("ENVIRONMENT"),
 			Port:        viper.GetString("PORT"),
+			BaseURL:     viper.GetString("BASE_URL"),
 		},
 		Cloudinary: CloudinaryConfig{
 			CloudName: viper.GetString("CLOUDINARY_CLOUD_NAME"),
@@ -123,10 +322,14 @@ func LoadConfig() *Config {
 			Password: viper.GetString("MONGODB_PASSWORD"),
 			Database: viper.GetString("MONGODB_DATABASE"),
 			Collections: MongoDBCollectionConfig{
-				Users:    viper.GetString("MONGODB_COLLECTION_USERS"),
-				Products: viper.GetString("MONGODB_COLLECTION_PRODUCTS"),
-				Orders:   viper.GetString("MONGODB_COLLECTION_ORDERS"),
-				Payments: viper.GetString("MONGODB_COLLECTION_PAYMENTS"),
+				Users:       viper.GetString("MONGODB_COLLECTION_USERS"),
+				Products:    viper.GetString("MONGODB_COLLECTION_PRODUCTS"),
+				Orders:      viper.GetString("MONGODB_COLLECTION_ORDERS"),
+				Payments:    viper.GetString("MONGODB_COLLECTION_PAYMENTS"),
+				AuditEvents: viper.GetString("MONGODB_COLLECTION_AUDIT_EVENTS"),
+				APIKeys:     viper.GetString("MONGODB_COLLECTION_API_KEYS"),
+				Categories:  viper.GetString("MONGODB_COLLECTION_CATEGORIES"),
+				Media:       viper.GetString("MONGODB_COLLECTION_MEDIA"),
 			},
 		},
 		Redis: RedisConfig{
@@ -140,13 +343,20 @@ func LoadConfig() *Config {
 			Username: viper.GetString("RABBITMQ_USERNAME"),
 			Password: viper.GetString("RABBITMQ_PASSWORD"),
 			// Queue names
-			EmailVerificationQueue: "email_verification",
-			PhoneVerificationQueue: "phone_verification",
+			EmailVerificationQueue:    "email_verification",
+			PhoneVerificationQueue:    "phone_verification",
+			ForgotPasswordQueue:       "forgot_password",
+			SecurityNotificationQueue: "security_notification",
+			MagicLinkQueue:            "magic_link",
+			LoginOTPQueue:             "login_otp",
+			DataExportQueue:           "data_export",
 		},
 		JWT: JWTConfig{
-			Secret:            viper.GetString("JWT_SECRET"),
-			Expiration:        viper.GetDuration("JWT_EXPIRES_IN"),
-			RefreshExpiration: viper.GetDuration("JWT_REFRESH_EXPIRES_IN"),
+			KeysDir:                 viper.GetString("JWT_KEYS_DIR"),
+			SigningKeyID:            viper.GetString("JWT_SIGNING_KEY_ID"),
+			Expiration:              viper.GetDuration("JWT_ACCESS_EXPIRES_IN_MINUTES"),
+			RefreshExpiration:       viper.GetDuration("JWT_REFRESH_EXPIRES_IN"),
+			ImpersonationExpiration: viper.GetDuration("JWT_IMPERSONATION_EXPIRES_IN"),
 		},
 		Twilio: TwilioConfig{
 			AccountSID:        viper.GetString("TWILIO_ACCOUNT_SID"),
@@ -155,15 +365,94 @@ func LoadConfig() *Config {
 			FromNumber:        viper.GetString("TWILIO_FROM_NUMBER"),
 		},
 		Sendgrid: SendgridConfig{
-			APIKey:                   viper.GetString("SENDGRID_API_KEY"),
-			FromEmail:                viper.GetString("SENDGRID_FROM_EMAIL"),
-			VerificationTemplateID:   viper.GetString("SENDGRID_VERIFICATION_EMAIL_TEMPLATE_ID"),
-			ForgotPasswordTemplateID: viper.GetString("SENDGRID_FORGOT_PASSWORD_EMAIL_TEMPLATE_ID"),
+			APIKey:                         viper.GetString("SENDGRID_API_KEY"),
+			FromEmail:                      viper.GetString("SENDGRID_FROM_EMAIL"),
+			VerificationTemplateID:         viper.GetString("SENDGRID_VERIFICATION_EMAIL_TEMPLATE_ID"),
+			ForgotPasswordTemplateID:       viper.GetString("SENDGRID_FORGOT_PASSWORD_EMAIL_TEMPLATE_ID"),
+			SecurityNotificationTemplateID: viper.GetString("SENDGRID_SECURITY_NOTIFICATION_EMAIL_TEMPLATE_ID"),
+			MagicLinkTemplateID:            viper.GetString("SENDGRID_MAGIC_LINK_EMAIL_TEMPLATE_ID"),
+			DataExportTemplateID:           viper.GetString("SENDGRID_DATA_EXPORT_EMAIL_TEMPLATE_ID"),
 		},
 		Time: TimeConfig{
 			EmailExpireTime:          viper.GetDuration("SENDGRID_EMAIL_EXPIRE_TIME"),
 			PhoneExpireTime:          viper.GetDuration("SENDGRID_PHONE_EXPIRE_TIME"),
 			ForgotPasswordExpireTime: viper.GetDuration("SENDGRID_FORGOT_PASSWORD_EXPIRE_TIME"),
+			MFAPendingExpireTime:     viper.GetDuration("MFA_PENDING_EXPIRE_TIME"),
+			MagicLinkExpireTime:      viper.GetDuration("MAGIC_LINK_EXPIRE_TIME"),
+			LoginOTPExpireTime:       viper.GetDuration("LOGIN_OTP_EXPIRE_TIME"),
+			OAuthStateExpireTime:     viper.GetDuration("OAUTH_STATE_EXPIRE_TIME"),
+			EmailChangeRevertTime:    viper.GetDuration("EMAIL_CHANGE_REVERT_EXPIRE_TIME"),
+			AccountDeletionGrace:     viper.GetDuration("ACCOUNT_DELETION_GRACE_PERIOD"),
+			AccountDeletionInterval:  viper.GetDuration("ACCOUNT_DELETION_CHECK_INTERVAL"),
+			KnownDeviceExpireTime:    viper.GetDuration("KNOWN_DEVICE_EXPIRE_TIME"),
+			NewDeviceReportTime:      viper.GetDuration("NEW_DEVICE_REPORT_EXPIRE_TIME"),
+			WebAuthnChallengeTime:    viper.GetDuration("WEBAUTHN_CHALLENGE_EXPIRE_TIME"),
+			CategoryTreeCacheTime:    viper.GetDuration("CATEGORY_TREE_CACHE_TIME"),
+		},
+		Lockout: LockoutConfig{
+			MaxLoginAttempts:        viper.GetInt64("LOCKOUT_MAX_LOGIN_ATTEMPTS"),
+			DelayThreshold:          viper.GetInt64("LOCKOUT_DELAY_THRESHOLD"),
+			MaxDelay:                viper.GetDuration("LOCKOUT_MAX_DELAY"),
+			FailureWindow:           viper.GetDuration("LOCKOUT_FAILURE_WINDOW"),
+			LockoutDuration:         viper.GetDuration("LOCKOUT_DURATION"),
+			MaxVerificationAttempts: viper.GetInt64("LOCKOUT_MAX_VERIFICATION_ATTEMPTS"),
+		},
+		Password: PasswordConfig{
+			Algorithm:         viper.GetString("PASSWORD_HASH_ALGORITHM"),
+			Argon2Memory:      viper.GetUint32("PASSWORD_ARGON2_MEMORY"),
+			Argon2Iterations:  viper.GetUint32("PASSWORD_ARGON2_ITERATIONS"),
+			Argon2Parallelism: uint8(viper.GetUint("PASSWORD_ARGON2_PARALLELISM")),
+			BcryptCost:        viper.GetInt("PASSWORD_BCRYPT_COST"),
+			MinLength:         viper.GetInt("PASSWORD_MIN_LENGTH"),
+			MaxLength:         viper.GetInt("PASSWORD_MAX_LENGTH"),
+			RequireUppercase:  viper.GetBool("PASSWORD_REQUIRE_UPPERCASE"),
+			RequireLowercase:  viper.GetBool("PASSWORD_REQUIRE_LOWERCASE"),
+			RequireDigit:      viper.GetBool("PASSWORD_REQUIRE_DIGIT"),
+			RequireSymbol:     viper.GetBool("PASSWORD_REQUIRE_SYMBOL"),
+			HistorySize:       viper.GetInt("PASSWORD_HISTORY_SIZE"),
+			BreachedListPath:  viper.GetString("PASSWORD_BREACHED_LIST_PATH"),
+		},
+		OAuth: OAuthConfig{
+			Google: OAuthProviderConfig{
+				ClientID:     viper.GetString("OAUTH_GOOGLE_CLIENT_ID"),
+				ClientSecret: viper.GetString("OAUTH_GOOGLE_CLIENT_SECRET"),
+				AuthURL:      viper.GetString("OAUTH_GOOGLE_AUTH_URL"),
+				TokenURL:     viper.GetString("OAUTH_GOOGLE_TOKEN_URL"),
+				Issuer:       viper.GetString("OAUTH_GOOGLE_ISSUER"),
+				JWKSURL:      viper.GetString("OAUTH_GOOGLE_JWKS_URL"),
+			},
+			GitHub: OAuthProviderConfig{
+				ClientID:     viper.GetString("OAUTH_GITHUB_CLIENT_ID"),
+				ClientSecret: viper.GetString("OAUTH_GITHUB_CLIENT_SECRET"),
+				AuthURL:      viper.GetString("OAUTH_GITHUB_AUTH_URL"),
+				TokenURL:     viper.GetString("OAUTH_GITHUB_TOKEN_URL"),
+				APIURL:       viper.GetString("OAUTH_GITHUB_API_URL"),
+			},
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:   viper.GetString("WEBAUTHN_RP_ID"),
+			RPName: viper.GetString("WEBAUTHN_RP_NAME"),
+			Origin: viper.GetString("WEBAUTHN_ORIGIN"),
+		},
+		Challenge: ChallengeConfig{
+			Provider:           viper.GetString("CHALLENGE_PROVIDER"),
+			SiteKey:            viper.GetString("CHALLENGE_SITE_KEY"),
+			SecretKey:          viper.GetString("CHALLENGE_SECRET_KEY"),
+			VerifyURL:          viper.GetString("CHALLENGE_VERIFY_URL"),
+			HashcashDifficulty: viper.GetInt("CHALLENGE_HASHCASH_DIFFICULTY"),
+			ExpireTime:         viper.GetDuration("CHALLENGE_EXPIRE_TIME"),
+			RequestThreshold:   viper.GetInt64("CHALLENGE_REQUEST_THRESHOLD"),
+			FailureThreshold:   viper.GetInt64("CHALLENGE_FAILURE_THRESHOLD"),
+			Window:             viper.GetDuration("CHALLENGE_WINDOW"),
+		},
+		Search: SearchConfig{
+			Backend: viper.GetString("SEARCH_BACKEND"),
+		},
+		Media: MediaConfig{
+			Storage:       viper.GetString("MEDIA_STORAGE"),
+			LocalDir:      viper.GetString("MEDIA_LOCAL_DIR"),
+			LocalURL:      strings.TrimSuffix(viper.GetString("MEDIA_LOCAL_URL"), "/"),
+			MaxUploadSize: viper.GetInt64("MEDIA_MAX_UPLOAD_SIZE"),
 		},
 	}
 }
@@ -183,6 +472,7 @@ func GetServerConfig() ServerConfig {
 		AppName:     GetConfig().Server.AppName,
 		Environment: GetConfig().Server.Environment,
 		Port:        GetConfig().Server.Port,
+		BaseURL:     GetConfig().Server.BaseURL,
 	}
 }
 
@@ -217,3 +507,41 @@ func GetSendgridConfig() SendgridConfig {
 func GetTimeConfig() TimeConfig {
 	return GetConfig().Time
 }
+
+func GetLockoutConfig() LockoutConfig {
+	return GetConfig().Lockout
+}
+
+func GetOAuthConfig() OAuthConfig {
+	return GetConfig().OAuth
+}
+
+func GetPasswordConfig() PasswordConfig {
+	return GetConfig().Password
+}
+
+func GetChallengeConfig() ChallengeConfig {
+	return GetConfig().Challenge
+}
+
+func GetWebAuthnConfig() WebAuthnConfig {
+	return GetConfig().WebAuthn
+}
+
+func GetSearchConfig() SearchConfig {
+	return GetConfig().Search
+}
+
+func GetMediaConfig() MediaConfig {
+	return GetConfig().Media
+}
+
+// hostname returns the host of a URL without its port
+func hostname(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return parsedURL.Hostname()
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/rabbitmq"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+type AccountController struct {
+	accountService services.AccountService
+	auditService   services.AuditService
+	AccountQueue   rabbitmq.AccountQueueManager
+}
+
+func NewAccountController() *AccountController {
+	return &AccountController{
+		accountService: services.NewAccountService(),
+		auditService:   services.NewAuditService(),
+		AccountQueue:   rabbitmq.NewAccountQueueManager(),
+	}
+}
+
+func (controller *AccountController) Deactivate(ctx *fiber.Ctx) error {
+	var user models.UserPasswordConfirmRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := controller.accountService.Deactivate(userId, user, helpers.GetClientInfo(ctx)); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.AccountDeactivateResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Message: "Your account has been deactivated, sign in again to reactivate it",
+	})
+}
+
+func (controller *AccountController) Delete(ctx *fiber.Ctx) error {
+	var user models.UserPasswordConfirmRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	deletionScheduledAt, err := controller.accountService.ScheduleDeletion(userId, user, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(types.AccountDeleteResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Message:             "Your account will be deleted, sign in again before the deletion date to cancel it",
+		DeletionScheduledAt: deletionScheduledAt,
+	})
+}
+
+func (controller *AccountController) Export(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	// The archive is built in the background and sent by email
+	controller.AccountQueue.PublishDataExport(userId.Hex())
+	controller.accountService.RecordDataExportRequest(userId, helpers.GetClientInfo(ctx))
+
+	return ctx.Status(fiber.StatusAccepted).JSON(types.AccountExportResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Message: "Your data export is being prepared and will be sent to your email address",
+	})
+}
+
+func (controller *AccountController) GetSecurityEvents(ctx *fiber.Ctx) error {
+	var query models.AuditEventListRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := ctx.QueryParser(&query); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	page, err := controller.auditService.GetUserEvents(userId, query)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(newAuditEventsResponse(page))
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+type AdminController struct {
+	userService  services.UserService
+	auditService services.AuditService
+}
+
+func NewAdminController() *AdminController {
+	return &AdminController{
+		userService:  services.NewUserService(),
+		auditService: services.NewAuditService(),
+	}
+}
+
+func (controller *AdminController) ChangeUserRole(ctx *fiber.Ctx) error {
+	var user models.UserChangeRoleRequest
+	adminId := ctx.Locals("userId").(primitive.ObjectID)
+
+	userId, err := primitive.ObjectIDFromHex(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "Invalid user id",
+		})
+	}
+
+	if userId == adminId {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "You can't change your own role",
+		})
+	}
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := controller.userService.ChangeRole(adminId, userId, user, helpers.GetClientInfo(ctx)); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.AdminChangeUserRoleResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
+func (controller *AdminController) Impersonate(ctx *fiber.Ctx) error {
+	adminId := ctx.Locals("userId").(primitive.ObjectID)
+	sessionId := ctx.Locals("sessionId").(string)
+
+	userId, err := primitive.ObjectIDFromHex(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "Invalid user id",
+		})
+	}
+
+	impersonation, err := controller.userService.Impersonate(adminId, sessionId, userId, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.AdminImpersonateResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Token:         impersonation.Token,
+		ExpiresAt:     impersonation.ExpiresAt,
+		Impersonating: userId.Hex(),
+	})
+}
+
+func (controller *AdminController) GetAuditEvents(ctx *fiber.Ctx) error {
+	var query models.AuditEventQueryRequest
+
+	if err := ctx.QueryParser(&query); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	page, err := controller.auditService.QueryEvents(query)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(newAuditEventsResponse(page))
+}
+
+func newAuditEventsResponse(page *services.AuditEventPage) types.AuditEventsResponse {
+	return types.AuditEventsResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Events: page.Events,
+		Pagination: types.Pagination{
+			Page:  page.Page,
+			Limit: page.Limit,
+			Total: page.Total,
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+type APIKeyController struct {
+	apiKeyService services.APIKeyService
+}
+
+func NewAPIKeyController() *APIKeyController {
+	return &APIKeyController{
+		apiKeyService: services.NewAPIKeyService(),
+	}
+}
+
+func (controller *APIKeyController) Create(ctx *fiber.Ctx) error {
+	var apiKey models.APIKeyCreateRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	role := ctx.Locals("role").(models.Role)
+
+	if err := ctx.BodyParser(&apiKey); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	newKey, key, err := controller.apiKeyService.Create(userId, role, apiKey, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(types.APIKeyCreateResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		APIKey: newKey,
+		Key:    key,
+	})
+}
+
+func (controller *APIKeyController) List(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	apiKeys, err := controller.apiKeyService.List(userId)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.APIKeysResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		APIKeys: apiKeys,
+	})
+}
+
+func (controller *APIKeyController) Get(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	apiKey, err := controller.apiKeyService.Get(userId, ctx.Params("id"))
+	if err != nil {
+		return apiKeyErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.APIKeyResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		APIKey: apiKey,
+	})
+}
+
+func (controller *APIKeyController) Update(ctx *fiber.Ctx) error {
+	var apiKey models.APIKeyUpdateRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	role := ctx.Locals("role").(models.Role)
+
+	if err := ctx.BodyParser(&apiKey); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	updatedKey, err := controller.apiKeyService.Update(userId, role, ctx.Params("id"), apiKey, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return apiKeyErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.APIKeyResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		APIKey: updatedKey,
+	})
+}
+
+func (controller *APIKeyController) Delete(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := controller.apiKeyService.Delete(userId, ctx.Params("id"), helpers.GetClientInfo(ctx)); err != nil {
+		return apiKeyErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.APIKeyDeleteResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
+func apiKeyErrorResponse(ctx *fiber.Ctx, err error) error {
+	status := fiber.StatusBadRequest
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		status = fiber.StatusNotFound
+	}
+
+	return ctx.Status(status).JSON(types.BaseResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+type CategoryController struct {
+	categoryService services.CategoryService
+}
+
+func NewCategoryController() *CategoryController {
+	return &CategoryController{
+		categoryService: services.NewCategoryService(),
+	}
+}
+
+func (controller *CategoryController) GetTree(ctx *fiber.Ctx) error {
+	tree, err := controller.categoryService.GetTree()
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.CategoryTreeResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Categories: tree,
+	})
+}
+
+func (controller *CategoryController) Get(ctx *fiber.Ctx) error {
+	category, breadcrumbs, err := controller.categoryService.Get(ctx.Params("id"))
+	if err != nil {
+		return categoryErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.CategoryResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Category:    category,
+		Breadcrumbs: breadcrumbs,
+	})
+}
+
+func (controller *CategoryController) Create(ctx *fiber.Ctx) error {
+	var category models.CategoryCreateRequest
+
+	if err := ctx.BodyParser(&category); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	newCategory, err := controller.categoryService.Create(category)
+	if err != nil {
+		return categoryErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(types.CategoryResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Category: newCategory,
+	})
+}
+
+func (controller *CategoryController) Update(ctx *fiber.Ctx) error {
+	var category models.CategoryUpdateRequest
+
+	if err := ctx.BodyParser(&category); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	updatedCategory, err := controller.categoryService.Update(ctx.Params("id"), category)
+	if err != nil {
+		return categoryErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.CategoryResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Category: updatedCategory,
+	})
+}
+
+func (controller *CategoryController) Move(ctx *fiber.Ctx) error {
+	var move models.CategoryMoveRequest
+
+	if err := ctx.BodyParser(&move); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	category, err := controller.categoryService.Move(ctx.Params("id"), move)
+	if err != nil {
+		return categoryErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.CategoryResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Category: category,
+	})
+}
+
+func (controller *CategoryController) Delete(ctx *fiber.Ctx) error {
+	if err := controller.categoryService.Delete(ctx.Params("id")); err != nil {
+		return categoryErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.CategoryDeleteResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
+func categoryErrorResponse(ctx *fiber.Ctx, err error) error {
+	status := fiber.StatusBadRequest
+	if errors.Is(err, services.ErrCategoryNotFound) {
+		status = fiber.StatusNotFound
+	}
+
+	return ctx.Status(status).JSON(types.BaseResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
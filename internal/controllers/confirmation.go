@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"html/template"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// confirmationPageTemplate is served on the GET of links sent by email. Link scanners and mail
+// clients open these links on their own, so the page only submits the token back with a POST
+// once the user confirms.
+var confirmationPageTemplate = template.Must(template.New("confirmation").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">{{.Button}}</button>
+</form>
+</body>
+</html>
+`))
+
+type confirmationPage struct {
+	Title   string
+	Message string
+	Button  string
+	Action  string
+	Token   string
+}
+
+// renderConfirmationPage renders a page that posts the token of the link back to the same path
+func renderConfirmationPage(ctx *fiber.Ctx, page confirmationPage) error {
+	page.Action = ctx.Path()
+	page.Token = ctx.Query("token")
+
+	// The token must not leak through the referrer or caches, and the page only posts to this server
+	ctx.Set(fiber.HeaderReferrerPolicy, "no-referrer")
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ctx.Set(fiber.HeaderContentSecurityPolicy, "default-src 'none'; form-action 'self'; frame-ancestors 'none'")
+	ctx.Type("html", "utf-8")
+
+	return confirmationPageTemplate.Execute(ctx, page)
+}
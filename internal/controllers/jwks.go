@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+type JWKSController struct{}
+
+func NewJWKSController() *JWKSController {
+	return &JWKSController{}
+}
+
+// GetJWKS publishes the public keys that access tokens can be verified with
+func (controller *JWKSController) GetJWKS(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=300")
+
+	return ctx.Status(fiber.StatusOK).JSON(types.JWKSResponse{
+		Keys: helpers.GetJWTKeyring().JSONWebKeys(),
+	})
+}
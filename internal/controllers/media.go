@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+type MediaController struct {
+	mediaService services.MediaService
+}
+
+func NewMediaController() *MediaController {
+	return &MediaController{
+		mediaService: services.NewMediaService(),
+	}
+}
+
+func (controller *MediaController) UploadProductImage(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	permissions := ctx.Locals("permissions").(models.Permissions)
+
+	data, err := readUpload(ctx)
+	if err != nil {
+		return mediaErrorResponse(ctx, err)
+	}
+
+	media, err := controller.mediaService.UploadProductImage(userId, permissions, ctx.Params("id"), data)
+	if err != nil {
+		return mediaErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(types.MediaResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Media: media,
+	})
+}
+
+func (controller *MediaController) DeleteProductImage(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	permissions := ctx.Locals("permissions").(models.Permissions)
+
+	if err := controller.mediaService.DeleteProductImage(userId, permissions, ctx.Params("id"), ctx.Params("mediaId")); err != nil {
+		return mediaErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.MediaDeleteResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
+func (controller *MediaController) UploadProfileImage(ctx *fiber.Ctx) error {
+	return controller.uploadUserImage(ctx, models.MediaTargetProfileImage)
+}
+
+func (controller *MediaController) UploadBannerImage(ctx *fiber.Ctx) error {
+	return controller.uploadUserImage(ctx, models.MediaTargetBannerImage)
+}
+
+func (controller *MediaController) uploadUserImage(ctx *fiber.Ctx, target models.MediaTarget) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	data, err := readUpload(ctx)
+	if err != nil {
+		return mediaErrorResponse(ctx, err)
+	}
+
+	media, err := controller.mediaService.UploadUserImage(userId, target, data)
+	if err != nil {
+		return mediaErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(types.MediaResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Media: media,
+	})
+}
+
+func (controller *MediaController) DeleteUserImage(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := controller.mediaService.DeleteUserImage(userId, ctx.Params("id")); err != nil {
+		return mediaErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.MediaDeleteResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
+// readUpload reads the file of a multipart upload, reading stops once the file exceeds the size limit
+func readUpload(ctx *fiber.Ctx) ([]byte, error) {
+	maxUploadSize := config.GetMediaConfig().MaxUploadSize
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		return nil, errors.New("The image must be uploaded as the file field of a multipart form")
+	}
+
+	if fileHeader.Size > maxUploadSize {
+		return nil, services.ErrMediaTooLarge
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxUploadSize {
+		return nil, services.ErrMediaTooLarge
+	}
+
+	return data, nil
+}
+
+func mediaErrorResponse(ctx *fiber.Ctx, err error) error {
+	status := fiber.StatusBadRequest
+	switch {
+	case errors.Is(err, services.ErrMediaNotFound), errors.Is(err, services.ErrProductNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, services.ErrMediaTooLarge):
+		status = fiber.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrUnsupportedMediaType):
+		status = fiber.StatusUnsupportedMediaType
+	}
+
+	return ctx.Status(status).JSON(types.BaseResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
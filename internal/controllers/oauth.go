@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/rabbitmq"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+// oauthBindingCookie holds the binding of the flow started in the browser, it is only sent to the
+// OAuth routes and never readable by scripts
+const oauthBindingCookie = "oauth_binding"
+
+type OAuthController struct {
+	oauthService services.OAuthService
+	EmailQueue   rabbitmq.EmailQueueManager
+}
+
+func NewOAuthController() *OAuthController {
+	return &OAuthController{
+		oauthService: services.NewOAuthService(),
+		EmailQueue:   rabbitmq.NewEmailQueueManager(),
+	}
+}
+
+func (controller *OAuthController) StartLogin(ctx *fiber.Ctx) error {
+	authorization, err := controller.oauthService.StartLogin(ctx.Params("provider"))
+	if err != nil {
+		return oauthErrorResponse(ctx, err)
+	}
+
+	setOAuthBindingCookie(ctx, authorization.Binding, time.Now().Add(config.GetTimeConfig().OAuthStateExpireTime*time.Second))
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserOAuthAuthorizationResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		AuthorizationURL: authorization.URL,
+	})
+}
+
+func (controller *OAuthController) StartLink(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	authorization, err := controller.oauthService.StartLink(userId, ctx.Params("provider"))
+	if err != nil {
+		return oauthErrorResponse(ctx, err)
+	}
+
+	setOAuthBindingCookie(ctx, authorization.Binding, time.Now().Add(config.GetTimeConfig().OAuthStateExpireTime*time.Second))
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserOAuthAuthorizationResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		AuthorizationURL: authorization.URL,
+	})
+}
+
+func (controller *OAuthController) Callback(ctx *fiber.Ctx) error {
+	var callback models.UserOAuthCallbackRequest
+
+	if err := ctx.QueryParser(&callback); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	binding := ctx.Cookies(oauthBindingCookie)
+	setOAuthBindingCookie(ctx, "", time.Unix(0, 0))
+
+	tokens, err := controller.oauthService.Callback(ctx.Params("provider"), binding, callback, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return oauthErrorResponse(ctx, err)
+	}
+
+	// Flows started to link an account do not sign in
+	if tokens == nil {
+		return ctx.Status(fiber.StatusOK).JSON(types.UserOAuthLinkResponse{
+			BaseResponse: types.BaseResponse{
+				Success: true,
+			},
+			Message: "Account linked successfully",
+		})
+	}
+
+	publishNewDeviceSignIn(controller.EmailQueue, tokens)
+
+	return ctx.Status(fiber.StatusOK).JSON(newLoginResponse(tokens))
+}
+
+func (controller *OAuthController) Unlink(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := controller.oauthService.Unlink(userId, ctx.Params("provider"), helpers.GetClientInfo(ctx)); err != nil {
+		return oauthErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserOAuthLinkResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Message: "Account unlinked successfully",
+	})
+}
+
+// setOAuthBindingCookie stores the binding of a flow until it expires, an expiry in the past deletes it.
+// The cookie is sent with the redirect back from the provider, a top level navigation.
+func setOAuthBindingCookie(ctx *fiber.Ctx, binding string, expires time.Time) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     oauthBindingCookie,
+		Value:    binding,
+		Path:     "/auth/oauth",
+		Expires:  expires,
+		Secure:   strings.HasPrefix(config.GetServerConfig().BaseURL, "https://"),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+func oauthErrorResponse(ctx *fiber.Ctx, err error) error {
+	status := fiber.StatusBadRequest
+	if errors.Is(err, services.ErrOAuthProviderNotFound) {
+		status = fiber.StatusNotFound
+	}
+
+	return ctx.Status(status).JSON(types.BaseResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/rabbitmq"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+type PasswordlessController struct {
+	passwordlessService services.PasswordlessService
+	EmailQueue          rabbitmq.EmailQueueManager
+	PhoneQueue          rabbitmq.PhoneQueueManager
+}
+
+func NewPasswordlessController() *PasswordlessController {
+	return &PasswordlessController{
+		passwordlessService: services.NewPasswordlessService(),
+		EmailQueue:          rabbitmq.NewEmailQueueManager(),
+		PhoneQueue:          rabbitmq.NewPhoneQueueManager(),
+	}
+}
+
+func (controller *PasswordlessController) RequestMagicLink(ctx *fiber.Ctx) error {
+	var user models.UserMagicLinkRequest
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	emailExists, err := controller.passwordlessService.RequestMagicLink(user)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	// The response is the same either way to avoid email enumeration
+	if emailExists {
+		controller.EmailQueue.PublishMagicLink(user.Email)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserPasswordlessResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Message: "If an account with that email exists, a login link has been sent",
+	})
+}
+
+// MagicLinkPage is the page a magic link opens, signing in needs a POST so that link scanners
+// can't use up the link
+func (controller *PasswordlessController) MagicLinkPage(ctx *fiber.Ctx) error {
+	return renderConfirmationPage(ctx, confirmationPage{
+		Title:   "Sign in",
+		Message: "Continue to sign in with the link sent to your email.",
+		Button:  "Sign in",
+	})
+}
+
+// VerifyMagicLink accepts the token as a form post from MagicLinkPage or as JSON
+func (controller *PasswordlessController) VerifyMagicLink(ctx *fiber.Ctx) error {
+	var user models.UserMagicLinkVerifyRequest
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	tokens, err := controller.passwordlessService.VerifyMagicLink(user, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	publishNewDeviceSignIn(controller.EmailQueue, tokens)
+
+	return ctx.Status(fiber.StatusOK).JSON(newLoginResponse(tokens))
+}
+
+func (controller *PasswordlessController) RequestLoginOTP(ctx *fiber.Ctx) error {
+	var user models.UserLoginOTPRequest
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	phoneVerified, err := controller.passwordlessService.RequestLoginOTP(user)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	// The response is the same either way to avoid phone number enumeration
+	if phoneVerified {
+		controller.PhoneQueue.PublishLoginOTP(user.PhoneNumber)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserPasswordlessResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Message: "If an account with that verified phone number exists, a login code has been sent",
+	})
+}
+
+func (controller *PasswordlessController) VerifyLoginOTP(ctx *fiber.Ctx) error {
+	var user models.UserLoginOTPVerifyRequest
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	tokens, err := controller.passwordlessService.VerifyLoginOTP(user, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	publishNewDeviceSignIn(controller.EmailQueue, tokens)
+
+	return ctx.Status(fiber.StatusOK).JSON(newLoginResponse(tokens))
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+type ProductController struct {
+	productService       services.ProductService
+	productSearchService services.ProductSearchService
+}
+
+func NewProductController() *ProductController {
+	return &ProductController{
+		productService:       services.NewProductService(),
+		productSearchService: services.NewProductSearchService(),
+	}
+}
+
+func (controller *ProductController) List(ctx *fiber.Ctx) error {
+	var query models.ProductListRequest
+
+	if err := ctx.QueryParser(&query); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	page, err := controller.productService.List(query)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(newProductsResponse(page))
+}
+
+func (controller *ProductController) Search(ctx *fiber.Ctx) error {
+	var request models.ProductSearchRequest
+
+	if err := ctx.QueryParser(&request); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	result, err := controller.productSearchService.Search(request)
+	if err != nil {
+		return productErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.ProductSearchResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Products:   result.Products,
+		NextCursor: result.NextCursor,
+		Facets:     result.Facets,
+	})
+}
+
+func (controller *ProductController) Get(ctx *fiber.Ctx) error {
+	product, err := controller.productService.Get(ctx.Params("id"))
+	if err != nil {
+		return productErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.ProductResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Product: product,
+	})
+}
+
+func (controller *ProductController) ListStore(ctx *fiber.Ctx) error {
+	var query models.ProductListRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	permissions := ctx.Locals("permissions").(models.Permissions)
+
+	if err := ctx.QueryParser(&query); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	page, err := controller.productService.ListStoreProducts(userId, permissions, query)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(newProductsResponse(page))
+}
+
+func (controller *ProductController) Create(ctx *fiber.Ctx) error {
+	var product models.ProductCreateRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := ctx.BodyParser(&product); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	newProduct, err := controller.productService.Create(userId, product)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(types.ProductResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Product: newProduct,
+	})
+}
+
+func (controller *ProductController) GetStore(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	permissions := ctx.Locals("permissions").(models.Permissions)
+
+	product, err := controller.productService.GetStoreProduct(userId, permissions, ctx.Params("id"))
+	if err != nil {
+		return productErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.ProductResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Product: product,
+	})
+}
+
+func (controller *ProductController) Update(ctx *fiber.Ctx) error {
+	var product models.ProductUpdateRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	permissions := ctx.Locals("permissions").(models.Permissions)
+
+	if err := ctx.BodyParser(&product); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	updatedProduct, err := controller.productService.Update(userId, permissions, ctx.Params("id"), product)
+	if err != nil {
+		return productErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.ProductResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Product: updatedProduct,
+	})
+}
+
+func (controller *ProductController) Delete(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	permissions := ctx.Locals("permissions").(models.Permissions)
+
+	if err := controller.productService.Delete(userId, permissions, ctx.Params("id")); err != nil {
+		return productErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.ProductDeleteResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
+func newProductsResponse(page *services.ProductPage) types.ProductsResponse {
+	return types.ProductsResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Products: page.Products,
+		Pagination: types.Pagination{
+			Page:  page.Page,
+			Limit: page.Limit,
+			Total: page.Total,
+		},
+	}
+}
+
+func productErrorResponse(ctx *fiber.Ctx, err error) error {
+	status := fiber.StatusBadRequest
+	if errors.Is(err, services.ErrProductNotFound) {
+		status = fiber.StatusNotFound
+	}
+
+	return ctx.Status(status).JSON(types.BaseResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
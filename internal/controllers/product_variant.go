@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+type ProductVariantController struct {
+	productVariantService services.ProductVariantService
+}
+
+func NewProductVariantController() *ProductVariantController {
+	return &ProductVariantController{
+		productVariantService: services.NewProductVariantService(),
+	}
+}
+
+func (controller *ProductVariantController) Get(ctx *fiber.Ctx) error {
+	variant, err := controller.productVariantService.GetVariant(ctx.Params("id"), ctx.Params("variantId"))
+	if err != nil {
+		return productVariantErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.ProductVariantResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Variant: variant,
+	})
+}
+
+func (controller *ProductVariantController) SetOptions(ctx *fiber.Ctx) error {
+	var options models.ProductOptionsRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	permissions := ctx.Locals("permissions").(models.Permissions)
+
+	if err := ctx.BodyParser(&options); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	product, err := controller.productVariantService.SetOptions(userId, permissions, ctx.Params("id"), options)
+	if err != nil {
+		return productVariantErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.ProductResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Product: product,
+	})
+}
+
+func (controller *ProductVariantController) Create(ctx *fiber.Ctx) error {
+	var variant models.ProductVariantCreateRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	permissions := ctx.Locals("permissions").(models.Permissions)
+
+	if err := ctx.BodyParser(&variant); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	newVariant, err := controller.productVariantService.CreateVariant(userId, permissions, ctx.Params("id"), variant)
+	if err != nil {
+		return productVariantErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(types.ProductVariantResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Variant: newVariant,
+	})
+}
+
+func (controller *ProductVariantController) Generate(ctx *fiber.Ctx) error {
+	var request models.ProductVariantGenerateRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	permissions := ctx.Locals("permissions").(models.Permissions)
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	product, err := controller.productVariantService.GenerateVariants(userId, permissions, ctx.Params("id"), request)
+	if err != nil {
+		return productVariantErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.ProductResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Product: product,
+	})
+}
+
+func (controller *ProductVariantController) Update(ctx *fiber.Ctx) error {
+	var variant models.ProductVariantUpdateRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	permissions := ctx.Locals("permissions").(models.Permissions)
+
+	if err := ctx.BodyParser(&variant); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	updatedVariant, err := controller.productVariantService.UpdateVariant(userId, permissions, ctx.Params("id"), ctx.Params("variantId"), variant)
+	if err != nil {
+		return productVariantErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.ProductVariantResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Variant: updatedVariant,
+	})
+}
+
+func (controller *ProductVariantController) Delete(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	permissions := ctx.Locals("permissions").(models.Permissions)
+
+	if err := controller.productVariantService.DeleteVariant(userId, permissions, ctx.Params("id"), ctx.Params("variantId")); err != nil {
+		return productVariantErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.ProductVariantDeleteResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
+func productVariantErrorResponse(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrProductVariantNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return productErrorResponse(ctx, err)
+}
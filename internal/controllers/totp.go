@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/rabbitmq"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+type TOTPController struct {
+	totpService services.TOTPService
+	EmailQueue  rabbitmq.EmailQueueManager
+}
+
+func NewTOTPController() *TOTPController {
+	return &TOTPController{
+		totpService: services.NewTOTPService(),
+		EmailQueue:  rabbitmq.NewEmailQueueManager(),
+	}
+}
+
+func (controller *TOTPController) Setup(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	secret, uri, err := controller.totpService.Setup(userId)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserTwoFactorSetupResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Secret: secret,
+		URI:    uri,
+	})
+}
+
+func (controller *TOTPController) Enable(ctx *fiber.Ctx) error {
+	var user models.UserTwoFactorCodeRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	recoveryCodes, err := controller.totpService.Enable(userId, user, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserTwoFactorRecoveryCodesResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (controller *TOTPController) Disable(ctx *fiber.Ctx) error {
+	var user models.UserTwoFactorDisableRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := controller.totpService.Disable(userId, user, helpers.GetClientInfo(ctx)); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserTwoFactorDisableResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
+func (controller *TOTPController) RegenerateRecoveryCodes(ctx *fiber.Ctx) error {
+	var user models.UserTwoFactorCodeRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	recoveryCodes, err := controller.totpService.RegenerateRecoveryCodes(userId, user, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserTwoFactorRecoveryCodesResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (controller *TOTPController) VerifyLogin(ctx *fiber.Ctx) error {
+	var user models.UserLoginTwoFactorRequest
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	tokens, err := controller.totpService.VerifyLogin(user, helpers.GetClientInfo(ctx))
+	var lockoutErr *services.LockoutError
+	if errors.As(err, &lockoutErr) {
+		return lockedOutResponse(ctx, lockoutErr)
+	}
+
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	publishNewDeviceSignIn(controller.EmailQueue, tokens)
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserLoginResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	})
+}
@@ -1,6 +1,11 @@
 package controllers
 
 import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
@@ -14,12 +19,14 @@ import (
 type UserController struct {
 	userService services.UserService
 	EmailQueue  rabbitmq.EmailQueueManager
+	PhoneQueue  rabbitmq.PhoneQueueManager
 }
 
 func NewUserController() *UserController {
 	return &UserController{
 		userService: services.NewUserService(),
 		EmailQueue:  rabbitmq.NewEmailQueueManager(),
+		PhoneQueue:  rabbitmq.NewPhoneQueueManager(),
 	}
 }
 
@@ -40,8 +47,12 @@ func (controller *UserController) Register(ctx *fiber.Ctx) error {
 		})
 	}
 
-	token, err := controller.userService.Register(user)
+	tokens, err := controller.userService.Register(user, helpers.GetClientInfo(ctx))
 	if err != nil {
+		if response, ok := passwordPolicyErrorResponse(err); ok {
+			return ctx.Status(fiber.StatusBadRequest).JSON(response)
+		}
+
 		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
 			Success: false,
 			Error:   err.Error(),
@@ -51,11 +62,17 @@ func (controller *UserController) Register(ctx *fiber.Ctx) error {
 	// Publish email verification message to RabbitMQ
 	controller.EmailQueue.PublishEmailVerification(user.FirstName, user.Email)
 
+	// Publish phone verification message to RabbitMQ when a phone number was given
+	if user.PhoneNumber != "" {
+		controller.PhoneQueue.PublishPhoneVerification(user.PhoneNumber)
+	}
+
 	return ctx.Status(fiber.StatusCreated).JSON(types.UserRegisterResponse{
 		BaseResponse: types.BaseResponse{
 			Success: true,
 		},
-		Token: token,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	})
 }
 
@@ -69,7 +86,24 @@ func (controller *UserController) Login(ctx *fiber.Ctx) error {
 		})
 	}
 
-	token, err := controller.userService.Login(user)
+	tokens, err := controller.userService.Login(user, helpers.GetClientInfo(ctx))
+	var lockoutErr *services.LockoutError
+	if errors.As(err, &lockoutErr) {
+		// Tell the account owner once, on the attempt that locked the account
+		if lockoutErr.JustLocked {
+			controller.EmailQueue.PublishSecurityNotification(models.SecurityNotification{
+				Type:  models.SecurityNotificationAccountLocked,
+				Email: user.Email,
+				Data: map[string]string{
+					"ip":        ctx.IP(),
+					"lockedFor": lockoutErr.RetryAfter.String(),
+				},
+			})
+		}
+
+		return lockedOutResponse(ctx, lockoutErr)
+	}
+
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
 			Success: false,
@@ -77,19 +111,45 @@ func (controller *UserController) Login(ctx *fiber.Ctx) error {
 		})
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(types.UserLoginResponse{
+	publishNewDeviceSignIn(controller.EmailQueue, tokens)
+
+	return ctx.Status(fiber.StatusOK).JSON(newLoginResponse(tokens))
+}
+
+func (controller *UserController) Refresh(ctx *fiber.Ctx) error {
+	var user models.UserRefreshRequest
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	tokens, err := controller.userService.Refresh(user, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserRefreshResponse{
 		BaseResponse: types.BaseResponse{
 			Success: true,
 		},
-		Token: token,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	})
 }
 
 func (controller *UserController) Logout(ctx *fiber.Ctx) error {
 	token := ctx.Locals("token").(string)
+	sessionId := ctx.Locals("sessionId").(string)
 	expFloat64 := ctx.Locals("exp").(float64)
+	userId := ctx.Locals("userId").(primitive.ObjectID)
 
-	err := controller.userService.Logout(token, expFloat64)
+	err := controller.userService.Logout(userId, token, sessionId, expFloat64, helpers.GetClientInfo(ctx))
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
 			Success: false,
@@ -104,10 +164,106 @@ func (controller *UserController) Logout(ctx *fiber.Ctx) error {
 	})
 }
 
+func (controller *UserController) LogoutAll(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := controller.userService.LogoutAll(userId, helpers.GetClientInfo(ctx)); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserLogoutResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
+// EndImpersonation is called with an impersonation token, which can't be used anymore afterwards
+func (controller *UserController) EndImpersonation(ctx *fiber.Ctx) error {
+	adminId, ok := ctx.Locals("impersonatorId").(primitive.ObjectID)
+	if !ok {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "You are not impersonating a user",
+		})
+	}
+
+	token := ctx.Locals("token").(string)
+	expFloat64 := ctx.Locals("exp").(float64)
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := controller.userService.EndImpersonation(adminId, userId, token, expFloat64, helpers.GetClientInfo(ctx)); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.AdminEndImpersonationResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
+func (controller *UserController) GetSessions(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+	sessionId := ctx.Locals("sessionId").(string)
+
+	sessions, err := controller.userService.GetSessions(userId)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	response := make([]types.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, types.SessionResponse{
+			ID:         session.ID,
+			Device:     session.Device,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == sessionId,
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserSessionsResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Sessions: response,
+	})
+}
+
+func (controller *UserController) RevokeSession(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := controller.userService.RevokeSession(userId, ctx.Params("id"), helpers.GetClientInfo(ctx)); err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserRevokeSessionResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
 func (controller *UserController) ChangePassword(ctx *fiber.Ctx) error {
 	var user models.UserChangePasswordRequest
 
 	token := ctx.Locals("token").(string)
+	sessionId := ctx.Locals("sessionId").(string)
 	expFloat64 := ctx.Locals("exp").(float64)
 	userId := ctx.Locals("userId").(primitive.ObjectID)
 
@@ -125,8 +281,12 @@ func (controller *UserController) ChangePassword(ctx *fiber.Ctx) error {
 		})
 	}
 
-	newToken, err := controller.userService.ChangePassword(userId, user, token, expFloat64)
+	tokens, err := controller.userService.ChangePassword(userId, user, token, sessionId, expFloat64, helpers.GetClientInfo(ctx))
 	if err != nil {
+		if response, ok := passwordPolicyErrorResponse(err); ok {
+			return ctx.Status(fiber.StatusBadRequest).JSON(response)
+		}
+
 		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
 			Success: false,
 			Error:   err.Error(),
@@ -137,14 +297,55 @@ func (controller *UserController) ChangePassword(ctx *fiber.Ctx) error {
 		BaseResponse: types.BaseResponse{
 			Success: true,
 		},
-		Token: newToken,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	})
 }
 
 func (controller *UserController) ChangeEmail(ctx *fiber.Ctx) error {
 	var user models.UserChangeEmailRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	emailChange, err := controller.userService.ChangeEmail(userId, user, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	// The confirmation code goes to the new address, the old one can undo the change
+	controller.EmailQueue.PublishEmailVerification(emailChange.FirstName, emailChange.NewEmail)
+	controller.EmailQueue.PublishSecurityNotification(models.SecurityNotification{
+		Type:  models.SecurityNotificationEmailChangeRequested,
+		Email: emailChange.OldEmail,
+		Data: map[string]string{
+			"newEmail":   emailChange.NewEmail,
+			"revertLink": emailChange.RevertLink,
+			"ip":         ctx.IP(),
+		},
+	})
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserChangeEmailResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Message: "A confirmation code has been sent to the new email address",
+	})
+}
+
+func (controller *UserController) VerifyEmailChange(ctx *fiber.Ctx) error {
+	var user models.UserVerificationRequest
 
 	token := ctx.Locals("token").(string)
+	sessionId := ctx.Locals("sessionId").(string)
 	expFloat64 := ctx.Locals("exp").(float64)
 	userId := ctx.Locals("userId").(primitive.ObjectID)
 
@@ -155,7 +356,7 @@ func (controller *UserController) ChangeEmail(ctx *fiber.Ctx) error {
 		})
 	}
 
-	newToken, err := controller.userService.ChangeEmail(userId, user, token, expFloat64)
+	tokens, err := controller.userService.VerifyEmailChange(userId, user, token, sessionId, expFloat64, helpers.GetClientInfo(ctx))
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
 			Success: false,
@@ -163,11 +364,123 @@ func (controller *UserController) ChangeEmail(ctx *fiber.Ctx) error {
 		})
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(types.UserChangeEmailResponse{
+	return ctx.Status(fiber.StatusOK).JSON(types.UserVerifyEmailChangeResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	})
+}
+
+// RevertEmailChangePage is the page the revert link sent to the old address opens
+func (controller *UserController) RevertEmailChangePage(ctx *fiber.Ctx) error {
+	return renderConfirmationPage(ctx, confirmationPage{
+		Title:   "Revert email change",
+		Message: "If you did not change your email, restore your previous email. Every session will be signed out.",
+		Button:  "Restore my email",
+	})
+}
+
+// RevertEmailChange accepts the token as a form post from RevertEmailChangePage or as JSON
+func (controller *UserController) RevertEmailChange(ctx *fiber.Ctx) error {
+	var user models.UserRevertEmailChangeRequest
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	email, err := controller.userService.RevertEmailChange(user, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	controller.EmailQueue.PublishSecurityNotification(models.SecurityNotification{
+		Type:  models.SecurityNotificationEmailChangeReverted,
+		Email: email,
+		Data: map[string]string{
+			"ip": ctx.IP(),
+		},
+	})
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserRevertEmailChangeResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Message: "The email change has been reverted and all sessions were signed out, please reset your password",
+	})
+}
+
+// ReportNewDevicePage is the page the report link of a new sign-in notification opens
+func (controller *UserController) ReportNewDevicePage(ctx *fiber.Ctx) error {
+	return renderConfirmationPage(ctx, confirmationPage{
+		Title:   "Report a sign-in",
+		Message: "If you did not sign in from this device, every session will be signed out, your API keys and the passkeys added since will be removed and you will have to reset your password.",
+		Button:  "This wasn't me",
+	})
+}
+
+// ReportNewDevice accepts the token as a form post from ReportNewDevicePage or as JSON
+func (controller *UserController) ReportNewDevice(ctx *fiber.Ctx) error {
+	var user models.UserReportNewDeviceRequest
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	email, err := controller.userService.ReportNewDevice(user, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	// Sign-ins stay blocked until the password is reset with this link
+	controller.EmailQueue.PublishForgotPassword(email)
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserReportNewDeviceResponse{
 		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Message: "All sessions and API keys were revoked, please reset your password with the link sent to your email",
+	})
+}
+
+func (controller *UserController) ChangePhone(ctx *fiber.Ctx) error {
+	var user models.UserChangePhoneRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
 			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if err := controller.userService.ChangePhone(userId, user, helpers.GetClientInfo(ctx)); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	// Publish phone verification message to RabbitMQ
+	controller.PhoneQueue.PublishPhoneVerification(user.PhoneNumber)
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserChangePhoneResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
 		},
-		Token: newToken,
 	})
 }
 
@@ -182,7 +495,7 @@ func (controller *UserController) VerifyEmail(ctx *fiber.Ctx) error {
 		})
 	}
 
-	err := controller.userService.VerifyEmail(userId, user)
+	err := controller.userService.VerifyEmail(userId, user, helpers.GetClientInfo(ctx))
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
 			Success: false,
@@ -225,7 +538,7 @@ func (controller *UserController) VerifyPhone(ctx *fiber.Ctx) error {
 		})
 	}
 
-	err := controller.userService.VerifyPhone(userId, user)
+	err := controller.userService.VerifyPhone(userId, user, helpers.GetClientInfo(ctx))
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
 			Success: false,
@@ -259,7 +572,127 @@ func (controller *UserController) ResendPhoneVerification(ctx *fiber.Ctx) error
 }
 
 func (controller *UserController) ForgotPassword(ctx *fiber.Ctx) error {
-	return ctx.JSON(fiber.Map{
-		"token": helpers.GenerateForgotPasswordToken(),
+	var user models.UserForgotPasswordRequest
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	emailExists, err := controller.userService.ForgotPassword(user, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	// Publish forgot password message to RabbitMQ only for existing accounts,
+	// the response is the same either way to avoid email enumeration
+	if emailExists {
+		controller.EmailQueue.PublishForgotPassword(user.Email)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserForgotPasswordResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Message: "If an account with that email exists, a password reset link has been sent",
+	})
+}
+
+func (controller *UserController) ResetPassword(ctx *fiber.Ctx) error {
+	var user models.UserResetPasswordRequest
+	token := ctx.Params("token")
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	if user.NewPassword != user.NewPasswordConfirm {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "New password and new password confirm do not match",
+		})
+	}
+
+	if err := controller.userService.ResetPassword(token, user, helpers.GetClientInfo(ctx)); err != nil {
+		if response, ok := passwordPolicyErrorResponse(err); ok {
+			return ctx.Status(fiber.StatusBadRequest).JSON(response)
+		}
+
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.UserResetPasswordResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
 	})
 }
+
+// newLoginResponse builds the login response, which carries either a token pair
+// or an "mfa pending" token when a second factor is still required
+func newLoginResponse(tokens *services.AuthTokens) types.UserLoginResponse {
+	return types.UserLoginResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		MFARequired:  tokens.MFAToken != "",
+		MFAToken:     tokens.MFAToken,
+		MFAMethods:   tokens.MFAMethods,
+	}
+}
+
+// lockedOutResponse answers an attempt made while the account is locked or has to wait
+func lockedOutResponse(ctx *fiber.Ctx, lockoutErr *services.LockoutError) error {
+	ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(lockoutErr.RetryAfter.Seconds()))))
+	return ctx.Status(fiber.StatusTooManyRequests).JSON(types.BaseResponse{
+		Success: false,
+		Error:   lockoutErr.Error(),
+	})
+}
+
+// publishNewDeviceSignIn tells the account owner about a sign-in from an unknown device
+func publishNewDeviceSignIn(emailQueue rabbitmq.EmailQueueManager, tokens *services.AuthTokens) {
+	if tokens == nil || tokens.NewDevice == nil {
+		return
+	}
+
+	emailQueue.PublishSecurityNotification(models.SecurityNotification{
+		Type:  models.SecurityNotificationNewSignIn,
+		Email: tokens.NewDevice.Email,
+		Data: map[string]string{
+			"device":     tokens.NewDevice.Device,
+			"ip":         tokens.NewDevice.IP,
+			"signedInAt": tokens.NewDevice.SignedInAt.UTC().Format(time.RFC1123),
+			"reportLink": tokens.NewDevice.ReportLink,
+		},
+	})
+}
+
+// passwordPolicyErrorResponse builds the response listing every violated rule when err is a password policy error
+func passwordPolicyErrorResponse(err error) (types.PasswordPolicyErrorResponse, bool) {
+	var policyErr *services.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return types.PasswordPolicyErrorResponse{}, false
+	}
+
+	return types.PasswordPolicyErrorResponse{
+		BaseResponse: types.BaseResponse{
+			Success: false,
+			Error:   policyErr.Error(),
+		},
+		Violations: policyErr.Violations,
+	}, true
+}
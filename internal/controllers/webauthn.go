@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/rabbitmq"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+type WebAuthnController struct {
+	webAuthnService services.WebAuthnService
+	EmailQueue      rabbitmq.EmailQueueManager
+}
+
+func NewWebAuthnController() *WebAuthnController {
+	return &WebAuthnController{
+		webAuthnService: services.NewWebAuthnService(),
+		EmailQueue:      rabbitmq.NewEmailQueueManager(),
+	}
+}
+
+func (controller *WebAuthnController) BeginRegistration(ctx *fiber.Ctx) error {
+	var user models.UserPasswordConfirmRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := ctx.BodyParser(&user); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	options, err := controller.webAuthnService.BeginRegistration(userId, user)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.WebAuthnCreationOptionsResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Options: options,
+	})
+}
+
+func (controller *WebAuthnController) FinishRegistration(ctx *fiber.Ctx) error {
+	var request models.WebAuthnRegistrationRequest
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	credential, err := controller.webAuthnService.FinishRegistration(userId, request, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(types.WebAuthnCredentialResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Credential: credential,
+	})
+}
+
+func (controller *WebAuthnController) ListCredentials(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	credentials, err := controller.webAuthnService.ListCredentials(userId)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.WebAuthnCredentialsResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Credentials: credentials,
+	})
+}
+
+func (controller *WebAuthnController) DeleteCredential(ctx *fiber.Ctx) error {
+	userId := ctx.Locals("userId").(primitive.ObjectID)
+
+	if err := controller.webAuthnService.DeleteCredential(userId, ctx.Params("id"), helpers.GetClientInfo(ctx)); err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrWebAuthnCredentialNotFound) {
+			status = fiber.StatusNotFound
+		}
+
+		return ctx.Status(status).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.WebAuthnCredentialDeleteResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+	})
+}
+
+func (controller *WebAuthnController) BeginLogin(ctx *fiber.Ctx) error {
+	options, err := controller.webAuthnService.BeginLogin()
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.WebAuthnRequestOptionsResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Options: options,
+	})
+}
+
+func (controller *WebAuthnController) FinishLogin(ctx *fiber.Ctx) error {
+	var request models.WebAuthnLoginRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	tokens, err := controller.webAuthnService.FinishLogin(request, helpers.GetClientInfo(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	publishNewDeviceSignIn(controller.EmailQueue, tokens)
+
+	return ctx.Status(fiber.StatusOK).JSON(newLoginResponse(tokens))
+}
+
+func (controller *WebAuthnController) BeginSecondFactor(ctx *fiber.Ctx) error {
+	var request models.WebAuthnSecondFactorOptionsRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	options, err := controller.webAuthnService.BeginSecondFactor(request)
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(types.WebAuthnRequestOptionsResponse{
+		BaseResponse: types.BaseResponse{
+			Success: true,
+		},
+		Options: options,
+	})
+}
+
+func (controller *WebAuthnController) FinishSecondFactor(ctx *fiber.Ctx) error {
+	var request models.WebAuthnSecondFactorRequest
+
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	tokens, err := controller.webAuthnService.FinishSecondFactor(request, helpers.GetClientInfo(ctx))
+	var lockoutErr *services.LockoutError
+	if errors.As(err, &lockoutErr) {
+		return lockedOutResponse(ctx, lockoutErr)
+	}
+
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	publishNewDeviceSignIn(controller.EmailQueue, tokens)
+
+	return ctx.Status(fiber.StatusOK).JSON(newLoginResponse(tokens))
+}
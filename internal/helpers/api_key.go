@@ -0,0 +1,27 @@
+package helpers
+
+import "strings"
+
+const (
+	apiKeyPrefix       = "sk"
+	apiKeyLookupLength = 12
+	apiKeySecretLength = 40
+)
+
+// GenerateAPIKey generates an API key of the form sk_<lookup>_<secret> and returns it together
+// with its "sk_<lookup>" prefix. The prefix is stored in plain text to find the key and to let
+// users recognize it, the full key is only stored hashed.
+func GenerateAPIKey() (string, string) {
+	prefix := apiKeyPrefix + "_" + generateRandomString(apiKeyLookupLength)
+	return prefix + "_" + generateRandomString(apiKeySecretLength), prefix
+}
+
+// APIKeyPrefix returns the prefix of an API key, the second value reports whether the key is well formed
+func APIKeyPrefix(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || len(parts[1]) != apiKeyLookupLength || len(parts[2]) != apiKeySecretLength {
+		return "", false
+	}
+
+	return parts[0] + "_" + parts[1], true
+}
@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// maxCBORDepth bounds the nesting of decoded items, WebAuthn structures are only a few levels deep
+const maxCBORDepth = 16
+
+var errInvalidCBOR = errors.New("Invalid CBOR data")
+
+// DecodeCBOR decodes the first CBOR (RFC 8949) item of data and returns it with the remaining bytes.
+// It supports the subset used by WebAuthn: integers are decoded as int64, byte strings as []byte,
+// text strings as string, arrays as []interface{}, maps as map[interface{}]interface{} with int64 or
+// string keys, tags as their content and simple values as bool or nil. Indefinite lengths and floats
+// are not supported.
+func DecodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth || len(data) == 0 {
+		return nil, nil, errInvalidCBOR
+	}
+
+	majorType := data[0] >> 5
+	additional := data[0] & 0x1f
+
+	// Simple values are read before the argument, 20 to 22 are false, true and null
+	if majorType == 7 {
+		switch additional {
+		case 20:
+			return false, data[1:], nil
+		case 21:
+			return true, data[1:], nil
+		case 22:
+			return nil, data[1:], nil
+		default:
+			return nil, nil, errInvalidCBOR
+		}
+	}
+
+	argument, rest, err := decodeCBORArgument(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch majorType {
+	case 0:
+		if argument > math.MaxInt64 {
+			return nil, nil, errInvalidCBOR
+		}
+		return int64(argument), rest, nil
+	case 1:
+		if argument > math.MaxInt64 {
+			return nil, nil, errInvalidCBOR
+		}
+		return -1 - int64(argument), rest, nil
+	case 2, 3:
+		if argument > uint64(len(rest)) {
+			return nil, nil, errInvalidCBOR
+		}
+
+		value := rest[:argument]
+		if majorType == 3 {
+			return string(value), rest[argument:], nil
+		}
+
+		return append([]byte(nil), value...), rest[argument:], nil
+	case 4:
+		// Every item takes at least one byte
+		if argument > uint64(len(rest)) {
+			return nil, nil, errInvalidCBOR
+		}
+
+		items := make([]interface{}, 0, argument)
+		for i := uint64(0); i < argument; i++ {
+			var item interface{}
+			if item, rest, err = decodeCBORItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+
+		return items, rest, nil
+	case 5:
+		if argument > uint64(len(rest))/2 {
+			return nil, nil, errInvalidCBOR
+		}
+
+		items := make(map[interface{}]interface{}, argument)
+		for i := uint64(0); i < argument; i++ {
+			var key, value interface{}
+			if key, rest, err = decodeCBORItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errInvalidCBOR
+			}
+
+			if value, rest, err = decodeCBORItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+
+			if _, exists := items[key]; exists {
+				return nil, nil, errInvalidCBOR
+			}
+			items[key] = value
+		}
+
+		return items, rest, nil
+	case 6:
+		return decodeCBORItem(rest, depth+1)
+	}
+
+	return nil, nil, errInvalidCBOR
+}
+
+// decodeCBORArgument reads the argument of the initial byte, which is the value of an integer
+// or the length of a string, an array or a map
+func decodeCBORArgument(data []byte) (uint64, []byte, error) {
+	additional := data[0] & 0x1f
+	data = data[1:]
+
+	switch {
+	case additional < 24:
+		return uint64(additional), data, nil
+	case additional == 24 && len(data) >= 1:
+		return uint64(data[0]), data[1:], nil
+	case additional == 25 && len(data) >= 2:
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case additional == 26 && len(data) >= 4:
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case additional == 27 && len(data) >= 8:
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	}
+
+	return 0, nil, errInvalidCBOR
+}
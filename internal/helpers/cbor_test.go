@@ -0,0 +1,109 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	// Most inputs are the examples of RFC 8949 appendix A
+	tests := []struct {
+		name     string
+		data     string
+		want     interface{}
+		wantRest string
+	}{
+		{name: "zero", data: "00", want: int64(0)},
+		{name: "largest direct integer", data: "17", want: int64(23)},
+		{name: "one byte integer", data: "1818", want: int64(24)},
+		{name: "two byte integer", data: "1903e8", want: int64(1000)},
+		{name: "four byte integer", data: "1a000f4240", want: int64(1000000)},
+		{name: "eight byte integer", data: "1b000000e8d4a51000", want: int64(1000000000000)},
+		{name: "negative one", data: "20", want: int64(-1)},
+		{name: "negative integer", data: "3863", want: int64(-100)},
+		{name: "COSE RS256", data: "390100", want: int64(-257)},
+		{name: "empty byte string", data: "40", want: []byte(nil)},
+		{name: "byte string", data: "4401020304", want: []byte{1, 2, 3, 4}},
+		{name: "empty text string", data: "60", want: ""},
+		{name: "text string", data: "6449455446", want: "IETF"},
+		{name: "empty array", data: "80", want: []interface{}{}},
+		{name: "nested arrays", data: "8301820203820405", want: []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{name: "empty map", data: "a0", want: map[interface{}]interface{}{}},
+		{name: "integer keys", data: "a201020304", want: map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{name: "text keys", data: "a26161016162820203", want: map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{name: "false", data: "f4", want: false},
+		{name: "true", data: "f5", want: true},
+		{name: "null", data: "f6", want: nil},
+		{name: "tag is decoded as its content", data: "c11a514b67b0", want: int64(1363896240)},
+		{name: "remaining bytes are returned", data: "0102", want: int64(1), wantRest: "02"},
+		{name: "only the first item is decoded", data: "8101f5", want: []interface{}{int64(1)}, wantRest: "f5"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, rest, err := DecodeCBOR(mustDecodeHex(t, test.data))
+			if err != nil {
+				t.Fatalf("DecodeCBOR(%s) error = %v", test.data, err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("DecodeCBOR(%s) = %#v, want %#v", test.data, got, test.want)
+			}
+
+			if !bytes.Equal(rest, mustDecodeHex(t, test.wantRest)) {
+				t.Errorf("DecodeCBOR(%s) rest = %x, want %s", test.data, rest, test.wantRest)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "empty", data: ""},
+		{name: "integer above int64", data: "1bffffffffffffffff"},
+		{name: "negative integer below int64", data: "3bffffffffffffffff"},
+		{name: "truncated argument", data: "19ff"},
+		{name: "reserved additional information", data: "1c"},
+		{name: "indefinite length byte string", data: "5f42010243030405ff"},
+		{name: "truncated byte string", data: "440102"},
+		{name: "truncated text string", data: "644945"},
+		{name: "array longer than the data", data: "9bffffffffffffffff"},
+		{name: "truncated array", data: "830102"},
+		{name: "map longer than the data", data: "bbffffffffffffffff"},
+		{name: "truncated map", data: "a20102"},
+		{name: "array as map key", data: "a18001"},
+		{name: "duplicate map key", data: "a201020103"},
+		{name: "half precision float", data: "f93c00"},
+		{name: "undefined", data: "f7"},
+		{name: "nested too deep", data: strings.Repeat("81", maxCBORDepth+1) + "00"},
+		{name: "tags nested too deep", data: strings.Repeat("c1", maxCBORDepth+1) + "00"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, _, err := DecodeCBOR(mustDecodeHex(t, test.data)); err == nil {
+				t.Errorf("DecodeCBOR(%s) = %#v, want an error", test.data, got)
+			}
+		})
+	}
+
+	// The deepest accepted nesting still decodes
+	if _, _, err := DecodeCBOR(mustDecodeHex(t, strings.Repeat("81", maxCBORDepth)+"00")); err != nil {
+		t.Errorf("DecodeCBOR() of %d nested arrays error = %v", maxCBORDepth, err)
+	}
+}
+
+func mustDecodeHex(t *testing.T, value string) []byte {
+	data, err := hex.DecodeString(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+// GetClientInfo extracts the client IP and user agent from the request
+func GetClientInfo(ctx *fiber.Ctx) models.ClientInfo {
+	return models.ClientInfo{
+		IP:        ctx.IP(),
+		UserAgent: ctx.Get(fiber.HeaderUserAgent),
+	}
+}
+
+// DeviceFingerprint identifies the device and network of a client by hashing its user agent
+// with the /24 prefix of an IPv4 address or the /48 prefix of an IPv6 address
+func DeviceFingerprint(client models.ClientInfo) string {
+	network := client.IP
+	if ip := net.ParseIP(client.IP); ip != nil {
+		if ipv4 := ip.To4(); ipv4 != nil {
+			network = ipv4.Mask(net.CIDRMask(24, 32)).String()
+		} else {
+			network = ip.Mask(net.CIDRMask(48, 128)).String()
+		}
+	}
+
+	sum := sha256.Sum256([]byte(client.UserAgent + "|" + network))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseDevice returns a human readable device name such as "Chrome on macOS" from a user agent
+func ParseDevice(userAgent string) string {
+	var browser, os string
+
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		os = "Android"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		os = "iOS"
+	case strings.Contains(userAgent, "Windows"):
+		os = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		os = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		os = "Linux"
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	default:
+		return "Unknown device"
+	}
+}
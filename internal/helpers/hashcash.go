@@ -0,0 +1,39 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"math/bits"
+)
+
+const (
+	hashcashNonceLength = 32
+	// Longer counters are rejected without hashing them
+	maxHashcashCounterLength = 32
+)
+
+// GenerateHashcashNonce generates a random nonce for a proof-of-work challenge
+func GenerateHashcashNonce() string {
+	return generateRandomString(hashcashNonceLength)
+}
+
+// VerifyHashcash reports whether the SHA-256 hash of "<nonce>:<counter>" starts with at least
+// difficulty zero bits
+func VerifyHashcash(nonce string, counter string, difficulty int) bool {
+	if counter == "" || len(counter) > maxHashcashCounterLength {
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(nonce + ":" + counter))
+
+	zeroBits := 0
+	for _, b := range hash {
+		if b != 0 {
+			zeroBits += bits.LeadingZeros8(b)
+			break
+		}
+
+		zeroBits += 8
+	}
+
+	return zeroBits >= difficulty
+}
@@ -0,0 +1,88 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// leadingZeroBits counts the zero bits the hash of "<nonce>:<counter>" starts with
+func leadingZeroBits(nonce string, counter string) int {
+	hash := sha256.Sum256([]byte(nonce + ":" + counter))
+	return 256 - new(big.Int).SetBytes(hash[:]).BitLen()
+}
+
+// solveHashcash searches the first counter whose hash starts with difficulty zero bits, like a client does
+func solveHashcash(t *testing.T, nonce string, difficulty int) string {
+	for counter := 0; counter < 1<<24; counter++ {
+		if leadingZeroBits(nonce, strconv.Itoa(counter)) >= difficulty {
+			return strconv.Itoa(counter)
+		}
+	}
+
+	t.Fatalf("no counter found for difficulty %d", difficulty)
+	return ""
+}
+
+func TestVerifyHashcash(t *testing.T) {
+	const nonce = "0123456789abcdef0123456789abcdef"
+	const difficulty = 12
+
+	counter := solveHashcash(t, nonce, difficulty)
+	zeroBits := leadingZeroBits(nonce, counter)
+
+	tests := []struct {
+		name       string
+		nonce      string
+		counter    string
+		difficulty int
+		want       bool
+	}{
+		{name: "solved", nonce: nonce, counter: counter, difficulty: difficulty, want: true},
+		{name: "exactly the zero bits of the hash", nonce: nonce, counter: counter, difficulty: zeroBits, want: true},
+		{name: "one bit more than the hash has", nonce: nonce, counter: counter, difficulty: zeroBits + 1, want: false},
+		{name: "no difficulty", nonce: nonce, counter: "anything", difficulty: 0, want: true},
+		{name: "empty counter", nonce: nonce, counter: "", difficulty: 0, want: false},
+		{name: "longest counter", nonce: nonce, counter: strings.Repeat("1", maxHashcashCounterLength), difficulty: 0, want: true},
+		{name: "too long counter", nonce: nonce, counter: strings.Repeat("1", maxHashcashCounterLength+1), difficulty: 0, want: false},
+		{name: "more bits than a hash has", nonce: nonce, counter: counter, difficulty: 257, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := VerifyHashcash(test.nonce, test.counter, test.difficulty); got != test.want {
+				t.Errorf("VerifyHashcash(%q, %q, %d) = %v, want %v", test.nonce, test.counter, test.difficulty, got, test.want)
+			}
+		})
+	}
+}
+
+func TestVerifyHashcashCountsBitsAcrossBytes(t *testing.T) {
+	// Every difficulty up to the zero bits of a solution is accepted, the next one is not
+	nonce := GenerateHashcashNonce()
+	counter := solveHashcash(t, nonce, 9)
+	zeroBits := leadingZeroBits(nonce, counter)
+
+	for difficulty := 0; difficulty <= zeroBits; difficulty++ {
+		if !VerifyHashcash(nonce, counter, difficulty) {
+			t.Errorf("VerifyHashcash() with difficulty %d = false, the hash has %d zero bits", difficulty, zeroBits)
+		}
+	}
+
+	if VerifyHashcash(nonce, counter, zeroBits+1) {
+		t.Errorf("VerifyHashcash() with difficulty %d = true, the hash has %d zero bits", zeroBits+1, zeroBits)
+	}
+}
+
+func TestGenerateHashcashNonce(t *testing.T) {
+	nonce := GenerateHashcashNonce()
+	if len(nonce) != hashcashNonceLength {
+		t.Errorf("GenerateHashcashNonce() length = %d, want %d", len(nonce), hashcashNonceLength)
+	}
+
+	if GenerateHashcashNonce() == nonce {
+		t.Error("GenerateHashcashNonce() returned the same nonce twice")
+	}
+}
@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const jpegQuality = 85
+
+// SniffContentType detects the type of a file from its first bytes, the type a client declares is not trusted
+func SniffContentType(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// ResizeImage scales an image down to fit within maxSize x maxSize pixels, keeping its aspect ratio.
+// Smaller images are copied at their size. Every pixel of the result is the average of the pixels it
+// covers, which keeps thumbnails smooth without an external imaging library.
+func ResizeImage(src image.Image, maxSize int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	width, height := srcWidth, srcHeight
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			width, height = maxSize, max(1, srcHeight*maxSize/srcWidth)
+		} else {
+			width, height = max(1, srcWidth*maxSize/srcHeight), maxSize
+		}
+	}
+
+	// Work on premultiplied RGBA pixels so that averaging handles transparency correctly
+	rgba, ok := src.(*image.RGBA)
+	if !ok || rgba.Bounds().Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, srcWidth, srcHeight))
+		draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	}
+
+	if width == srcWidth && height == srcHeight {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := max(y0+1, (y+1)*srcHeight/height)
+
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := max(x0+1, (x+1)*srcWidth/width)
+
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				offset := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(rgba.Pix[offset])
+					g += uint64(rgba.Pix[offset+1])
+					b += uint64(rgba.Pix[offset+2])
+					a += uint64(rgba.Pix[offset+3])
+					offset += 4
+					count++
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / count)
+			dst.Pix[offset+1] = uint8(g / count)
+			dst.Pix[offset+2] = uint8(b / count)
+			dst.Pix[offset+3] = uint8(a / count)
+		}
+	}
+
+	return dst
+}
+
+// EncodeImage encodes an image as JPEG or PNG, other content types are not supported
+func EncodeImage(img image.Image, contentType string) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	switch contentType {
+	case "image/jpeg":
+		if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+	case "image/png":
+		if err := png.Encode(&buffer, img); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("Unsupported image type " + contentType)
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+package helpers
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func newUniformImage(width, height int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	return img
+}
+
+func TestResizeImage(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        image.Image
+		maxSize    int
+		wantWidth  int
+		wantHeight int
+	}{
+		{name: "landscape", src: image.NewRGBA(image.Rect(0, 0, 400, 200)), maxSize: 100, wantWidth: 100, wantHeight: 50},
+		{name: "portrait", src: image.NewRGBA(image.Rect(0, 0, 200, 400)), maxSize: 100, wantWidth: 50, wantHeight: 100},
+		{name: "square", src: image.NewRGBA(image.Rect(0, 0, 300, 300)), maxSize: 150, wantWidth: 150, wantHeight: 150},
+		{name: "smaller than the maximum", src: image.NewRGBA(image.Rect(0, 0, 80, 60)), maxSize: 100, wantWidth: 80, wantHeight: 60},
+		{name: "extreme aspect ratio keeps a pixel", src: image.NewRGBA(image.Rect(0, 0, 1000, 1)), maxSize: 100, wantWidth: 100, wantHeight: 1},
+		{name: "bounds not at the origin", src: image.NewRGBA(image.Rect(50, 50, 250, 150)), maxSize: 100, wantWidth: 100, wantHeight: 50},
+		{name: "paletted source", src: image.NewPaletted(image.Rect(0, 0, 64, 32), color.Palette{color.Black, color.White}), maxSize: 16, wantWidth: 16, wantHeight: 8},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resized := ResizeImage(test.src, test.maxSize)
+
+			bounds := resized.Bounds()
+			if bounds.Min != (image.Point{}) || bounds.Dx() != test.wantWidth || bounds.Dy() != test.wantHeight {
+				t.Errorf("ResizeImage() bounds = %v, want %dx%d at the origin", bounds, test.wantWidth, test.wantHeight)
+			}
+		})
+	}
+}
+
+func TestResizeImageAveragesPixels(t *testing.T) {
+	// Black and white columns average to grey
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if x%2 == 0 {
+				src.Set(x, y, color.RGBA{A: 255})
+			} else {
+				src.Set(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+			}
+		}
+	}
+
+	resized := ResizeImage(src, 2)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			if got, want := resized.RGBAAt(x, y), (color.RGBA{R: 127, G: 127, B: 127, A: 255}); got != want {
+				t.Errorf("ResizeImage() pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	// Transparent pixels do not darken the opaque ones
+	translucent := newUniformImage(2, 1, color.RGBA{})
+	translucent.Set(0, 0, color.RGBA{R: 255, A: 255})
+	if got, want := ResizeImage(translucent, 1).RGBAAt(0, 0), (color.RGBA{R: 127, A: 127}); got != want {
+		t.Errorf("ResizeImage() of a half transparent image = %v, want %v", got, want)
+	}
+}
+
+func TestEncodeImage(t *testing.T) {
+	img := newUniformImage(16, 8, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+
+	tests := []struct {
+		contentType string
+		decode      func(data []byte) (image.Image, error)
+		wantErr     bool
+	}{
+		{contentType: "image/jpeg", decode: func(data []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(data)) }},
+		{contentType: "image/png", decode: func(data []byte) (image.Image, error) { return png.Decode(bytes.NewReader(data)) }},
+		{contentType: "image/gif", wantErr: true},
+		{contentType: "image/webp", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.contentType, func(t *testing.T) {
+			data, err := EncodeImage(img, test.contentType)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("EncodeImage() succeeded, want an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("EncodeImage() error = %v", err)
+			}
+
+			if got := SniffContentType(data); got != test.contentType {
+				t.Errorf("SniffContentType() of the encoded image = %q, want %q", got, test.contentType)
+			}
+
+			decoded, err := test.decode(data)
+			if err != nil {
+				t.Fatalf("decoding the encoded image: %v", err)
+			}
+
+			if decoded.Bounds() != img.Bounds() {
+				t.Errorf("decoded bounds = %v, want %v", decoded.Bounds(), img.Bounds())
+			}
+		})
+	}
+}
+
+func TestSniffContentType(t *testing.T) {
+	img := newUniformImage(4, 4, color.White)
+
+	var jpegData, pngData, gifData bytes.Buffer
+	if err := jpeg.Encode(&jpegData, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.Encode(&gifData, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "jpeg", data: jpegData.Bytes(), want: "image/jpeg"},
+		{name: "png", data: pngData.Bytes(), want: "image/png"},
+		{name: "gif", data: gifData.Bytes(), want: "image/gif"},
+		{name: "html declared as an image", data: []byte("<html><script>alert(1)</script></html>"), want: "text/html; charset=utf-8"},
+		{name: "svg is not an image", data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), want: "text/plain; charset=utf-8"},
+		{name: "empty", data: nil, want: "text/plain; charset=utf-8"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := SniffContentType(test.data); got != test.want {
+				t.Errorf("SniffContentType() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
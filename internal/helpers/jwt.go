@@ -1,29 +1,80 @@
 package helpers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"github.com/golang-jwt/jwt"
 	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
-// GenerateJWT generates a JWT token
-func GenerateJWT(id primitive.ObjectID) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS512)
+const refreshTokenLength = 64
+
+// GenerateJWT generates a short-lived access token bound to a session, signed with the
+// current signing key of the keyring
+func GenerateJWT(id primitive.ObjectID, role models.Role, sessionId string) (string, error) {
+	token, _ := newJWT(id, role, sessionId, config.GetJWTConfig().Expiration*time.Minute)
+
+	return signJWT(token)
+}
+
+// GenerateImpersonationJWT generates an access token that lets an admin act as another user.
+// The "act" claim (RFC 8693) names the admin and the token is bound to the admin's own session,
+// so it stops working when that session is revoked. It returns the token and its expiry.
+func GenerateImpersonationJWT(id primitive.ObjectID, role models.Role, actorId primitive.ObjectID, sessionId string) (string, time.Time, error) {
+	token, expiresAt := newJWT(id, role, sessionId, config.GetJWTConfig().ImpersonationExpiration*time.Minute)
+
+	claims := token.Claims.(jwt.MapClaims)
+	claims["act"] = map[string]string{"sub": actorId.Hex()}
+
+	tokenString, err := signJWT(token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+func newJWT(id primitive.ObjectID, role models.Role, sessionId string, expiration time.Duration) (*jwt.Token, time.Time) {
+	signingKey := GetJWTKeyring().SigningKey()
+	token := jwt.New(signingKey.Method)
+	token.Header["kid"] = signingKey.ID
 	now := time.Now().UTC()
+	expiresAt := now.Add(expiration)
 
 	// Set claims
 	claims := token.Claims.(jwt.MapClaims)
-	claims["exp"] = now.Add(config.GetJWTConfig().Expiration * time.Hour).Unix()
+	claims["exp"] = expiresAt.Unix()
 	claims["iat"] = now.Unix()
 	claims["id"] = id
+	claims["role"] = role
+	claims["sid"] = sessionId
 	claims["authorized"] = true
 
+	return token, expiresAt
+}
+
+func signJWT(token *jwt.Token) (string, error) {
+	signingKey := GetJWTKeyring().SigningKey()
+
 	// Generate encoded token and send it as response.
-	tokenString, err := token.SignedString([]byte(config.GetJWTConfig().Secret))
+	tokenString, err := token.SignedString(signingKey.PrivateKey)
 	if err != nil {
 		return "", err
 	}
 
 	return tokenString, nil
 }
+
+// GenerateRefreshToken generates an opaque refresh token
+func GenerateRefreshToken() string {
+	return generateRandomString(refreshTokenLength)
+}
+
+// HashToken returns the hex encoded SHA-256 hash of a token, used as its storage key
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,214 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+const minRSAKeyBits = 2048
+
+// JWTKey is a key of the keyring. Keys without a private key can only verify tokens,
+// which lets a retired signing key keep validating tokens until they expire.
+type JWTKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey interface{}
+	PublicKey  interface{}
+}
+
+// JWTKeyring holds the key used to sign access tokens and every key accepted to verify them
+type JWTKeyring struct {
+	signingKey *JWTKey
+	keys       map[string]*JWTKey
+}
+
+var (
+	jwtKeyring     *JWTKeyring
+	jwtKeyringOnce sync.Once
+)
+
+// GetJWTKeyring returns the keyring loaded from JWT_KEYS_DIR. Every *.pem file is a key
+// whose id is the file name, the key named by JWT_SIGNING_KEY_ID signs new tokens.
+func GetJWTKeyring() *JWTKeyring {
+	jwtKeyringOnce.Do(func() {
+		keyring, err := loadJWTKeyring(config.GetJWTConfig().KeysDir, config.GetJWTConfig().SigningKeyID)
+		if err != nil {
+			// A throwaway key keeps development setups working, tokens do not survive a restart
+			if config.GetServerConfig().Environment != "development" {
+				log.Fatalf("JWT keyring error: %v", err)
+			}
+
+			log.Printf("JWT keyring error: %v, using an ephemeral signing key", err)
+			keyring = newEphemeralJWTKeyring()
+		}
+
+		jwtKeyring = keyring
+	})
+
+	return jwtKeyring
+}
+
+// SigningKey returns the key used to sign new tokens
+func (keyring *JWTKeyring) SigningKey() *JWTKey {
+	return keyring.signingKey
+}
+
+// VerificationKey returns the key with the given id
+func (keyring *JWTKeyring) VerificationKey(kid string) (*JWTKey, bool) {
+	key, ok := keyring.keys[kid]
+	return key, ok
+}
+
+// JSONWebKeys returns the public keys of the keyring in JWK format, sorted by id
+func (keyring *JWTKeyring) JSONWebKeys() []models.JSONWebKey {
+	ids := make([]string, 0, len(keyring.keys))
+	for id := range keyring.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := make([]models.JSONWebKey, 0, len(ids))
+	for _, id := range ids {
+		key := keyring.keys[id]
+		jwk := models.JSONWebKey{
+			Kid: key.ID,
+			Use: "sig",
+			Alg: key.Method.Alg(),
+		}
+
+		switch publicKey := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		}
+
+		jwks = append(jwks, jwk)
+	}
+
+	return jwks
+}
+
+func loadJWTKeyring(keysDir string, signingKeyID string) (*JWTKeyring, error) {
+	if keysDir == "" {
+		return nil, errors.New("JWT_KEYS_DIR is not set")
+	}
+
+	files, err := filepath.Glob(filepath.Join(keysDir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := &JWTKeyring{keys: make(map[string]*JWTKey)}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := parseJWTKey(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+
+		keyring.keys[id] = key
+	}
+
+	signingKey, ok := keyring.keys[signingKeyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found in %s", signingKeyID, keysDir)
+	}
+
+	if signingKey.PrivateKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", signingKeyID)
+	}
+
+	keyring.signingKey = signingKey
+	return keyring, nil
+}
+
+// parseJWTKey parses a PEM encoded RSA or Ed25519 private or public key
+func parseJWTKey(id string, data []byte) (*JWTKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &JWTKey{ID: id}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.PrivateKey, key.PublicKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.PublicKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.PrivateKey, key.PublicKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.PublicKey = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	if publicKey, ok := key.PublicKey.(*rsa.PublicKey); ok && publicKey.N.BitLen() < minRSAKeyBits {
+		return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+	}
+
+	return key, nil
+}
+
+func newEphemeralJWTKeyring() *JWTKeyring {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	key := &JWTKey{
+		ID:         "ephemeral-" + generateRandomString(8),
+		Method:     jwt.SigningMethodEdDSA,
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+	}
+
+	return &JWTKeyring{
+		signingKey: key,
+		keys:       map[string]*JWTKey{key.ID: key},
+	}
+}
@@ -0,0 +1,27 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+const (
+	oauthStateLength    = 32
+	oauthVerifierLength = 64 // RFC 7636 allows 43 to 128 characters
+)
+
+// GenerateOAuthState generates a random value for the OAuth state and OpenID Connect nonce parameters
+func GenerateOAuthState() string {
+	return generateRandomString(oauthStateLength)
+}
+
+// GenerateCodeVerifier generates a random PKCE code verifier
+func GenerateCodeVerifier() string {
+	return generateRandomString(oauthVerifierLength)
+}
+
+// CodeChallengeS256 derives the PKCE code challenge of a code verifier with the S256 method
+func CodeChallengeS256(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
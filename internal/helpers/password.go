@@ -1,9 +1,8 @@
 package helpers
 
 import (
-	"golang.org/x/crypto/bcrypt"
-	"math/rand"
-	"time"
+	"crypto/rand"
+	"math/big"
 )
 
 const (
@@ -11,29 +10,28 @@ const (
 	forgotPasswordTokenLength = 64
 )
 
-// HashPassword hashes a password
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	if err != nil {
-		return "", err
-	}
-
-	return string(bytes), nil
+// GenerateForgotPasswordToken generates a cryptographically secure random string
+func GenerateForgotPasswordToken() string {
+	return generateRandomString(forgotPasswordTokenLength)
 }
 
-// VerifyPassword compares a hashed password with a plain text password
-func VerifyPassword(hashedPassword, password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	return err == nil
+// generateRandomString generates a cryptographically secure random alphanumeric string
+func generateRandomString(length int) string {
+	return generateRandomStringFrom(letterBytes, length)
 }
 
-// GenerateForgotPasswordToken generates a random string
-func GenerateForgotPasswordToken() string {
-	random := rand.New(rand.NewSource(time.Now().UnixNano()))
-	bytes := make([]byte, forgotPasswordTokenLength)
+// generateRandomStringFrom generates a cryptographically secure random string using the given alphabet
+func generateRandomStringFrom(alphabet string, length int) string {
+	bytes := make([]byte, length)
+	max := big.NewInt(int64(len(alphabet)))
 
 	for i := range bytes {
-		bytes[i] = letterBytes[random.Intn(len(letterBytes))]
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+
+		bytes[i] = alphabet[n.Int64()]
 	}
 
 	return string(bytes)
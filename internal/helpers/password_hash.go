@@ -0,0 +1,148 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mercan/ecommerce/internal/config"
+)
+
+// Passwords are stored as self-describing hashes, so that the algorithm and its cost can change
+// without invalidating existing passwords:
+//
+//	argon2id: $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash> (PHC string format)
+//	bcrypt:   $2a$12$<salt and hash>
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var errInvalidPasswordHash = errors.New("invalid password hash")
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// HashPassword hashes a password with the configured algorithm and cost
+func HashPassword(password string) (string, error) {
+	passwordConfig := config.GetPasswordConfig()
+
+	switch passwordConfig.Algorithm {
+	case PasswordAlgorithmArgon2id:
+		return hashArgon2id(password, configuredArgon2Params())
+	case PasswordAlgorithmBcrypt:
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), passwordConfig.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+
+		return string(bytes), nil
+	default:
+		return "", fmt.Errorf("unsupported password hash algorithm %q", passwordConfig.Algorithm)
+	}
+}
+
+// VerifyPassword compares a hashed password with a plain text password, any supported algorithm is accepted
+func VerifyPassword(hashedPassword, password string) bool {
+	if strings.HasPrefix(hashedPassword, "$"+PasswordAlgorithmArgon2id+"$") {
+		params, salt, hash, err := decodeArgon2id(hashedPassword)
+		if err != nil {
+			return false
+		}
+
+		otherHash := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(hash)))
+		return subtle.ConstantTimeCompare(hash, otherHash) == 1
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	return err == nil
+}
+
+// PasswordNeedsRehash reports whether a hash was made with another algorithm or cost than configured
+func PasswordNeedsRehash(hashedPassword string) bool {
+	passwordConfig := config.GetPasswordConfig()
+
+	switch passwordConfig.Algorithm {
+	case PasswordAlgorithmArgon2id:
+		params, _, _, err := decodeArgon2id(hashedPassword)
+		return err != nil || params != configuredArgon2Params()
+	case PasswordAlgorithmBcrypt:
+		cost, err := bcrypt.Cost([]byte(hashedPassword))
+		return err != nil || cost != passwordConfig.BcryptCost
+	default:
+		return false
+	}
+}
+
+func configuredArgon2Params() argon2Params {
+	passwordConfig := config.GetPasswordConfig()
+
+	return argon2Params{
+		memory:      passwordConfig.Argon2Memory,
+		iterations:  passwordConfig.Argon2Iterations,
+		parallelism: passwordConfig.Argon2Parallelism,
+	}
+}
+
+func hashArgon2id(password string, params argon2Params) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		PasswordAlgorithmArgon2id,
+		argon2.Version,
+		params.memory,
+		params.iterations,
+		params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
+}
+
+func decodeArgon2id(hashedPassword string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != PasswordAlgorithmArgon2id {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	// argon2 panics without iterations or parallelism
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil ||
+		params.iterations == 0 || params.parallelism == 0 {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash) == 0 {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	return params, salt, hash, nil
+}
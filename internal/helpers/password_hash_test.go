@@ -0,0 +1,162 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mercan/ecommerce/internal/config"
+)
+
+// cheapArgon2Params keeps the hashes of the tests fast, the configured ones take tens of milliseconds
+var cheapArgon2Params = argon2Params{memory: 64, iterations: 1, parallelism: 1}
+
+func TestHashPassword(t *testing.T) {
+	passwordConfig := config.GetPasswordConfig()
+	if passwordConfig.Algorithm != PasswordAlgorithmArgon2id {
+		t.Skipf("PASSWORD_HASH_ALGORITHM is %q", passwordConfig.Algorithm)
+	}
+
+	hash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("HashPassword() error = %v", err)
+	}
+
+	prefix := fmt.Sprintf("$argon2id$v=19$m=%d,t=%d,p=%d$",
+		passwordConfig.Argon2Memory, passwordConfig.Argon2Iterations, passwordConfig.Argon2Parallelism)
+	if !strings.HasPrefix(hash, prefix) {
+		t.Errorf("HashPassword() = %q, want the prefix %q", hash, prefix)
+	}
+
+	if !VerifyPassword(hash, "correct horse battery staple") {
+		t.Error("VerifyPassword() of the hashed password = false")
+	}
+
+	if PasswordNeedsRehash(hash) {
+		t.Error("PasswordNeedsRehash() of a hash with the configured parameters = true")
+	}
+
+	otherHash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if otherHash == hash {
+		t.Error("HashPassword() returned the same hash twice, the salt is not random")
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	const password = "correct horse battery staple"
+
+	argon2Hash, err := hashArgon2id(password, cheapArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaces one part of the argon2id hash, parts are separated by "$"
+	withPart := func(index int, value string) string {
+		parts := strings.Split(argon2Hash, "$")
+		parts[index] = value
+		return strings.Join(parts, "$")
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+	}{
+		{name: "argon2id", hash: argon2Hash, password: password, want: true},
+		{name: "bcrypt", hash: string(bcryptHash), password: password, want: true},
+		{name: "argon2id wrong password", hash: argon2Hash, password: "Correct horse battery staple"},
+		{name: "bcrypt wrong password", hash: string(bcryptHash), password: "Correct horse battery staple"},
+		{name: "argon2id empty password", hash: argon2Hash, password: ""},
+		{name: "other version", hash: withPart(2, "v=16"), password: password},
+		{name: "other parameters", hash: withPart(3, "m=64,t=2,p=1"), password: password},
+		{name: "no iterations", hash: withPart(3, "m=64,t=0,p=1"), password: password},
+		{name: "no parallelism", hash: withPart(3, "m=64,t=1,p=0"), password: password},
+		{name: "malformed parameters", hash: withPart(3, "m=64"), password: password},
+		{name: "invalid salt", hash: withPart(4, "not base64!"), password: password},
+		{name: "invalid hash", hash: withPart(5, "not base64!"), password: password},
+		{name: "empty hash", hash: withPart(5, ""), password: password},
+		{name: "missing part", hash: strings.TrimSuffix(argon2Hash, "$"+strings.Split(argon2Hash, "$")[5]), password: password},
+		{name: "empty", hash: "", password: password},
+		{name: "plain text", hash: password, password: password},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := VerifyPassword(test.hash, test.password); got != test.want {
+				t.Errorf("VerifyPassword(%q) = %v, want %v", test.hash, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeArgon2id(t *testing.T) {
+	hash, err := hashArgon2id("password", argon2Params{memory: 128, iterations: 3, parallelism: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		t.Fatalf("decodeArgon2id(%q) error = %v", hash, err)
+	}
+
+	if want := (argon2Params{memory: 128, iterations: 3, parallelism: 2}); params != want {
+		t.Errorf("decodeArgon2id() parameters = %+v, want %+v", params, want)
+	}
+
+	if len(salt) != argon2SaltLength || len(key) != argon2KeyLength {
+		t.Errorf("decodeArgon2id() salt and key lengths = %d, %d, want %d, %d", len(salt), len(key), argon2SaltLength, argon2KeyLength)
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	if config.GetPasswordConfig().Algorithm != PasswordAlgorithmArgon2id {
+		t.Skipf("PASSWORD_HASH_ALGORITHM is %q", config.GetPasswordConfig().Algorithm)
+	}
+
+	configuredHash, err := hashArgon2id("password", configuredArgon2Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cheapHash, err := hashArgon2id("password", cheapArgon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{name: "configured parameters", hash: configuredHash, want: false},
+		{name: "other parameters", hash: cheapHash, want: true},
+		{name: "other algorithm", hash: string(bcryptHash), want: true},
+		{name: "invalid hash", hash: "invalid", want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := PasswordNeedsRehash(test.hash); got != test.want {
+				t.Errorf("PasswordNeedsRehash(%q) = %v, want %v", test.hash, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package helpers
+
+import (
+	"strings"
+	"unicode"
+)
+
+const maxSlugLength = 80
+
+// slugReplacer transliterates the Turkish letters, other letters outside of ASCII are dropped
+var slugReplacer = strings.NewReplacer(
+	"ç", "c", "Ç", "c",
+	"ğ", "g", "Ğ", "g",
+	"ı", "i", "İ", "i",
+	"ö", "o", "Ö", "o",
+	"ş", "s", "Ş", "s",
+	"ü", "u", "Ü", "u",
+)
+
+// Slugify turns a title into a lowercase URL path segment of ASCII letters, digits and hyphens
+func Slugify(title string) string {
+	var slug strings.Builder
+	hyphen := false
+
+	for _, r := range strings.ToLower(slugReplacer.Replace(title)) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			slug.WriteRune(r)
+			hyphen = false
+		case !hyphen && slug.Len() > 0:
+			slug.WriteByte('-')
+			hyphen = true
+		}
+
+		if slug.Len() >= maxSlugLength {
+			break
+		}
+	}
+
+	return strings.TrimRight(slug.String(), "-")
+}
+
+const (
+	slugSuffixAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	slugSuffixLength   = 6
+)
+
+// SlugWithSuffix appends a random suffix to a slug that is already taken
+func SlugWithSuffix(slug string) string {
+	return slug + "-" + generateRandomStringFrom(slugSuffixAlphabet, slugSuffixLength)
+}
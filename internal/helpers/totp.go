@@ -0,0 +1,114 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod             = 30
+	totpDigits             = 6
+	totpSkew               = 1 // accepted steps before and after the current one
+	totpSecretLength       = 20
+	recoveryCodeAlphabet   = "abcdefghijklmnopqrstuvwxyz0123456789"
+	recoveryCodeHalfLength = 5
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret generates a random base32 encoded TOTP secret
+func GenerateTOTPSecret() string {
+	bytes := make([]byte, totpSecretLength)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+
+	return totpEncoding.EncodeToString(bytes)
+}
+
+// TOTPURI returns the otpauth URI authenticator apps use to enroll the secret
+func TOTPURI(issuer, accountName, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+accountName) + "?" + query.Encode()
+}
+
+// GenerateTOTPCode returns the RFC 6238 code of the secret for the given time
+func GenerateTOTPCode(secret string, t time.Time) (string, error) {
+	return generateTOTPCodeAt(secret, t.Unix()/totpPeriod)
+}
+
+// ValidateTOTPCode checks a code against the steps around t and returns the matched time step,
+// which callers use to reject a code being replayed within its validity window
+func ValidateTOTPCode(secret, code string, t time.Time) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := t.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := generateTOTPCodeAt(secret, step)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+func generateTOTPCodeAt(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// GenerateRecoveryCodes generates one-time recovery codes formatted as "xxxxx-xxxxx"
+func GenerateRecoveryCodes(count int) []string {
+	codes := make([]string, count)
+	for i := range codes {
+		codes[i] = generateRandomStringFrom(recoveryCodeAlphabet, recoveryCodeHalfLength) + "-" +
+			generateRandomStringFrom(recoveryCodeAlphabet, recoveryCodeHalfLength)
+	}
+
+	return codes
+}
+
+// HashRecoveryCode normalizes a recovery code as typed by the user and hashes it for storage
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+
+	return HashToken(code)
+}
@@ -0,0 +1,160 @@
+package helpers
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCode(t *testing.T) {
+	// The last six digits of the eight digit codes of RFC 6238 appendix B
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, test := range tests {
+		got, err := GenerateTOTPCode(rfc6238Secret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatalf("GenerateTOTPCode(%d) error = %v", test.unix, err)
+		}
+
+		if got != test.want {
+			t.Errorf("GenerateTOTPCode(%d) = %q, want %q", test.unix, got, test.want)
+		}
+	}
+
+	if got, err := GenerateTOTPCode(strings.ToLower(rfc6238Secret), time.Unix(59, 0)); err != nil || got != "287082" {
+		t.Errorf("GenerateTOTPCode() with a lowercase secret = %q, %v, want %q", got, err, "287082")
+	}
+
+	if _, err := GenerateTOTPCode("not base32!", time.Unix(59, 0)); err == nil {
+		t.Error("GenerateTOTPCode() with an invalid secret succeeded")
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+
+	code := func(offset int64) string {
+		code, err := generateTOTPCodeAt(rfc6238Secret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", secret: rfc6238Secret, code: code(0), wantStep: step, wantOK: true},
+		{name: "previous step", secret: rfc6238Secret, code: code(-1), wantStep: step - 1, wantOK: true},
+		{name: "next step", secret: rfc6238Secret, code: code(1), wantStep: step + 1, wantOK: true},
+		{name: "two steps ago", secret: rfc6238Secret, code: code(-2)},
+		{name: "two steps ahead", secret: rfc6238Secret, code: code(2)},
+		{name: "wrong code", secret: rfc6238Secret, code: "000000"},
+		{name: "too short", secret: rfc6238Secret, code: code(0)[:5]},
+		{name: "too long", secret: rfc6238Secret, code: code(0) + "0"},
+		{name: "other secret", secret: "JBSWY3DPEHPK3PXP", code: code(0)},
+		{name: "invalid secret", secret: "not base32!", code: code(0)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotStep, gotOK := ValidateTOTPCode(test.secret, test.code, now)
+			if gotOK != test.wantOK || gotStep != test.wantStep {
+				t.Errorf("ValidateTOTPCode() = %d, %v, want %d, %v", gotStep, gotOK, test.wantStep, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret := GenerateTOTPSecret()
+
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() = %q is not base32: %v", secret, err)
+	}
+
+	if len(key) != totpSecretLength {
+		t.Errorf("GenerateTOTPSecret() key length = %d, want %d", len(key), totpSecretLength)
+	}
+
+	if GenerateTOTPSecret() == secret {
+		t.Error("GenerateTOTPSecret() returned the same secret twice")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Shop & Co", "ada@example.com", rfc6238Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Shop & Co:ada@example.com" {
+		t.Errorf("TOTPURI() = %q, want an otpauth://totp/ URI labelled with the issuer and account", uri)
+	}
+
+	want := map[string]string{
+		"secret":    rfc6238Secret,
+		"issuer":    "Shop & Co",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	}
+	for key, value := range want {
+		if got := uri.Query().Get(key); got != value {
+			t.Errorf("TOTPURI() %s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := GenerateRecoveryCodes(10)
+	if len(codes) != 10 {
+		t.Fatalf("GenerateRecoveryCodes(10) returned %d codes", len(codes))
+	}
+
+	format := regexp.MustCompile(`^[a-z0-9]{5}-[a-z0-9]{5}$`)
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("recovery code %q does not match %s", code, format)
+		}
+
+		if seen[code] {
+			t.Errorf("recovery code %q was generated twice", code)
+		}
+		seen[code] = true
+	}
+
+	// Codes are accepted as typed, with or without the dash, spaces and in any case
+	hash := HashRecoveryCode("abcde-12345")
+	for _, typed := range []string{"abcde12345", " ABCDE-12345 ", "abcde 12345", "AbCdE-1234-5"} {
+		if got := HashRecoveryCode(typed); got != hash {
+			t.Errorf("HashRecoveryCode(%q) differs from the hash of abcde-12345", typed)
+		}
+	}
+
+	if HashRecoveryCode("abcde-12346") == hash {
+		t.Error("HashRecoveryCode() of another code matched")
+	}
+}
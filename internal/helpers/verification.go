@@ -1,15 +1,11 @@
 package helpers
 
-import (
-	"math/rand"
-	"strconv"
-	"time"
+const (
+	digitBytes             = "0123456789"
+	verificationCodeLength = 6
 )
 
-// GenerateVerificationCode generates a random 6 digit number
+// GenerateVerificationCode generates a cryptographically secure random 6 digit code
 func GenerateVerificationCode() string {
-	random := rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	// Generate a random number between 100000 and 999999 (6 digits) and convert it to string
-	return strconv.Itoa(random.Intn(999999) + 100000)
+	return generateRandomStringFrom(digitBytes, verificationCodeLength)
 }
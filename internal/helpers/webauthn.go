@@ -0,0 +1,357 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// The spec asks for at least 16 random bytes
+const webAuthnChallengeLength = 32
+
+// Flags of the authenticator data
+const (
+	WebAuthnFlagUserPresent            byte = 0x01
+	WebAuthnFlagUserVerified           byte = 0x04
+	WebAuthnFlagBackupEligible         byte = 0x08
+	WebAuthnFlagBackedUp               byte = 0x10
+	WebAuthnFlagAttestedCredentialData byte = 0x40
+	WebAuthnFlagExtensionData          byte = 0x80
+)
+
+// COSE algorithms of credential public keys
+const (
+	COSEAlgorithmES256 int64 = -7
+	COSEAlgorithmEdDSA int64 = -8
+	COSEAlgorithmRS256 int64 = -257
+)
+
+// WebAuthnAlgorithms are the supported credential algorithms in order of preference
+var WebAuthnAlgorithms = []int64{COSEAlgorithmEdDSA, COSEAlgorithmES256, COSEAlgorithmRS256}
+
+// COSE key parameters (RFC 9053)
+const (
+	coseKeyType      int64 = 1
+	coseAlgorithm    int64 = 3
+	coseCurve        int64 = -1
+	coseX            int64 = -2
+	coseY            int64 = -3
+	coseRSAModulus   int64 = -1
+	coseRSAExponent  int64 = -2
+	coseKeyTypeOKP   int64 = 1
+	coseKeyTypeEC2   int64 = 2
+	coseKeyTypeRSA   int64 = 3
+	coseCurveP256    int64 = 1
+	coseCurveEd25519 int64 = 6
+)
+
+var errInvalidAuthenticatorData = errors.New("Invalid authenticator data")
+
+// AuthenticatorData is the data signed by an authenticator. The attested credential
+// is only present in registrations, CredentialPublicKey is a COSE key.
+type AuthenticatorData struct {
+	Raw                 []byte
+	RPIDHash            []byte
+	Flags               byte
+	SignCount           uint32
+	AAGUID              []byte
+	CredentialID        []byte
+	CredentialPublicKey []byte
+}
+
+func (data *AuthenticatorData) HasFlag(flag byte) bool {
+	return data.Flags&flag == flag
+}
+
+// MatchesRPID reports whether the authenticator data was made for the relying party
+func (data *AuthenticatorData) MatchesRPID(rpId string) bool {
+	hash := sha256.Sum256([]byte(rpId))
+	return bytes.Equal(data.RPIDHash, hash[:])
+}
+
+// WebAuthnClientData is the client data JSON that the browser passes to the authenticator
+type WebAuthnClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// AttestationObject is the CBOR encoded result of a registration
+type AttestationObject struct {
+	Format    string
+	Statement map[interface{}]interface{}
+	AuthData  *AuthenticatorData
+}
+
+// DecodeBase64URL decodes base64url with or without padding, as browsers and libraries differ
+func DecodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func EncodeBase64URL(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// ParseAuthenticatorData parses the authenticator data of a registration or an assertion
+func ParseAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, errInvalidAuthenticatorData
+	}
+
+	data := &AuthenticatorData{
+		Raw:       raw,
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rest := raw[37:]
+
+	if data.HasFlag(WebAuthnFlagAttestedCredentialData) {
+		if len(rest) < 18 {
+			return nil, errInvalidAuthenticatorData
+		}
+
+		data.AAGUID = rest[:16]
+		credentialIDLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if credentialIDLength > 1023 || len(rest) < credentialIDLength {
+			return nil, errInvalidAuthenticatorData
+		}
+
+		data.CredentialID = rest[:credentialIDLength]
+		rest = rest[credentialIDLength:]
+
+		// The key is followed by the extensions, its length is only known once decoded
+		_, remaining, err := DecodeCBOR(rest)
+		if err != nil {
+			return nil, errInvalidAuthenticatorData
+		}
+
+		data.CredentialPublicKey = rest[:len(rest)-len(remaining)]
+		rest = remaining
+	}
+
+	if data.HasFlag(WebAuthnFlagExtensionData) {
+		var err error
+		if _, rest, err = DecodeCBOR(rest); err != nil {
+			return nil, errInvalidAuthenticatorData
+		}
+	}
+
+	if len(rest) != 0 {
+		return nil, errInvalidAuthenticatorData
+	}
+
+	return data, nil
+}
+
+// ParseAttestationObject decodes an attestation object and its authenticator data
+func ParseAttestationObject(raw []byte) (*AttestationObject, error) {
+	decoded, rest, err := DecodeCBOR(raw)
+	if err != nil || len(rest) != 0 {
+		return nil, errors.New("Invalid attestation object")
+	}
+
+	object, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("Invalid attestation object")
+	}
+
+	format, _ := object["fmt"].(string)
+	statement, _ := object["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := object["authData"].([]byte)
+	if format == "" || statement == nil || rawAuthData == nil {
+		return nil, errors.New("Invalid attestation object")
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AttestationObject{
+		Format:    format,
+		Statement: statement,
+		AuthData:  authData,
+	}, nil
+}
+
+// VerifyAttestation verifies the attestation statement over the authenticator data and the client
+// data hash. The "none" and "packed" formats are supported. A packed certificate is only checked to
+// have made the signature, it is not chained to a trust anchor since only the key itself is trusted.
+func VerifyAttestation(attestation *AttestationObject, clientDataHash []byte) error {
+	signedData := append(append([]byte(nil), attestation.AuthData.Raw...), clientDataHash...)
+
+	switch attestation.Format {
+	case "none":
+		if len(attestation.Statement) != 0 {
+			return errors.New("Invalid attestation statement")
+		}
+
+		return nil
+	case "packed":
+		alg, _ := attestation.Statement["alg"].(int64)
+		signature, _ := attestation.Statement["sig"].([]byte)
+		if signature == nil {
+			return errors.New("Invalid attestation statement")
+		}
+
+		if chain, ok := attestation.Statement["x5c"].([]interface{}); ok {
+			if len(chain) == 0 {
+				return errors.New("Invalid attestation statement")
+			}
+
+			rawCertificate, _ := chain[0].([]byte)
+			certificate, err := x509.ParseCertificate(rawCertificate)
+			if err != nil {
+				return errors.New("Invalid attestation certificate")
+			}
+
+			signatureAlgorithm, ok := x509SignatureAlgorithms[alg]
+			if !ok {
+				return errors.New("Unsupported attestation algorithm")
+			}
+
+			if err := certificate.CheckSignature(signatureAlgorithm, signedData, signature); err != nil {
+				return errors.New("Invalid attestation signature")
+			}
+
+			return nil
+		}
+
+		// Self attestation is signed with the credential key itself
+		_, keyAlg, err := ParseCOSEKey(attestation.AuthData.CredentialPublicKey)
+		if err != nil {
+			return err
+		}
+
+		if alg != keyAlg {
+			return errors.New("Invalid attestation statement")
+		}
+
+		return VerifyCOSESignature(attestation.AuthData.CredentialPublicKey, signedData, signature)
+	}
+
+	return errors.New("Unsupported attestation format")
+}
+
+var x509SignatureAlgorithms = map[int64]x509.SignatureAlgorithm{
+	COSEAlgorithmES256: x509.ECDSAWithSHA256,
+	COSEAlgorithmEdDSA: x509.PureEd25519,
+	COSEAlgorithmRS256: x509.SHA256WithRSA,
+}
+
+// ParseCOSEKey returns the public key and the algorithm of a COSE encoded credential key
+func ParseCOSEKey(raw []byte) (crypto.PublicKey, int64, error) {
+	errInvalidKey := errors.New("Invalid credential public key")
+
+	decoded, rest, err := DecodeCBOR(raw)
+	if err != nil || len(rest) != 0 {
+		return nil, 0, errInvalidKey
+	}
+
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errInvalidKey
+	}
+
+	keyType, _ := key[coseKeyType].(int64)
+	alg, _ := key[coseAlgorithm].(int64)
+
+	switch {
+	case keyType == coseKeyTypeEC2 && alg == COSEAlgorithmES256:
+		curve, _ := key[coseCurve].(int64)
+		x, _ := key[coseX].([]byte)
+		y, _ := key[coseY].([]byte)
+		if curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errInvalidKey
+		}
+
+		// Rejects points that are not on the curve
+		point := append(append([]byte{0x04}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, 0, errInvalidKey
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, alg, nil
+	case keyType == coseKeyTypeOKP && alg == COSEAlgorithmEdDSA:
+		curve, _ := key[coseCurve].(int64)
+		x, _ := key[coseX].([]byte)
+		if curve != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errInvalidKey
+		}
+
+		return ed25519.PublicKey(x), alg, nil
+	case keyType == coseKeyTypeRSA && alg == COSEAlgorithmRS256:
+		modulus, _ := key[coseRSAModulus].([]byte)
+		exponent, _ := key[coseRSAExponent].([]byte)
+		if len(modulus) < 256 || len(exponent) == 0 || len(exponent) > 4 {
+			return nil, 0, errInvalidKey
+		}
+
+		e := 0
+		for _, b := range exponent {
+			e = e<<8 | int(b)
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(modulus),
+			E: e,
+		}, alg, nil
+	}
+
+	return nil, 0, errors.New("Unsupported credential algorithm")
+}
+
+// VerifyCOSESignature verifies a signature made with the private key of a COSE encoded public key
+func VerifyCOSESignature(coseKey []byte, data []byte, signature []byte) error {
+	publicKey, alg, err := ParseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	digest := sha256.Sum256(data)
+
+	switch alg {
+	case COSEAlgorithmES256:
+		valid = ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], signature)
+	case COSEAlgorithmEdDSA:
+		valid = ed25519.Verify(publicKey.(ed25519.PublicKey), data, signature)
+	case COSEAlgorithmRS256:
+		valid = rsa.VerifyPKCS1v15(publicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	}
+
+	if !valid {
+		return errors.New("Invalid signature")
+	}
+
+	return nil
+}
+
+// GenerateWebAuthnChallenge generates a random base64url encoded challenge for a ceremony
+func GenerateWebAuthnChallenge() string {
+	challenge := make([]byte, webAuthnChallengeLength)
+	if _, err := rand.Read(challenge); err != nil {
+		panic(err)
+	}
+
+	return EncodeBase64URL(challenge)
+}
@@ -0,0 +1,263 @@
+package helpers
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"testing"
+)
+
+// coseParam is a parameter of a COSE key, values are int64 or []byte
+type coseParam struct {
+	label int64
+	value interface{}
+}
+
+// encodeCOSEKey encodes the parameters as a CBOR map in the given order, which lets tests build
+// keys that are invalid
+func encodeCOSEKey(params ...coseParam) []byte {
+	data := cborHead(5, uint64(len(params)))
+	for _, param := range params {
+		data = append(data, encodeCBORInt(param.label)...)
+
+		switch value := param.value.(type) {
+		case int64:
+			data = append(data, encodeCBORInt(value)...)
+		case []byte:
+			data = append(append(data, cborHead(2, uint64(len(value)))...), value...)
+		}
+	}
+
+	return data
+}
+
+func encodeCBORInt(value int64) []byte {
+	if value < 0 {
+		return cborHead(1, uint64(-1-value))
+	}
+
+	return cborHead(0, uint64(value))
+}
+
+func cborHead(majorType byte, argument uint64) []byte {
+	switch {
+	case argument < 24:
+		return []byte{majorType<<5 | byte(argument)}
+	case argument <= 0xff:
+		return []byte{majorType<<5 | 24, byte(argument)}
+	case argument <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{majorType<<5 | 25}, uint16(argument))
+	default:
+		return binary.BigEndian.AppendUint32([]byte{majorType<<5 | 26}, uint32(argument))
+	}
+}
+
+func fixedBytes(value *big.Int, size int) []byte {
+	return value.FillBytes(make([]byte, size))
+}
+
+func TestVerifyCOSESignature(t *testing.T) {
+	data := []byte("authenticator data and client data hash")
+	digest := sha256.Sum256(data)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecSignature, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecCOSEKey := encodeCOSEKey(
+		coseParam{coseKeyType, coseKeyTypeEC2},
+		coseParam{coseAlgorithm, COSEAlgorithmES256},
+		coseParam{coseCurve, coseCurveP256},
+		coseParam{coseX, fixedBytes(ecKey.X, 32)},
+		coseParam{coseY, fixedBytes(ecKey.Y, 32)},
+	)
+
+	edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edSignature := ed25519.Sign(edPrivateKey, data)
+	edCOSEKey := encodeCOSEKey(
+		coseParam{coseKeyType, coseKeyTypeOKP},
+		coseParam{coseAlgorithm, COSEAlgorithmEdDSA},
+		coseParam{coseCurve, coseCurveEd25519},
+		coseParam{coseX, []byte(edPublicKey)},
+	)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSignature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaCOSEKey := encodeCOSEKey(
+		coseParam{coseKeyType, coseKeyTypeRSA},
+		coseParam{coseAlgorithm, COSEAlgorithmRS256},
+		coseParam{coseRSAModulus, rsaKey.N.Bytes()},
+		coseParam{coseRSAExponent, big.NewInt(int64(rsaKey.E)).Bytes()},
+	)
+
+	tamper := func(signature []byte) []byte {
+		tampered := append([]byte(nil), signature...)
+		tampered[len(tampered)/2] ^= 0x01
+		return tampered
+	}
+
+	tests := []struct {
+		name      string
+		key       []byte
+		data      []byte
+		signature []byte
+		wantErr   bool
+	}{
+		{name: "ES256", key: ecCOSEKey, data: data, signature: ecSignature},
+		{name: "EdDSA", key: edCOSEKey, data: data, signature: edSignature},
+		{name: "RS256", key: rsaCOSEKey, data: data, signature: rsaSignature},
+		{name: "ES256 other data", key: ecCOSEKey, data: []byte("other data"), signature: ecSignature, wantErr: true},
+		{name: "EdDSA other data", key: edCOSEKey, data: []byte("other data"), signature: edSignature, wantErr: true},
+		{name: "RS256 other data", key: rsaCOSEKey, data: []byte("other data"), signature: rsaSignature, wantErr: true},
+		{name: "ES256 tampered signature", key: ecCOSEKey, data: data, signature: tamper(ecSignature), wantErr: true},
+		{name: "EdDSA tampered signature", key: edCOSEKey, data: data, signature: tamper(edSignature), wantErr: true},
+		{name: "RS256 tampered signature", key: rsaCOSEKey, data: data, signature: tamper(rsaSignature), wantErr: true},
+		{name: "signature of another key", key: ecCOSEKey, data: data, signature: edSignature, wantErr: true},
+		{name: "empty signature", key: ecCOSEKey, data: data, signature: nil, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyCOSESignature(test.key, test.data, test.signature)
+			if (err != nil) != test.wantErr {
+				t.Errorf("VerifyCOSESignature() error = %v, want an error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseCOSEKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, y := fixedBytes(ecKey.X, 32), fixedBytes(ecKey.Y, 32)
+
+	edPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A point that is not on the curve
+	offCurveY := fixedBytes(new(big.Int).Add(ecKey.Y, big.NewInt(1)), 32)
+
+	tests := []struct {
+		name    string
+		key     []byte
+		wantAlg int64
+		wantErr bool
+	}{
+		{
+			name: "ES256",
+			key: encodeCOSEKey(coseParam{coseKeyType, coseKeyTypeEC2}, coseParam{coseAlgorithm, COSEAlgorithmES256},
+				coseParam{coseCurve, coseCurveP256}, coseParam{coseX, x}, coseParam{coseY, y}),
+			wantAlg: COSEAlgorithmES256,
+		},
+		{
+			name: "EdDSA",
+			key: encodeCOSEKey(coseParam{coseKeyType, coseKeyTypeOKP}, coseParam{coseAlgorithm, COSEAlgorithmEdDSA},
+				coseParam{coseCurve, coseCurveEd25519}, coseParam{coseX, []byte(edPublicKey)}),
+			wantAlg: COSEAlgorithmEdDSA,
+		},
+		{
+			name: "point not on the curve",
+			key: encodeCOSEKey(coseParam{coseKeyType, coseKeyTypeEC2}, coseParam{coseAlgorithm, COSEAlgorithmES256},
+				coseParam{coseCurve, coseCurveP256}, coseParam{coseX, x}, coseParam{coseY, offCurveY}),
+			wantErr: true,
+		},
+		{
+			name: "other curve",
+			key: encodeCOSEKey(coseParam{coseKeyType, coseKeyTypeEC2}, coseParam{coseAlgorithm, COSEAlgorithmES256},
+				coseParam{coseCurve, int64(2)}, coseParam{coseX, x}, coseParam{coseY, y}),
+			wantErr: true,
+		},
+		{
+			name: "short coordinate",
+			key: encodeCOSEKey(coseParam{coseKeyType, coseKeyTypeEC2}, coseParam{coseAlgorithm, COSEAlgorithmES256},
+				coseParam{coseCurve, coseCurveP256}, coseParam{coseX, x[1:]}, coseParam{coseY, y}),
+			wantErr: true,
+		},
+		{
+			name: "missing coordinate",
+			key: encodeCOSEKey(coseParam{coseKeyType, coseKeyTypeEC2}, coseParam{coseAlgorithm, COSEAlgorithmES256},
+				coseParam{coseCurve, coseCurveP256}, coseParam{coseX, x}),
+			wantErr: true,
+		},
+		{
+			name: "algorithm of another key type",
+			key: encodeCOSEKey(coseParam{coseKeyType, coseKeyTypeOKP}, coseParam{coseAlgorithm, COSEAlgorithmES256},
+				coseParam{coseCurve, coseCurveEd25519}, coseParam{coseX, []byte(edPublicKey)}),
+			wantErr: true,
+		},
+		{
+			name: "short Ed25519 key",
+			key: encodeCOSEKey(coseParam{coseKeyType, coseKeyTypeOKP}, coseParam{coseAlgorithm, COSEAlgorithmEdDSA},
+				coseParam{coseCurve, coseCurveEd25519}, coseParam{coseX, []byte(edPublicKey)[1:]}),
+			wantErr: true,
+		},
+		{
+			name: "RSA modulus shorter than 2048 bits",
+			key: encodeCOSEKey(coseParam{coseKeyType, coseKeyTypeRSA}, coseParam{coseAlgorithm, COSEAlgorithmRS256},
+				coseParam{coseRSAModulus, rsaKey.N.Bytes()}, coseParam{coseRSAExponent, []byte{1, 0, 1}}),
+			wantErr: true,
+		},
+		{
+			name: "unsupported algorithm",
+			key: encodeCOSEKey(coseParam{coseKeyType, coseKeyTypeEC2}, coseParam{coseAlgorithm, int64(-35)},
+				coseParam{coseCurve, int64(2)}, coseParam{coseX, x}, coseParam{coseY, y}),
+			wantErr: true,
+		},
+		{
+			name: "trailing bytes",
+			key: append(encodeCOSEKey(coseParam{coseKeyType, coseKeyTypeOKP}, coseParam{coseAlgorithm, COSEAlgorithmEdDSA},
+				coseParam{coseCurve, coseCurveEd25519}, coseParam{coseX, []byte(edPublicKey)}), 0x00),
+			wantErr: true,
+		},
+		{name: "not a map", key: []byte{0x80}, wantErr: true},
+		{name: "empty", key: nil, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			publicKey, alg, err := ParseCOSEKey(test.key)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("ParseCOSEKey() = %T, %d, want an error", publicKey, alg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseCOSEKey() error = %v", err)
+			}
+
+			if alg != test.wantAlg {
+				t.Errorf("ParseCOSEKey() algorithm = %d, want %d", alg, test.wantAlg)
+			}
+		})
+	}
+}
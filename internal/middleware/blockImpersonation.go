@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+// BlockImpersonation middleware rejects sensitive operations, such as changing credentials, managing
+// sessions or payments, while an admin is impersonating the user. It must be used after IsAuthenticated.
+func BlockImpersonation(ctx *fiber.Ctx) error {
+	if ctx.Locals("impersonatorId") != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "This action is not allowed while impersonating a user",
+		})
+	}
+
+	return ctx.Next()
+}
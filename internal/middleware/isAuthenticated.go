@@ -1,30 +1,43 @@
 package middleware
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt"
 	"github.com/mercan/ecommerce/internal/types"
 	"strings"
 
-	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
 	"github.com/mercan/ecommerce/internal/repositories/redis"
+	"github.com/mercan/ecommerce/internal/services"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var authRedisRepo = redis.NewAuthenticationRedisRepository()
+const (
+	bearerScheme = "Bearer"
+	apiKeyScheme = "ApiKey"
+)
 
-func extractToken(Bearer string) (string, error) {
-	splitToken := strings.Split(Bearer, "Bearer ")
-	if len(splitToken) != 2 {
-		return "", fiber.ErrUnauthorized
+var authRedisRepo = redis.NewAuthenticationRedisRepository()
+var tokenService = services.NewTokenService()
+var apiKeyService = services.NewAPIKeyService()
+var auditService = services.NewAuditService()
+
+// extractToken returns the scheme and the credentials of an Authorization header,
+// either "Bearer <access token>" or "ApiKey <api key>"
+func extractToken(authorization string) (string, string, error) {
+	scheme, token, found := strings.Cut(authorization, " ")
+	if !found || token == "" {
+		return "", "", fiber.ErrUnauthorized
 	}
 
-	if splitToken[1] != "" {
-		return splitToken[1], nil
+	if scheme != bearerScheme && scheme != apiKeyScheme {
+		return "", "", fiber.ErrUnauthorized
 	}
 
-	return "", fiber.ErrUnauthorized
+	return scheme, token, nil
 }
 
 // IsAuthenticated middleware checks if the request has an Authorization header
@@ -37,11 +50,19 @@ func IsAuthenticated(ctx *fiber.Ctx) error {
 		})
 	}
 
-	token, err := extractToken(Bearer)
+	scheme, token, err := extractToken(Bearer)
 	if err != nil {
 		return err
 	}
 
+	// API keys have no session, routes that accept them use IsAuthenticatedOrAPIKey
+	if scheme != bearerScheme {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "API keys are not accepted for this resource",
+		})
+	}
+
 	if existingToken, err := authRedisRepo.IsTokenInBlacklist(token); err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(types.BaseResponse{
 			Success: false,
@@ -57,11 +78,18 @@ func IsAuthenticated(ctx *fiber.Ctx) error {
 	// Parse and validate JWT token
 	claims := jwt.MapClaims{}
 	jwtToken, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := helpers.GetJWTKeyring().VerificationKey(kid)
+		if !ok {
 			return nil, fiber.ErrUnauthorized
 		}
 
-		return []byte(config.GetJWTConfig().Secret), nil
+		// The algorithm is bound to the key, never taken from the token alone
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fiber.ErrUnauthorized
+		}
+
+		return key.PublicKey, nil
 	})
 
 	if err != nil || !jwtToken.Valid {
@@ -79,11 +107,129 @@ func IsAuthenticated(ctx *fiber.Ctx) error {
 		})
 	}
 
+	// Impersonation tokens name the admin in the "act" claim and are bound to the admin's session
+	sessionOwnerId := userId
+	var impersonatorId primitive.ObjectID
+	if act, ok := claims["act"].(map[string]interface{}); ok {
+		actorId, _ := act["sub"].(string)
+		if impersonatorId, err = primitive.ObjectIDFromHex(actorId); err != nil {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(types.BaseResponse{
+				Success: false,
+				Error:   "Unauthorized",
+			})
+		}
+
+		sessionOwnerId = impersonatorId
+	}
+
+	// The token is only valid while its session has not been revoked
+	sessionId, _ := claims["sid"].(string)
+	if err := tokenService.ValidateSession(sessionOwnerId, sessionId, helpers.GetClientInfo(ctx)); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(types.BaseResponse{
+				Success: false,
+				Error:   "Unauthorized",
+			})
+		}
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "Internal server error",
+		})
+	}
+
+	// Deactivated accounts and accounts scheduled for deletion can't be used until they sign in again
+	if isActive, err := mongoRepository.CheckUserActive(userId); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "Internal server error",
+		})
+	} else if !isActive {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "Account is deactivated",
+		})
+	}
+
+	role := models.RoleCustomer
+	if claimRole, ok := claims["role"].(string); ok && claimRole != "" {
+		role = models.Role(claimRole)
+	}
+
 	// Set user context with extracted data
 	ctx.Locals("userId", userId)
-	ctx.Locals("role", claims["role"])
+	ctx.Locals("role", role)
+	ctx.Locals("permissions", role.Permissions())
 	ctx.Locals("exp", claims["exp"])
+	ctx.Locals("sessionId", sessionId)
 	ctx.Locals("token", token)
 
+	if impersonatorId.IsZero() {
+		return ctx.Next()
+	}
+
+	ctx.Locals("impersonatorId", impersonatorId)
+
+	return auditImpersonatedRequest(ctx, impersonatorId, userId)
+}
+
+// auditImpersonatedRequest runs the rest of the chain and records the request in the audit log
+// with the admin as the actor and the impersonated user as the target
+func auditImpersonatedRequest(ctx *fiber.Ctx, impersonatorId primitive.ObjectID, userId primitive.ObjectID) error {
+	err := ctx.Next()
+
+	client := helpers.GetClientInfo(ctx)
+	event := &models.AuditEvent{
+		Action:    models.AuditActionImpersonatedRequest,
+		ActorID:   &impersonatorId,
+		TargetID:  &userId,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Outcome:   models.AuditOutcomeSuccess,
+		Request:   ctx.Method() + " " + ctx.Path(),
+	}
+
+	if err != nil {
+		event.Outcome = models.AuditOutcomeFailure
+		event.Reason = err.Error()
+	} else if ctx.Response().StatusCode() >= fiber.StatusBadRequest {
+		event.Outcome = models.AuditOutcomeFailure
+	}
+
+	auditService.Record(event)
+
+	return err
+}
+
+// IsAuthenticatedOrAPIKey middleware accepts an API key besides an access token. Requests made with
+// a key have no session, so "sessionId", "token" and "exp" are not set. "scopes" holds the scopes of
+// the key and "permissions" only the permissions of the owner's role that the key is scoped to.
+func IsAuthenticatedOrAPIKey(ctx *fiber.Ctx) error {
+	scheme, key, err := extractToken(ctx.Get("Authorization"))
+	if err != nil || scheme != apiKeyScheme {
+		return IsAuthenticated(ctx)
+	}
+
+	apiKey, user, err := apiKeyService.Authenticate(key, helpers.GetClientInfo(ctx))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(types.BaseResponse{
+				Success: false,
+				Error:   "Unauthorized",
+			})
+		}
+
+		return ctx.Status(fiber.StatusForbidden).JSON(types.BaseResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+
+	ctx.Locals("userId", user.ID)
+	ctx.Locals("role", user.GetRole())
+	ctx.Locals("scopes", apiKey.Scopes)
+	ctx.Locals("permissions", user.GetRole().Permissions().Intersect(apiKey.Scopes))
+	ctx.Locals("apiKeyId", apiKey.ID)
+
 	return ctx.Next()
 }
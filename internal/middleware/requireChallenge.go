@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+const challengeResponseHeader = "X-Challenge-Response"
+
+var challengeService = services.NewChallengeService()
+
+// RequireChallenge middleware asks clients that look automated to solve a CAPTCHA or a proof of work
+// before using the endpoints of a scope. Every request is counted, and once a client has made too many
+// requests or failed requests it gets a 428 response with a challenge and has to retry with the solution
+// in the X-Challenge-Response header.
+func RequireChallenge(scope string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		client := helpers.GetClientInfo(ctx)
+
+		// Requests go through when Redis is unavailable, the rate limiter still applies
+		required, err := challengeService.IsRequired(scope, client)
+		if err != nil {
+			log.Println("Error while checking challenge requirement: ", err.Error())
+		}
+
+		if required {
+			response := ctx.Get(challengeResponseHeader)
+			if response == "" {
+				return challengeRequired(ctx, "Challenge required")
+			}
+
+			if err := challengeService.Verify(response, client); err != nil {
+				if errors.Is(err, services.ErrInvalidChallengeResponse) {
+					challengeService.RecordAttempt(scope, client, true)
+					return challengeRequired(ctx, err.Error())
+				}
+
+				log.Println("Error while verifying challenge response: ", err.Error())
+				return ctx.Status(fiber.StatusInternalServerError).JSON(types.BaseResponse{
+					Success: false,
+					Error:   "Internal server error",
+				})
+			}
+		}
+
+		err = ctx.Next()
+		challengeService.RecordAttempt(scope, client, err != nil || ctx.Response().StatusCode() >= fiber.StatusBadRequest)
+
+		return err
+	}
+}
+
+func challengeRequired(ctx *fiber.Ctx, message string) error {
+	challenge, err := challengeService.Issue()
+	if err != nil {
+		log.Println("Error while issuing challenge: ", err.Error())
+		return ctx.Status(fiber.StatusInternalServerError).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "Internal server error",
+		})
+	}
+
+	return ctx.Status(fiber.StatusPreconditionRequired).JSON(types.ChallengeRequiredResponse{
+		BaseResponse: types.BaseResponse{
+			Success: false,
+			Error:   message,
+		},
+		Challenge: types.Challenge{
+			Provider:   challenge.Provider,
+			SiteKey:    challenge.SiteKey,
+			Nonce:      challenge.Nonce,
+			Difficulty: challenge.Difficulty,
+			ExpiresAt:  challenge.ExpiresAt,
+		},
+	})
+}
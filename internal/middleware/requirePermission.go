@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+// RequirePermission middleware allows the request through when the permissions of the request hold
+// every given permission. These are the permissions of the user's role in models.RolePermissions,
+// narrowed to the scopes of the key for requests made with an API key. It must be used after
+// IsAuthenticated.
+func RequirePermission(permissions ...models.Permission) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		granted, _ := ctx.Locals("permissions").(models.Permissions)
+
+		for _, permission := range permissions {
+			if !granted.Has(permission) {
+				return ctx.Status(fiber.StatusForbidden).JSON(types.BaseResponse{
+					Success: false,
+					Error:   "Forbidden",
+				})
+			}
+		}
+
+		return ctx.Next()
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/types"
+)
+
+// RequireRole middleware allows the request through when the authenticated user has one of the given roles.
+// It must be used after IsAuthenticated.
+func RequireRole(roles ...models.Role) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		role, _ := ctx.Locals("role").(models.Role)
+
+		for _, allowed := range roles {
+			if role == allowed {
+				return ctx.Next()
+			}
+		}
+
+		return ctx.Status(fiber.StatusForbidden).JSON(types.BaseResponse{
+			Success: false,
+			Error:   "Forbidden",
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditAction string
+
+const (
+	AuditActionRegister                AuditAction = "register"
+	AuditActionLogin                   AuditAction = "login"
+	AuditActionLogout                  AuditAction = "logout"
+	AuditActionLogoutAll               AuditAction = "logout_all"
+	AuditActionSessionRevoke           AuditAction = "session_revoke"
+	AuditActionPasswordChange          AuditAction = "password_change"
+	AuditActionPasswordResetRequest    AuditAction = "password_reset_request"
+	AuditActionPasswordReset           AuditAction = "password_reset"
+	AuditActionEmailChangeRequest      AuditAction = "email_change_request"
+	AuditActionEmailChange             AuditAction = "email_change"
+	AuditActionEmailChangeRevert       AuditAction = "email_change_revert"
+	AuditActionEmailVerify             AuditAction = "email_verify"
+	AuditActionPhoneChange             AuditAction = "phone_change"
+	AuditActionPhoneVerify             AuditAction = "phone_verify"
+	AuditActionRoleChange              AuditAction = "role_change"
+	AuditActionAPIKeyCreate            AuditAction = "api_key_create"
+	AuditActionAPIKeyUpdate            AuditAction = "api_key_update"
+	AuditActionAPIKeyDelete            AuditAction = "api_key_delete"
+	AuditActionNewDeviceReport         AuditAction = "new_device_report"
+	AuditActionImpersonationStart      AuditAction = "impersonation_start"
+	AuditActionImpersonatedRequest     AuditAction = "impersonated_request"
+	AuditActionWebAuthnRegister        AuditAction = "webauthn_register"
+	AuditActionWebAuthnDelete          AuditAction = "webauthn_delete"
+	AuditActionWebAuthnLogin           AuditAction = "webauthn_login"
+	AuditActionMagicLinkLogin          AuditAction = "magic_link_login"
+	AuditActionOTPLogin                AuditAction = "otp_login"
+	AuditActionOAuthLogin              AuditAction = "oauth_login"
+	AuditActionOAuthLink               AuditAction = "oauth_link"
+	AuditActionOAuthUnlink             AuditAction = "oauth_unlink"
+	AuditActionMFAVerify               AuditAction = "mfa_verify"
+	AuditActionTwoFactorEnable         AuditAction = "two_factor_enable"
+	AuditActionTwoFactorDisable        AuditAction = "two_factor_disable"
+	AuditActionRecoveryCodesRegenerate AuditAction = "recovery_codes_regenerate"
+	AuditActionImpersonationEnd        AuditAction = "impersonation_end"
+	AuditActionAccountDeactivate       AuditAction = "account_deactivate"
+	AuditActionAccountDelete           AuditAction = "account_delete"
+	AuditActionAccountPurge            AuditAction = "account_purge"
+	AuditActionDataExport              AuditAction = "data_export"
+)
+
+type AuditOutcome string
+
+const (
+	AuditOutcomeSuccess AuditOutcome = "success"
+	AuditOutcomeFailure AuditOutcome = "failure"
+	// AuditOutcomeMFARequired is a sign-in whose first factor was verified, it only succeeds
+	// with the mfa_verify event of the second factor
+	AuditOutcomeMFARequired AuditOutcome = "mfa_required"
+)
+
+// AuditEvent is an entry of the append-only security audit log. The actor is who performed
+// the action and the target is the account it was performed on, they differ for admin actions.
+// Email is recorded for actions that name the account by email, such as logins, and is the
+// only reference to the account when it does not exist. Request is the method and path of
+// requests made while impersonating.
+type AuditEvent struct {
+	ID        primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Action    AuditAction         `json:"action" bson:"action"`
+	ActorID   *primitive.ObjectID `json:"actor_id,omitempty" bson:"actor_id,omitempty"`
+	TargetID  *primitive.ObjectID `json:"target_id,omitempty" bson:"target_id,omitempty"`
+	Email     string              `json:"email,omitempty" bson:"email,omitempty"`
+	IP        string              `json:"ip" bson:"ip"`
+	UserAgent string              `json:"user_agent" bson:"user_agent"`
+	Outcome   AuditOutcome        `json:"outcome" bson:"outcome"`
+	Reason    string              `json:"reason,omitempty" bson:"reason,omitempty"`
+	Request   string              `json:"request,omitempty" bson:"request,omitempty"`
+	CreatedAt time.Time           `json:"created_at" bson:"created_at"`
+}
+
+// AuditEventFilter selects audit events, zero fields are not filtered on.
+// UserID matches events where the user is either the actor or the target.
+type AuditEventFilter struct {
+	UserID  *primitive.ObjectID
+	Action  AuditAction
+	Outcome AuditOutcome
+	IP      string
+	Email   string
+	From    *time.Time
+	To      *time.Time
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Category groups products, categories form a tree stored with materialized paths. Path lists the
+// ids of the ancestors of the category and the category itself, e.g. ",<root>,<parent>,<id>,", so
+// that the subtree of a category is every category whose path starts with its path.
+type Category struct {
+	ID          primitive.ObjectID  `json:"id" bson:"_id"`
+	ParentID    *primitive.ObjectID `json:"parent_id" bson:"parent_id"`
+	Name        string              `json:"name" bson:"name"`
+	Slug        string              `json:"slug" bson:"slug"`
+	Description string              `json:"description,omitempty" bson:"description,omitempty"`
+	Path        string              `json:"path" bson:"path"`
+	Depth       int                 `json:"depth" bson:"depth"`
+	Position    int                 `json:"position" bson:"position"`
+	CreatedAt   time.Time           `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at" bson:"updated_at"`
+}
+
+// CategoryPath returns the materialized path of a category under the parent path, root categories
+// have an empty parent path
+func CategoryPath(parentPath string, id primitive.ObjectID) string {
+	if parentPath == "" {
+		parentPath = ","
+	}
+
+	return parentPath + id.Hex() + ","
+}
+
+// AncestorIDs returns the ids of the ancestors of the category, the root first
+func (c *Category) AncestorIDs() []primitive.ObjectID {
+	ids := []primitive.ObjectID{}
+	for _, hex := range strings.Split(strings.Trim(c.Path, ","), ",") {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil || id == c.ID {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// IsAncestorOf reports whether the category is an ancestor of other or other itself
+func (c *Category) IsAncestorOf(other *Category) bool {
+	return strings.HasPrefix(other.Path, c.Path)
+}
+
+// Breadcrumb is a link to a category on the way from the root to a category
+type Breadcrumb struct {
+	ID   primitive.ObjectID `json:"id"`
+	Name string             `json:"name"`
+	Slug string             `json:"slug"`
+}
+
+// CategoryNode is a category with its subcategories
+type CategoryNode struct {
+	ID          primitive.ObjectID `json:"id"`
+	Name        string             `json:"name"`
+	Slug        string             `json:"slug"`
+	Description string             `json:"description,omitempty"`
+	Position    int                `json:"position"`
+	Children    []*CategoryNode    `json:"children"`
+}
@@ -0,0 +1,23 @@
+package models
+
+type CategoryCreateRequest struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Slug        string `json:"slug" validate:"omitempty,max=80"`
+	Description string `json:"description" validate:"max=1000"`
+	ParentID    string `json:"parent_id" validate:"omitempty,mongodb"`
+	Position    int    `json:"position" validate:"min=0"`
+}
+
+// CategoryUpdateRequest changes the fields that are set, the parent is changed by moving the category
+type CategoryUpdateRequest struct {
+	Name        *string `json:"name" validate:"omitempty,min=1,max=100"`
+	Slug        *string `json:"slug" validate:"omitempty,min=1,max=80"`
+	Description *string `json:"description" validate:"omitempty,max=1000"`
+	Position    *int    `json:"position" validate:"omitempty,min=0"`
+}
+
+// CategoryMoveRequest moves a category with its subtree under another parent, an empty parent makes it a root
+type CategoryMoveRequest struct {
+	ParentID string `json:"parent_id" validate:"omitempty,mongodb"`
+	Position *int   `json:"position" validate:"omitempty,min=0"`
+}
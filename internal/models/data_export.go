@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DataExport is the archive of everything stored about a user. Orders have no fixed
+// schema yet and are exported as stored. Known devices are the hashed fingerprints of the
+// devices and networks the user signed in from.
+type DataExport struct {
+	ExportedAt       time.Time            `json:"exported_at"`
+	Profile          *User                `json:"profile"`
+	TwoFactorEnabled bool                 `json:"two_factor_enabled"`
+	Passkeys         []WebAuthnCredential `json:"passkeys"`
+	Sessions         []*Session           `json:"sessions"`
+	KnownDevices     []string             `json:"known_devices"`
+	APIKeys          []*APIKey            `json:"api_keys"`
+	AuditEvents      []*AuditEvent        `json:"audit_events"`
+	Orders           []bson.M             `json:"orders"`
+	Products         []*Product           `json:"products"`
+	Media            []*Media             `json:"media"`
+}
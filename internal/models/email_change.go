@@ -0,0 +1,11 @@
+package models
+
+// EmailChangeRevert is stored behind the revert link sent to the old address when an email
+// change is requested, it holds what is needed to restore the old email. NewEmail is the
+// requested address, the revert only applies while the user has it as email or pending email.
+type EmailChangeRevert struct {
+	UserID        string `json:"user_id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	NewEmail      string `json:"new_email"`
+}
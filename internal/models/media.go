@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MediaTarget is what an uploaded image is attached to
+type MediaTarget string
+
+const (
+	MediaTargetProduct      MediaTarget = "product"
+	MediaTargetProfileImage MediaTarget = "profile_image"
+	MediaTargetBannerImage  MediaTarget = "banner_image"
+)
+
+// Media is an uploaded image and its resized variants, the largest variant is attached to the target.
+// TargetID is the product for product images and the user for profile and banner images.
+type Media struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	OwnerID     primitive.ObjectID `json:"owner_id" bson:"owner_id"`
+	Target      MediaTarget        `json:"target" bson:"target"`
+	TargetID    primitive.ObjectID `json:"target_id" bson:"target_id"`
+	ContentType string             `json:"content_type" bson:"content_type"`
+	URL         string             `json:"url" bson:"url"`
+	Variants    []MediaVariant     `json:"variants" bson:"variants"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// MediaVariant is a stored size of an image, Key identifies the file in the media storage
+type MediaVariant struct {
+	Name   string `json:"name" bson:"name"`
+	URL    string `json:"url" bson:"url"`
+	Key    string `json:"-" bson:"key"`
+	Width  int    `json:"width" bson:"width"`
+	Height int    `json:"height" bson:"height"`
+	Size   int    `json:"size" bson:"size"`
+}
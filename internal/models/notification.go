@@ -0,0 +1,18 @@
+package models
+
+type SecurityNotificationType string
+
+const (
+	SecurityNotificationAccountLocked        SecurityNotificationType = "account_locked"
+	SecurityNotificationEmailChangeRequested SecurityNotificationType = "email_change_requested"
+	SecurityNotificationEmailChangeReverted  SecurityNotificationType = "email_change_reverted"
+	SecurityNotificationNewSignIn            SecurityNotificationType = "new_sign_in"
+)
+
+// SecurityNotification is an email telling the account owner about a security relevant event.
+// Data is passed to the email template as is.
+type SecurityNotification struct {
+	Type  SecurityNotificationType `json:"type"`
+	Email string                   `json:"email"`
+	Data  map[string]string        `json:"data,omitempty"`
+}
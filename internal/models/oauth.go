@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// LinkedIdentity is an external account, e.g. Google or GitHub, that can be used to sign in
+type LinkedIdentity struct {
+	Provider string    `json:"provider" bson:"provider"`
+	Subject  string    `json:"-" bson:"subject"`
+	Email    string    `json:"email,omitempty" bson:"email,omitempty"`
+	LinkedAt time.Time `json:"linked_at" bson:"linked_at"`
+}
+
+// OAuthState is stored while the user is at the identity provider. UserID is only set
+// when an identity is being linked to an account that is already signed in. BindingHash is
+// the hash of the cookie set in the browser that started the flow.
+type OAuthState struct {
+	Provider     string `json:"provider"`
+	CodeVerifier string `json:"code_verifier"`
+	Nonce        string `json:"nonce"`
+	UserID       string `json:"user_id,omitempty"`
+	BindingHash  string `json:"binding_hash"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// PasswordPolicyViolation is a single password policy rule that a password does not satisfy
+type PasswordPolicyViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
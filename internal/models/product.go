@@ -0,0 +1,110 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ProductStatus string
+
+const (
+	ProductStatusDraft    ProductStatus = "draft"
+	ProductStatusActive   ProductStatus = "active"
+	ProductStatusArchived ProductStatus = "archived"
+)
+
+// Product is an item sold by a store, the store is the user that owns it. Price is in the minor
+// unit of the ISO 4217 currency, e.g. cents, and only active products are visible to the public.
+// Rating is the average review rating, zero until the product is rated, and SearchTerms holds the
+// attribute values for the text index, which can not index the values of a map.
+type Product struct {
+	ID          primitive.ObjectID   `json:"id" bson:"_id"`
+	StoreID     primitive.ObjectID   `json:"store_id" bson:"store_id"`
+	Title       string               `json:"title" bson:"title"`
+	Slug        string               `json:"slug" bson:"slug"`
+	Description string               `json:"description,omitempty" bson:"description,omitempty"`
+	Price       int64                `json:"price" bson:"price"`
+	Currency    string               `json:"currency" bson:"currency"`
+	Images      []string             `json:"images" bson:"images"`
+	Attributes  map[string]string    `json:"attributes,omitempty" bson:"attributes,omitempty"`
+	CategoryIDs []primitive.ObjectID `json:"category_ids,omitempty" bson:"category_ids,omitempty"`
+	Options     []ProductOption      `json:"options,omitempty" bson:"options,omitempty"`
+	Variants    []ProductVariant     `json:"variants,omitempty" bson:"variants,omitempty"`
+	Rating      float64              `json:"rating,omitempty" bson:"rating,omitempty"`
+	SearchTerms []string             `json:"-" bson:"search_terms,omitempty"`
+	Status      ProductStatus        `json:"status" bson:"status"`
+	CreatedAt   time.Time            `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at" bson:"updated_at"`
+}
+
+// BuildSearchTerms copies the attribute values into the search terms, it is called whenever they change
+func (p *Product) BuildSearchTerms() {
+	p.SearchTerms = make([]string, 0, len(p.Attributes))
+	for _, value := range p.Attributes {
+		p.SearchTerms = append(p.SearchTerms, value)
+	}
+	sort.Strings(p.SearchTerms)
+}
+
+// ProductOption is a dimension the product is sold in, e.g. Size with the values S, M and L
+type ProductOption struct {
+	Name   string   `json:"name" bson:"name" validate:"required,max=64"`
+	Values []string `json:"values" bson:"values" validate:"required,min=1,max=20,unique,dive,required,max=64"`
+}
+
+// HasValue reports whether value is one of the values of the option
+func (o ProductOption) HasValue(value string) bool {
+	for _, optionValue := range o.Values {
+		if optionValue == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ProductVariant is a sellable combination of option values. Options maps every option name of the
+// product to one of its values. Price overrides the price of the product when set and Weight is in grams.
+type ProductVariant struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	SKU       string             `json:"sku" bson:"sku"`
+	Barcode   string             `json:"barcode,omitempty" bson:"barcode,omitempty"`
+	Options   map[string]string  `json:"options,omitempty" bson:"options,omitempty"`
+	Price     *int64             `json:"price,omitempty" bson:"price,omitempty"`
+	Weight    int64              `json:"weight,omitempty" bson:"weight,omitempty"`
+	Stock     int64              `json:"stock" bson:"stock"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// GetVariant returns the variant with the given id or SKU
+func (p *Product) GetVariant(idOrSKU string) *ProductVariant {
+	for i := range p.Variants {
+		if p.Variants[i].ID.Hex() == idOrSKU || p.Variants[i].SKU == idOrSKU {
+			return &p.Variants[i]
+		}
+	}
+
+	return nil
+}
+
+// ResolveVariantPrices sets the price of every variant without an override to the price of the
+// product, so that clients of the public catalog do not have to
+func (p *Product) ResolveVariantPrices() {
+	for i := range p.Variants {
+		if p.Variants[i].Price == nil {
+			price := p.Price
+			p.Variants[i].Price = &price
+		}
+	}
+}
+
+// ProductFilter selects products, zero fields are not filtered on
+type ProductFilter struct {
+	StoreID     *primitive.ObjectID
+	Status      ProductStatus
+	SKU         string
+	CategoryIDs []primitive.ObjectID
+}
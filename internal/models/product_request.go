@@ -0,0 +1,67 @@
+package models
+
+type ProductCreateRequest struct {
+	Title       string            `json:"title" validate:"required,max=200"`
+	Slug        string            `json:"slug" validate:"omitempty,max=80"`
+	Description string            `json:"description" validate:"max=5000"`
+	Price       int64             `json:"price" validate:"min=0"`
+	Currency    string            `json:"currency" validate:"required,iso4217"`
+	Images      []string          `json:"images" validate:"max=20,dive,required,customURL"`
+	Attributes  map[string]string `json:"attributes" validate:"max=50,dive,keys,required,max=64,endkeys,max=256"`
+	CategoryIDs []string          `json:"category_ids" validate:"max=10,unique,dive,mongodb"`
+	Status      ProductStatus     `json:"status" validate:"omitempty,oneof=draft active archived"`
+}
+
+// ProductUpdateRequest changes the fields that are set, Images and Attributes replace the current values
+type ProductUpdateRequest struct {
+	Title       *string            `json:"title" validate:"omitempty,min=1,max=200"`
+	Slug        *string            `json:"slug" validate:"omitempty,min=1,max=80"`
+	Description *string            `json:"description" validate:"omitempty,max=5000"`
+	Price       *int64             `json:"price" validate:"omitempty,min=0"`
+	Currency    *string            `json:"currency" validate:"omitempty,iso4217"`
+	Images      *[]string          `json:"images" validate:"omitempty,max=20,dive,required,customURL"`
+	Attributes  *map[string]string `json:"attributes" validate:"omitempty,max=50,dive,keys,required,max=64,endkeys,max=256"`
+	CategoryIDs *[]string          `json:"category_ids" validate:"omitempty,max=10,unique,dive,mongodb"`
+	Status      *ProductStatus     `json:"status" validate:"omitempty,oneof=draft active archived"`
+}
+
+type ProductListRequest struct {
+	StoreID  string `query:"store_id" validate:"omitempty,mongodb"`
+	Status   string `query:"status" validate:"omitempty,oneof=draft active archived"`
+	SKU      string `query:"sku" validate:"omitempty,max=64"`
+	Category string `query:"category" validate:"omitempty,max=80"`
+	Page     int64  `query:"page" validate:"omitempty,min=1"`
+	Limit    int64  `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// ProductOptionsRequest replaces the option definitions of a product, the existing variants must fit the new options
+type ProductOptionsRequest struct {
+	Options []ProductOption `json:"options" validate:"max=3,dive"`
+}
+
+type ProductVariantCreateRequest struct {
+	SKU     string            `json:"sku" validate:"required,max=64,printascii"`
+	Barcode string            `json:"barcode" validate:"omitempty,max=64,printascii"`
+	Options map[string]string `json:"options" validate:"max=3"`
+	Price   *int64            `json:"price" validate:"omitempty,min=0"`
+	Weight  int64             `json:"weight" validate:"min=0"`
+	Stock   int64             `json:"stock" validate:"min=0"`
+}
+
+// ProductVariantUpdateRequest changes the fields that are set, ClearPrice removes the price override
+type ProductVariantUpdateRequest struct {
+	SKU        *string            `json:"sku" validate:"omitempty,min=1,max=64,printascii"`
+	Barcode    *string            `json:"barcode" validate:"omitempty,max=64,printascii"`
+	Options    *map[string]string `json:"options" validate:"omitempty,max=3"`
+	Price      *int64             `json:"price" validate:"omitempty,min=0"`
+	ClearPrice bool               `json:"clear_price"`
+	Weight     *int64             `json:"weight" validate:"omitempty,min=0"`
+	Stock      *int64             `json:"stock" validate:"omitempty,min=0"`
+}
+
+// ProductVariantGenerateRequest creates a variant for every combination of option values that has none,
+// SKUs are built from the prefix and the option values
+type ProductVariantGenerateRequest struct {
+	SKUPrefix string `json:"sku_prefix" validate:"required,max=32,printascii"`
+	Stock     int64  `json:"stock" validate:"min=0"`
+}
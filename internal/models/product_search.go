@@ -0,0 +1,86 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type ProductSearchSort string
+
+const (
+	ProductSearchSortRelevance ProductSearchSort = "relevance"
+	ProductSearchSortPriceAsc  ProductSearchSort = "price_asc"
+	ProductSearchSortPriceDesc ProductSearchSort = "price_desc"
+	ProductSearchSortNewest    ProductSearchSort = "newest"
+	ProductSearchSortRating    ProductSearchSort = "rating"
+)
+
+// ProductSearchRequest is the query of a search. Attributes filters by attribute values and is a comma
+// separated list of key:value pairs, e.g. color:red,size:m.
+type ProductSearchRequest struct {
+	Query      string `query:"q" validate:"max=200"`
+	Category   string `query:"category" validate:"omitempty,max=80"`
+	MinPrice   *int64 `query:"min_price" validate:"omitempty,min=0"`
+	MaxPrice   *int64 `query:"max_price" validate:"omitempty,min=0"`
+	Brand      string `query:"brand" validate:"omitempty,max=256"`
+	Attributes string `query:"attributes" validate:"omitempty,max=1000"`
+	Sort       string `query:"sort" validate:"omitempty,oneof=relevance price_asc price_desc newest rating"`
+	Cursor     string `query:"cursor" validate:"omitempty,max=512"`
+	Limit      int64  `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// ProductSearchQuery is a parsed search that is run by a search backend. Only active products are
+// searched, the cursor is opaque to everyone but the backend that returned it.
+type ProductSearchQuery struct {
+	Text        string
+	CategoryIDs []primitive.ObjectID
+	MinPrice    *int64
+	MaxPrice    *int64
+	Brand       string
+	Attributes  map[string]string
+	Sort        ProductSearchSort
+	Cursor      string
+	Limit       int64
+	// Facets are only counted when requested, usually for the first page of a search
+	WithFacets bool
+}
+
+// ProductSearchResult is a page of matching products, NextCursor is empty on the last page
+type ProductSearchResult struct {
+	Products   []*Product
+	NextCursor string
+	Facets     *ProductSearchFacets
+}
+
+// ProductSearchFacets counts the matching products by category, brand, price range and attribute value
+type ProductSearchFacets struct {
+	Total       int64                   `json:"total"`
+	Categories  []FacetCount            `json:"categories"`
+	Brands      []FacetCount            `json:"brands"`
+	PriceRanges []PriceRangeFacet       `json:"price_ranges"`
+	Attributes  map[string][]FacetCount `json:"attributes"`
+}
+
+// FacetCount is the number of matching products with a value, Label is the display name of an id
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// PriceRangeFacet is the number of matching products priced from Min up to but excluding Max, the
+// highest range has no Max
+type PriceRangeFacet struct {
+	Min   int64  `json:"min"`
+	Max   *int64 `json:"max,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// ProductSearchHit is a matching product and the value it is sorted by
+type ProductSearchHit struct {
+	Product   `bson:",inline"`
+	SortValue interface{} `bson:"sort_value"`
+}
+
+// ProductSearchAfter is the position of the last product of a page, the next page starts after it
+type ProductSearchAfter struct {
+	SortValue interface{}
+	ID        primitive.ObjectID
+}
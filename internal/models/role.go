@@ -0,0 +1,95 @@
+package models
+
+type Role string
+
+const (
+	RoleCustomer   Role = "customer"
+	RoleStoreOwner Role = "store_owner"
+	RoleStaff      Role = "staff"
+	RoleAdmin      Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermissionOrdersRead       Permission = "orders:read"
+	PermissionOrdersManage     Permission = "orders:manage"
+	PermissionProductsWrite    Permission = "products:write"
+	PermissionProductsManage   Permission = "products:manage"
+	PermissionCategoriesManage Permission = "categories:manage"
+	PermissionUsersRead        Permission = "users:read"
+	PermissionUsersManage      Permission = "users:manage"
+)
+
+// RolePermissions is the permission matrix. Routes declare the permissions they need
+// and a role is allowed through when it holds all of them.
+var RolePermissions = map[Role][]Permission{
+	RoleCustomer: {
+		PermissionOrdersRead,
+	},
+	RoleStoreOwner: {
+		PermissionOrdersRead,
+		PermissionOrdersManage,
+		PermissionProductsWrite,
+	},
+	RoleStaff: {
+		PermissionOrdersRead,
+		PermissionOrdersManage,
+		PermissionProductsWrite,
+		PermissionProductsManage,
+		PermissionCategoriesManage,
+		PermissionUsersRead,
+	},
+	RoleAdmin: {
+		PermissionOrdersRead,
+		PermissionOrdersManage,
+		PermissionProductsWrite,
+		PermissionProductsManage,
+		PermissionCategoriesManage,
+		PermissionUsersRead,
+		PermissionUsersManage,
+	},
+}
+
+// IsValid reports whether the role is part of the permission matrix
+func (r Role) IsValid() bool {
+	_, ok := RolePermissions[r]
+	return ok
+}
+
+// HasPermission reports whether the role has been granted the permission
+func (r Role) HasPermission(permission Permission) bool {
+	return r.Permissions().Has(permission)
+}
+
+// Permissions returns the permissions the role has been granted
+func (r Role) Permissions() Permissions {
+	return RolePermissions[r]
+}
+
+// Permissions are the permissions a request was made with. Requests made with an API key only
+// hold the permissions of the owner's role that are also in the scopes of the key.
+type Permissions []Permission
+
+// Has reports whether the permission is part of the set
+func (p Permissions) Has(permission Permission) bool {
+	for _, granted := range p {
+		if granted == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Intersect returns the permissions that are part of both sets
+func (p Permissions) Intersect(scopes []Permission) Permissions {
+	permissions := make(Permissions, 0, len(p))
+	for _, permission := range p {
+		if Permissions(scopes).Has(permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	return permissions
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Session is a signed-in device. Its id is carried in the "sid" claim of every access
+// token and groups the refresh tokens rotated for that device.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// NewDeviceReport is stored under the token of a new device report link. SignedInAt dates the
+// reported sign-in, passkeys registered since then are removed with it.
+type NewDeviceReport struct {
+	UserID     string    `json:"user_id"`
+	SignedInAt time.Time `json:"signed_in_at"`
+}
+
+// ClientInfo describes the client a request originates from
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
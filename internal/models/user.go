@@ -8,22 +8,31 @@ import (
 )
 
 type User struct {
-	ID                  primitive.ObjectID `json:"_id" bson:"_id"`
-	FirstName           string             `json:"first_name,omitempty" bson:"first_name,omitempty"`
-	LastName            string             `json:"last_name,omitempty" bson:"last_name,omitempty"`
-	Email               string             `json:"email,omitempty" bson:"email,omitempty"`
-	EmailVerified       bool               `json:"email_verified" bson:"email_verified"`
-	Password            string             `json:"password,omitempty" bson:"password,omitempty"`
-	PhoneNumber         string             `json:"phone_number,omitempty" bson:"phone_number,omitempty"`
-	PhoneNumberVerified bool               `json:"phone_number_verified" bson:"phone_number_verified"`
-	IsActive            bool               `json:"is_active" bson:"is_active"`
-	Description         string             `json:"description,omitempty" bson:"description,omitempty"`
-	SocialMediaLinks    SocialMediaLinks   `json:"social_media_links,omitempty" bson:"social_media_links,omitempty"`
-	Price               int                `json:"price,omitempty" bson:"price,omitempty"`
-	ProfileImage        string             `json:"profile_image,omitempty" bson:"profile_image,omitempty"`
-	BannerImage         string             `json:"banner_image,omitempty" bson:"banner_image,omitempty"`
-	CreatedAt           time.Time          `json:"created_at,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt           time.Time          `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	ID                    primitive.ObjectID   `json:"_id" bson:"_id"`
+	FirstName             string               `json:"first_name,omitempty" bson:"first_name,omitempty"`
+	LastName              string               `json:"last_name,omitempty" bson:"last_name,omitempty"`
+	Email                 string               `json:"email,omitempty" bson:"email,omitempty"`
+	EmailVerified         bool                 `json:"email_verified" bson:"email_verified"`
+	PendingEmail          string               `json:"-" bson:"pending_email,omitempty"`
+	Password              string               `json:"password,omitempty" bson:"password,omitempty"`
+	PasswordHistory       []string             `json:"-" bson:"password_history,omitempty"`
+	PasswordResetRequired bool                 `json:"-" bson:"password_reset_required,omitempty"`
+	PhoneNumber           string               `json:"phone_number,omitempty" bson:"phone_number,omitempty"`
+	PhoneNumberVerified   bool                 `json:"phone_number_verified" bson:"phone_number_verified"`
+	IsActive              bool                 `json:"is_active" bson:"is_active"`
+	DeactivatedAt         *time.Time           `json:"deactivated_at,omitempty" bson:"deactivated_at,omitempty"`
+	DeletionScheduledAt   *time.Time           `json:"deletion_scheduled_at,omitempty" bson:"deletion_scheduled_at,omitempty"`
+	Role                  Role                 `json:"role,omitempty" bson:"role,omitempty"`
+	TwoFactor             TwoFactor            `json:"-" bson:"two_factor,omitempty"`
+	WebAuthnCredentials   []WebAuthnCredential `json:"-" bson:"webauthn_credentials,omitempty"`
+	LinkedIdentities      []LinkedIdentity     `json:"linked_identities,omitempty" bson:"linked_identities,omitempty"`
+	Description           string               `json:"description,omitempty" bson:"description,omitempty"`
+	SocialMediaLinks      SocialMediaLinks     `json:"social_media_links,omitempty" bson:"social_media_links,omitempty"`
+	Price                 int                  `json:"price,omitempty" bson:"price,omitempty"`
+	ProfileImage          string               `json:"profile_image,omitempty" bson:"profile_image,omitempty"`
+	BannerImage           string               `json:"banner_image,omitempty" bson:"banner_image,omitempty"`
+	CreatedAt             time.Time            `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt             time.Time            `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
 }
 
 type SocialMediaLinks struct {
@@ -36,10 +45,21 @@ type SocialMediaLinks struct {
 	YouTube   string `json:"youtube,omitempty" bson:"youtube,omitempty" validate:"customURL"`
 }
 
+// TwoFactor holds the TOTP enrollment of a user. Recovery codes are stored as SHA-256 hashes
+// and removed once used.
+type TwoFactor struct {
+	Enabled       bool      `bson:"enabled"`
+	Secret        string    `bson:"secret,omitempty"`
+	PendingSecret string    `bson:"pending_secret,omitempty"`
+	RecoveryCodes []string  `bson:"recovery_codes,omitempty"`
+	EnabledAt     time.Time `bson:"enabled_at,omitempty"`
+}
+
 func NewUser() *User {
 	return &User{
 		ID:               primitive.NewObjectID(),
 		IsActive:         true,
+		Role:             RoleCustomer,
 		SocialMediaLinks: SocialMediaLinks{},
 		Price:            100, // Constant value
 		CreatedAt:        time.Now(),
@@ -47,12 +67,22 @@ func NewUser() *User {
 	}
 }
 
+// GetRole returns the role of the user, accounts created before roles existed are customers
+func (u *User) GetRole() Role {
+	if u.Role == "" {
+		return RoleCustomer
+	}
+
+	return u.Role
+}
+
 func (u *User) RegisterValidation() error {
 	registerStruct := UserRegisterRequest{
 		FirstName:   u.FirstName,
 		LastName:    u.LastName,
 		Email:       u.Email,
 		Password:    u.Password,
+		PhoneNumber: u.PhoneNumber,
 		Description: u.Description,
 		SocialMediaLinks: SocialMediaLinks{
 			Website:   u.SocialMediaLinks.Website,
@@ -1,10 +1,13 @@
 package models
 
+import "time"
+
 type UserRegisterRequest struct {
 	FirstName        string           `json:"first_name" validate:"required"`
 	LastName         string           `json:"last_name" validate:"required"`
 	Email            string           `json:"email" validate:"required,email"`
-	Password         string           `json:"password" validate:"required,min=6,max=500"`
+	Password         string           `json:"password" validate:"required,max=500"`
+	PhoneNumber      string           `json:"phone_number" validate:"customPhone"`
 	Description      string           `json:"description" validate:"required,min=6,max=500"`
 	SocialMediaLinks SocialMediaLinks `json:"social_media"`
 }
@@ -16,14 +19,162 @@ type UserLoginRequest struct {
 
 type UserChangePasswordRequest struct {
 	Password           string `json:"password" validate:"required,min=6,max=500"`
-	NewPassword        string `json:"new_password" validate:"required,min=6,max=500"`
-	NewPasswordConfirm string `json:"new_password_confirm" validate:"required,min=6,max=500"`
+	NewPassword        string `json:"new_password" validate:"required,max=500"`
+	NewPasswordConfirm string `json:"new_password_confirm" validate:"required,max=500"`
 }
 
 type UserChangeEmailRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+type UserRevertEmailChangeRequest struct {
+	Token string `json:"token" form:"token" validate:"required,max=128"`
+}
+
+type UserReportNewDeviceRequest struct {
+	Token string `json:"token" form:"token" validate:"required,max=128"`
+}
+
+type UserChangePhoneRequest struct {
+	PhoneNumber string `json:"phone_number" validate:"required,customPhone"`
+}
+
 type UserVerificationRequest struct {
 	Code string `json:"code" query:"code" validate:"required,min=6,max=6,number"`
 }
+
+type UserForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type UserResetPasswordRequest struct {
+	NewPassword        string `json:"new_password" validate:"required,max=500"`
+	NewPasswordConfirm string `json:"new_password_confirm" validate:"required,max=500"`
+}
+
+type UserRefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type UserChangeRoleRequest struct {
+	Role Role `json:"role" validate:"required"`
+}
+
+type UserTwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type UserTwoFactorDisableRequest struct {
+	Password string `json:"password" validate:"required,min=6,max=500"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+type UserLoginTwoFactorRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// WebAuthnAttestationCredential is the JSON encoding of the PublicKeyCredential returned by
+// navigator.credentials.create, binary values are base64url encoded
+type WebAuthnAttestationCredential struct {
+	ID       string                      `json:"id" validate:"required,max=1400"`
+	Type     string                      `json:"type" validate:"required,eq=public-key"`
+	Response WebAuthnAttestationResponse `json:"response"`
+}
+
+type WebAuthnAttestationResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON" validate:"required"`
+	AttestationObject string   `json:"attestationObject" validate:"required"`
+	Transports        []string `json:"transports" validate:"max=10,dive,max=32"`
+}
+
+// WebAuthnAssertionCredential is the JSON encoding of the PublicKeyCredential returned by
+// navigator.credentials.get, binary values are base64url encoded
+type WebAuthnAssertionCredential struct {
+	ID       string                    `json:"id" validate:"required,max=1400"`
+	Type     string                    `json:"type" validate:"required,eq=public-key"`
+	Response WebAuthnAssertionResponse `json:"response"`
+}
+
+type WebAuthnAssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+	AuthenticatorData string `json:"authenticatorData" validate:"required"`
+	Signature         string `json:"signature" validate:"required"`
+	UserHandle        string `json:"userHandle"`
+}
+
+type WebAuthnRegistrationRequest struct {
+	Name       string                        `json:"name" validate:"omitempty,max=64"`
+	Credential WebAuthnAttestationCredential `json:"credential"`
+}
+
+type WebAuthnLoginRequest struct {
+	Credential WebAuthnAssertionCredential `json:"credential"`
+}
+
+type WebAuthnSecondFactorOptionsRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+type WebAuthnSecondFactorRequest struct {
+	MFAToken   string                      `json:"mfa_token" validate:"required"`
+	Credential WebAuthnAssertionCredential `json:"credential"`
+}
+
+type UserMagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type UserMagicLinkVerifyRequest struct {
+	Token string `json:"token" form:"token" validate:"required,max=128"`
+}
+
+type UserLoginOTPRequest struct {
+	PhoneNumber string `json:"phone_number" validate:"required,customPhone"`
+}
+
+type UserLoginOTPVerifyRequest struct {
+	PhoneNumber string `json:"phone_number" validate:"required,customPhone"`
+	Code        string `json:"code" validate:"required,min=6,max=6,number"`
+}
+
+type UserOAuthCallbackRequest struct {
+	Code             string `query:"code"`
+	State            string `query:"state" validate:"required,max=128"`
+	Error            string `query:"error"`
+	ErrorDescription string `query:"error_description"`
+}
+
+type UserPasswordConfirmRequest struct {
+	Password string `json:"password" validate:"required,max=500"`
+}
+
+type AuditEventListRequest struct {
+	Page  int64 `query:"page" validate:"omitempty,min=1"`
+	Limit int64 `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+type AuditEventQueryRequest struct {
+	UserID  string `query:"user_id" validate:"omitempty,mongodb"`
+	Action  string `query:"action" validate:"omitempty,max=64"`
+	Outcome string `query:"outcome" validate:"omitempty,oneof=success failure mfa_required"`
+	IP      string `query:"ip" validate:"omitempty,ip"`
+	Email   string `query:"email" validate:"omitempty,email"`
+	From    string `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To      string `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Page    int64  `query:"page" validate:"omitempty,min=1"`
+	Limit   int64  `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+type APIKeyCreateRequest struct {
+	Name       string       `json:"name" validate:"required,max=64"`
+	Scopes     []Permission `json:"scopes" validate:"required,min=1,max=20,dive,required"`
+	AllowedIPs []string     `json:"allowed_ips" validate:"omitempty,max=20,dive,cidr|ip"`
+	ExpiresAt  *time.Time   `json:"expires_at"`
+}
+
+type APIKeyUpdateRequest struct {
+	Name       string       `json:"name" validate:"omitempty,max=64"`
+	Scopes     []Permission `json:"scopes" validate:"omitempty,min=1,max=20,dive,required"`
+	AllowedIPs *[]string    `json:"allowed_ips" validate:"omitempty,max=20,dive,cidr|ip"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+	WebAuthnCeremonySecondFactor = "second_factor"
+)
+
+// WebAuthnCredential is a passkey or security key of a user. ID is the base64url encoded credential id
+// and PublicKey the COSE encoded public key. SignCount is the last signature counter reported by the
+// authenticator, it must grow with every assertion unless the authenticator doesn't count.
+type WebAuthnCredential struct {
+	ID             string     `json:"id" bson:"credential_id"`
+	Name           string     `json:"name" bson:"name"`
+	PublicKey      []byte     `json:"-" bson:"public_key"`
+	SignCount      uint32     `json:"-" bson:"sign_count"`
+	AAGUID         string     `json:"aaguid,omitempty" bson:"aaguid,omitempty"`
+	Transports     []string   `json:"transports,omitempty" bson:"transports,omitempty"`
+	BackupEligible bool       `json:"backup_eligible" bson:"backup_eligible"`
+	CreatedAt      time.Time  `json:"created_at" bson:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+}
+
+// WebAuthnSession is stored under its challenge until the ceremony finishes. UserID is not set for
+// passwordless logins, where the user is only known once the credential is presented.
+type WebAuthnSession struct {
+	Ceremony         string `json:"ceremony"`
+	UserID           string `json:"user_id,omitempty"`
+	UserVerification string `json:"user_verification"`
+}
+
+// WebAuthnCreationOptions are the PublicKeyCredentialCreationOptions passed to navigator.credentials.create
+type WebAuthnCreationOptions struct {
+	Challenge              string                         `json:"challenge"`
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUserEntity             `json:"user"`
+	PubKeyCredParams       []WebAuthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+// WebAuthnRequestOptions are the PublicKeyCredentialRequestOptions passed to navigator.credentials.get
+type WebAuthnRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	RPID             string                         `json:"rpId"`
+	Timeout          int64                          `json:"timeout"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type WebAuthnUserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type WebAuthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type WebAuthnCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
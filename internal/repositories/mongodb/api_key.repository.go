@@ -0,0 +1,154 @@
+package mongodb
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+type APIKeyMongoRepository interface {
+	CreateAPIKey(key *models.APIKey) error
+	CountAPIKeysByUserID(userId primitive.ObjectID) (int64, error)
+	GetAPIKeysByUserID(userId primitive.ObjectID) ([]*models.APIKey, error)
+	GetAPIKey(userId primitive.ObjectID, keyId primitive.ObjectID) (*models.APIKey, error)
+	GetAPIKeyByPrefix(prefix string) (*models.APIKey, error)
+	UpdateAPIKey(key *models.APIKey) (bool, error)
+	TouchAPIKey(keyId primitive.ObjectID, ip string) error
+	DeleteAPIKey(userId primitive.ObjectID, keyId primitive.ObjectID) (bool, error)
+	DeleteAPIKeysByUserID(userId primitive.ObjectID) error
+}
+
+type APIKeyMongoRepositoryImpl struct {
+	Collection *mongo.Collection
+}
+
+func NewAPIKeyMongoRepository() APIKeyMongoRepository {
+	return &APIKeyMongoRepositoryImpl{
+		Collection: GetCollection(config.GetMongoDBConfig().Collections.APIKeys),
+	}
+}
+
+func (repository *APIKeyMongoRepositoryImpl) CreateAPIKey(key *models.APIKey) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	if _, err := repository.Collection.InsertOne(ctx, key); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *APIKeyMongoRepositoryImpl) CountAPIKeysByUserID(userId primitive.ObjectID) (int64, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	return repository.Collection.CountDocuments(ctx, bson.M{"user_id": userId})
+}
+
+func (repository *APIKeyMongoRepositoryImpl) GetAPIKeysByUserID(userId primitive.ObjectID) ([]*models.APIKey, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := repository.Collection.Find(ctx, bson.M{"user_id": userId}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (repository *APIKeyMongoRepositoryImpl) GetAPIKey(userId primitive.ObjectID, keyId primitive.ObjectID) (*models.APIKey, error) {
+	return repository.findOne(bson.M{"_id": keyId, "user_id": userId})
+}
+
+func (repository *APIKeyMongoRepositoryImpl) GetAPIKeyByPrefix(prefix string) (*models.APIKey, error) {
+	return repository.findOne(bson.M{"prefix": prefix})
+}
+
+func (repository *APIKeyMongoRepositoryImpl) findOne(filter bson.M) (*models.APIKey, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	var key models.APIKey
+	if err := repository.Collection.FindOne(ctx, filter).Decode(&key); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// UpdateAPIKey stores the name, scopes and IP allowlist of a key of its owner
+func (repository *APIKeyMongoRepositoryImpl) UpdateAPIKey(key *models.APIKey) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": key.ID, "user_id": key.UserID}
+	update := bson.M{"$set": bson.M{
+		"name":        key.Name,
+		"scopes":      key.Scopes,
+		"allowed_ips": key.AllowedIPs,
+		"updated_at":  key.UpdatedAt,
+	}}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+// TouchAPIKey records when and from where a key was last used
+func (repository *APIKeyMongoRepositoryImpl) TouchAPIKey(keyId primitive.ObjectID, ip string) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": keyId}
+	update := bson.M{"$set": bson.M{"last_used_at": time.Now(), "last_used_ip": ip}}
+	if _, err := repository.Collection.UpdateOne(ctx, filter, update); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *APIKeyMongoRepositoryImpl) DeleteAPIKey(userId primitive.ObjectID, keyId primitive.ObjectID) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	result, err := repository.Collection.DeleteOne(ctx, bson.M{"_id": keyId, "user_id": userId})
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount == 1, nil
+}
+
+func (repository *APIKeyMongoRepositoryImpl) DeleteAPIKeysByUserID(userId primitive.ObjectID) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	if _, err := repository.Collection.DeleteMany(ctx, bson.M{"user_id": userId}); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package mongodb
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+// AuditEventMongoRepository is append-only, events are never updated or deleted
+type AuditEventMongoRepository interface {
+	CreateEvent(event *models.AuditEvent) error
+	GetEvents(filter models.AuditEventFilter, page int64, limit int64) ([]*models.AuditEvent, int64, error)
+}
+
+type AuditEventMongoRepositoryImpl struct {
+	Collection *mongo.Collection
+}
+
+func NewAuditEventMongoRepository() AuditEventMongoRepository {
+	return &AuditEventMongoRepositoryImpl{
+		Collection: GetCollection(config.GetMongoDBConfig().Collections.AuditEvents),
+	}
+}
+
+func (repository *AuditEventMongoRepositoryImpl) CreateEvent(event *models.AuditEvent) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	if _, err := repository.Collection.InsertOne(ctx, event); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetEvents returns a page of the events matching the filter, newest first, and the total number of matches
+func (repository *AuditEventMongoRepositoryImpl) GetEvents(filter models.AuditEventFilter, page int64, limit int64) ([]*models.AuditEvent, int64, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.UserID != nil {
+		query["$or"] = bson.A{bson.M{"actor_id": filter.UserID}, bson.M{"target_id": filter.UserID}}
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.Outcome != "" {
+		query["outcome"] = filter.Outcome
+	}
+	if filter.IP != "" {
+		query["ip"] = filter.IP
+	}
+	if filter.Email != "" {
+		query["email"] = filter.Email
+	}
+	if filter.From != nil || filter.To != nil {
+		createdAt := bson.M{}
+		if filter.From != nil {
+			createdAt["$gte"] = filter.From
+		}
+		if filter.To != nil {
+			createdAt["$lte"] = filter.To
+		}
+		query["created_at"] = createdAt
+	}
+
+	total, err := repository.Collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := repository.Collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events := []*models.AuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
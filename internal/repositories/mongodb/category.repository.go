@@ -0,0 +1,238 @@
+package mongodb
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+type CategoryMongoRepository interface {
+	CreateCategory(category *models.Category) error
+	GetCategoryByID(id primitive.ObjectID) (*models.Category, error)
+	GetCategoryBySlug(slug string) (*models.Category, error)
+	GetCategoriesByIDs(ids []primitive.ObjectID) ([]*models.Category, error)
+	GetCategories() ([]*models.Category, error)
+	GetSubtree(path string) ([]*models.Category, error)
+	HasChildren(id primitive.ObjectID) (bool, error)
+	UpdateCategory(category *models.Category) (bool, error)
+	MoveCategory(category *models.Category, oldPath string, depthDelta int) (bool, error)
+	DeleteCategory(id primitive.ObjectID) (bool, error)
+}
+
+type CategoryMongoRepositoryImpl struct {
+	Collection *mongo.Collection
+}
+
+func NewCategoryMongoRepository() CategoryMongoRepository {
+	return &CategoryMongoRepositoryImpl{
+		Collection: GetCollection(config.GetMongoDBConfig().Collections.Categories),
+	}
+}
+
+func (repository *CategoryMongoRepositoryImpl) CreateCategory(category *models.Category) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	if _, err := repository.Collection.InsertOne(ctx, category); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *CategoryMongoRepositoryImpl) GetCategoryByID(id primitive.ObjectID) (*models.Category, error) {
+	return repository.findOne(bson.M{"_id": id})
+}
+
+func (repository *CategoryMongoRepositoryImpl) GetCategoryBySlug(slug string) (*models.Category, error) {
+	return repository.findOne(bson.M{"slug": slug})
+}
+
+func (repository *CategoryMongoRepositoryImpl) findOne(filter bson.M) (*models.Category, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	var category models.Category
+	if err := repository.Collection.FindOne(ctx, filter).Decode(&category); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+func (repository *CategoryMongoRepositoryImpl) GetCategoriesByIDs(ids []primitive.ObjectID) ([]*models.Category, error) {
+	return repository.find(bson.M{"_id": bson.M{"$in": ids}})
+}
+
+// GetCategories returns every category, parents before their children and siblings in order of position
+func (repository *CategoryMongoRepositoryImpl) GetCategories() ([]*models.Category, error) {
+	return repository.find(bson.M{})
+}
+
+// GetSubtree returns the category with the given path and all of its descendants
+func (repository *CategoryMongoRepositoryImpl) GetSubtree(path string) ([]*models.Category, error) {
+	return repository.find(subtreeFilter(path))
+}
+
+func (repository *CategoryMongoRepositoryImpl) find(filter bson.M) ([]*models.Category, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "depth", Value: 1}, {Key: "position", Value: 1}, {Key: "name", Value: 1}})
+	cursor, err := repository.Collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := []*models.Category{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+func (repository *CategoryMongoRepositoryImpl) HasChildren(id primitive.ObjectID) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	count, err := repository.Collection.CountDocuments(ctx, bson.M{"parent_id": id}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// UpdateCategory stores the editable fields of a category, its place in the tree is changed by MoveCategory
+func (repository *CategoryMongoRepositoryImpl) UpdateCategory(category *models.Category) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": category.ID}
+	update := bson.M{"$set": bson.M{
+		"name":        category.Name,
+		"slug":        category.Slug,
+		"description": category.Description,
+		"position":    category.Position,
+		"updated_at":  category.UpdatedAt,
+	}}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+// errCategoryMoveConflict aborts a move whose category or parent changed since they were read
+var errCategoryMoveConflict = errors.New("Category move conflict")
+
+// MoveCategory stores the new parent and position of a category and rewrites the paths and depths of
+// its subtree, the category holds its new path and oldPath is the path it was moved from. Both writes
+// happen in one transaction that only applies while the category is still at oldPath and the parent
+// still has the path the new one was built from, it reports whether the move was applied.
+func (repository *CategoryMongoRepositoryImpl) MoveCategory(category *models.Category, oldPath string, depthDelta int) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(30)
+	defer cancel()
+
+	// A category can never end up below itself
+	parentPath := strings.TrimSuffix(category.Path, category.ID.Hex()+",")
+	if strings.Contains(parentPath, ","+category.ID.Hex()+",") {
+		return false, nil
+	}
+
+	session, err := repository.Collection.Database().Client().StartSession()
+	if err != nil {
+		return false, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		// The parent is written as well, so that two categories moved into each other conflict
+		// instead of both committing and forming a cycle
+		if category.ParentID != nil {
+			filter := bson.M{"_id": category.ParentID, "path": parentPath}
+			update := bson.M{"$set": bson.M{"updated_at": category.UpdatedAt}}
+			result, err := repository.Collection.UpdateOne(sessionCtx, filter, update)
+			if err != nil {
+				return nil, err
+			}
+
+			if result.MatchedCount != 1 {
+				return nil, errCategoryMoveConflict
+			}
+		}
+
+		filter := bson.M{"_id": category.ID, "path": oldPath}
+		update := bson.M{"$set": bson.M{
+			"parent_id":  category.ParentID,
+			"position":   category.Position,
+			"updated_at": category.UpdatedAt,
+		}}
+		result, err := repository.Collection.UpdateOne(sessionCtx, filter, update)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.MatchedCount != 1 {
+			return nil, errCategoryMoveConflict
+		}
+
+		// Replace the old path prefix with the new one, paths only contain ASCII characters
+		pipeline := mongo.Pipeline{
+			{{Key: "$set", Value: bson.D{
+				{Key: "path", Value: bson.D{{Key: "$concat", Value: bson.A{
+					category.Path,
+					bson.D{{Key: "$substrCP", Value: bson.A{"$path", len(oldPath), bson.D{{Key: "$strLenCP", Value: "$path"}}}}},
+				}}}},
+				{Key: "depth", Value: bson.D{{Key: "$add", Value: bson.A{"$depth", depthDelta}}}},
+				{Key: "updated_at", Value: category.UpdatedAt},
+			}}},
+		}
+		if _, err := repository.Collection.UpdateMany(sessionCtx, subtreeFilter(oldPath), pipeline); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		if errors.Is(err, errCategoryMoveConflict) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (repository *CategoryMongoRepositoryImpl) DeleteCategory(id primitive.ObjectID) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	result, err := repository.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount == 1, nil
+}
+
+// subtreeFilter matches the categories whose path starts with path, the anchored regex uses the path index
+func subtreeFilter(path string) bson.M {
+	return bson.M{"path": bson.M{"$regex": "^" + regexp.QuoteMeta(path)}}
+}
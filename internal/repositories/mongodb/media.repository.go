@@ -0,0 +1,110 @@
+package mongodb
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+type MediaMongoRepository interface {
+	CreateMedia(media *models.Media) error
+	GetMediaByID(id primitive.ObjectID) (*models.Media, error)
+	GetMediaByTarget(target models.MediaTarget, targetId primitive.ObjectID) ([]*models.Media, error)
+	GetMediaByOwnerID(ownerId primitive.ObjectID) ([]*models.Media, error)
+	DeleteMedia(id primitive.ObjectID) (bool, error)
+}
+
+type MediaMongoRepositoryImpl struct {
+	Collection *mongo.Collection
+}
+
+func NewMediaMongoRepository() MediaMongoRepository {
+	return &MediaMongoRepositoryImpl{
+		Collection: GetCollection(config.GetMongoDBConfig().Collections.Media),
+	}
+}
+
+func (repository *MediaMongoRepositoryImpl) CreateMedia(media *models.Media) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	if _, err := repository.Collection.InsertOne(ctx, media); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *MediaMongoRepositoryImpl) GetMediaByID(id primitive.ObjectID) (*models.Media, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	var media models.Media
+	if err := repository.Collection.FindOne(ctx, bson.M{"_id": id}).Decode(&media); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &media, nil
+}
+
+// GetMediaByTarget returns the images attached to a product, profile or banner, newest first
+func (repository *MediaMongoRepositoryImpl) GetMediaByTarget(target models.MediaTarget, targetId primitive.ObjectID) ([]*models.Media, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"target": target, "target_id": targetId}
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	cursor, err := repository.Collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	media := []*models.Media{}
+	if err := cursor.All(ctx, &media); err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+// GetMediaByOwnerID returns every image uploaded by a user, newest first
+func (repository *MediaMongoRepositoryImpl) GetMediaByOwnerID(ownerId primitive.ObjectID) ([]*models.Media, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	cursor, err := repository.Collection.Find(ctx, bson.M{"owner_id": ownerId}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	media := []*models.Media{}
+	if err := cursor.All(ctx, &media); err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+func (repository *MediaMongoRepositoryImpl) DeleteMedia(id primitive.ObjectID) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	result, err := repository.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount == 1, nil
+}
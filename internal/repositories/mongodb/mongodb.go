@@ -9,9 +9,23 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
+	"sync"
 )
 
-var client = Connect()
+// The client connects when the first repository is created rather than when the package is loaded,
+// so that packages depending on it can be tested without a server
+var (
+	client      *mongo.Client
+	connectOnce sync.Once
+)
+
+func getClient() *mongo.Client {
+	connectOnce.Do(func() {
+		client = Connect()
+	})
+
+	return client
+}
 
 func Connect() *mongo.Client {
 	mongoURI := config.GetMongoDBConfig().URI
@@ -33,6 +47,26 @@ func Connect() *mongo.Client {
 		log.Fatalf("MongoDB create user indexes error: %v", err)
 	}
 
+	if err := createAuditEventIndexes(client); err != nil {
+		log.Fatalf("MongoDB create audit event indexes error: %v", err)
+	}
+
+	if err := createAPIKeyIndexes(client); err != nil {
+		log.Fatalf("MongoDB create API key indexes error: %v", err)
+	}
+
+	if err := createProductIndexes(client); err != nil {
+		log.Fatalf("MongoDB create product indexes error: %v", err)
+	}
+
+	if err := createCategoryIndexes(client); err != nil {
+		log.Fatalf("MongoDB create category indexes error: %v", err)
+	}
+
+	if err := createMediaIndexes(client); err != nil {
+		log.Fatalf("MongoDB create media indexes error: %v", err)
+	}
+
 	log.Println("Connected to MongoDB")
 	return client
 }
@@ -53,6 +87,128 @@ func createUserIndexes(client *mongo.Client) error {
 			Keys:    bson.M{"email": 1},
 			Options: options.Index().SetUnique(true),
 		},
+		// Only verified numbers are unique, an unverified number must not keep its owner from using it
+		{
+			Keys: bson.M{"phone_number": 1},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"phone_number":          bson.M{"$exists": true},
+				"phone_number_verified": true,
+			}),
+		},
+		{
+			Keys:    bson.D{{Key: "linked_identities.provider", Value: 1}, {Key: "linked_identities.subject", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{
+			Keys:    bson.M{"webauthn_credentials.credential_id": 1},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+	return err
+}
+
+func createAuditEventIndexes(client *mongo.Client) error {
+	collection := client.Database(config.GetMongoDBConfig().Database).Collection(config.GetMongoDBConfig().Collections.AuditEvents)
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "action", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.M{"created_at": -1},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+	return err
+}
+
+func createAPIKeyIndexes(client *mongo.Client) error {
+	collection := client.Database(config.GetMongoDBConfig().Database).Collection(config.GetMongoDBConfig().Collections.APIKeys)
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.M{"prefix": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+	return err
+}
+
+func createProductIndexes(client *mongo.Client) error {
+	collection := client.Database(config.GetMongoDBConfig().Database).Collection(config.GetMongoDBConfig().Collections.Products)
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.M{"slug": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "store_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "category_ids", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			// Titles and attributes are mixed language, words are indexed without stemming
+			Keys: bson.D{{Key: "title", Value: "text"}, {Key: "search_terms", Value: "text"}, {Key: "description", Value: "text"}},
+			Options: options.Index().
+				SetName("product_search").
+				SetWeights(bson.M{"title": 10, "search_terms": 5, "description": 1}).
+				SetDefaultLanguage("none"),
+		},
+		{
+			// Products without variants are left out, an empty array would otherwise be indexed as a duplicate
+			Keys:    bson.M{"variants.sku": 1},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"variants.sku": bson.M{"$exists": true}}),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+	return err
+}
+
+func createCategoryIndexes(client *mongo.Client) error {
+	collection := client.Database(config.GetMongoDBConfig().Database).Collection(config.GetMongoDBConfig().Collections.Categories)
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.M{"slug": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{"path": 1},
+		},
+		{
+			Keys: bson.D{{Key: "parent_id", Value: 1}, {Key: "position", Value: 1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
+	return err
+}
+
+func createMediaIndexes(client *mongo.Client) error {
+	collection := client.Database(config.GetMongoDBConfig().Database).Collection(config.GetMongoDBConfig().Collections.Media)
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "target", Value: 1}, {Key: "target_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "created_at", Value: -1}},
+		},
 	}
 
 	_, err := collection.Indexes().CreateMany(context.Background(), indexModels)
@@ -61,5 +217,5 @@ func createUserIndexes(client *mongo.Client) error {
 
 // GetCollection returns a collection
 func GetCollection(collectionName string) *mongo.Collection {
-	return client.Database(config.GetMongoDBConfig().Database).Collection(collectionName)
+	return getClient().Database(config.GetMongoDBConfig().Database).Collection(collectionName)
 }
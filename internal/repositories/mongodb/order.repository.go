@@ -0,0 +1,69 @@
+package mongodb
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+)
+
+type OrderMongoRepository interface {
+	GetOrdersByUserID(userId primitive.ObjectID) ([]bson.M, error)
+	AnonymizeUserOrders(userId primitive.ObjectID) error
+}
+
+type OrderMongoRepositoryImpl struct {
+	Collection *mongo.Collection
+}
+
+func NewOrderMongoRepository() OrderMongoRepository {
+	return &OrderMongoRepositoryImpl{
+		Collection: GetCollection(config.GetMongoDBConfig().Collections.Orders),
+	}
+}
+
+func (repository *OrderMongoRepositoryImpl) GetOrdersByUserID(userId primitive.ObjectID) ([]bson.M, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	cursor, err := repository.Collection.Find(ctx, bson.M{"user_id": userId})
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []bson.M{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// AnonymizeUserOrders unlinks the orders of a deleted user and removes the personal data on them,
+// the orders themselves are kept for bookkeeping
+func (repository *OrderMongoRepositoryImpl) AnonymizeUserOrders(userId primitive.ObjectID) error {
+	ctx, cancel := helpers.ContextWithTimeout(30)
+	defer cancel()
+
+	filter := bson.M{"user_id": userId}
+	update := bson.M{
+		"$set": bson.M{"anonymized_at": time.Now()},
+		"$unset": bson.M{
+			"user_id":          "",
+			"customer_name":    "",
+			"email":            "",
+			"phone_number":     "",
+			"shipping_address": "",
+			"billing_address":  "",
+		},
+	}
+	if _, err := repository.Collection.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+
+	return nil
+}
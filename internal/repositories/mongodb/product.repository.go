@@ -0,0 +1,524 @@
+package mongodb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+type ProductMongoRepository interface {
+	CreateProduct(product *models.Product) error
+	GetProductByID(id primitive.ObjectID) (*models.Product, error)
+	GetProductBySlug(slug string) (*models.Product, error)
+	GetProducts(filter models.ProductFilter, page int64, limit int64) ([]*models.Product, int64, error)
+	UpdateProduct(product *models.Product) (bool, error)
+	DeleteProduct(id primitive.ObjectID) (bool, error)
+	ArchiveProductsByStoreID(storeId primitive.ObjectID) error
+	SetProductOptions(id primitive.ObjectID, productOptions []models.ProductOption, updatedAt time.Time) (bool, error)
+	AddProductVariants(id primitive.ObjectID, variants []models.ProductVariant, maxVariants int) (bool, error)
+	UpdateProductVariant(id primitive.ObjectID, variant *models.ProductVariant) (bool, error)
+	RemoveProductVariant(id primitive.ObjectID, variantId primitive.ObjectID) (bool, error)
+	RemoveCategoryFromProducts(categoryId primitive.ObjectID) error
+	AddProductImage(id primitive.ObjectID, url string, maxImages int) (bool, error)
+	RemoveProductImage(id primitive.ObjectID, url string) error
+	SearchProducts(query models.ProductSearchQuery, after *models.ProductSearchAfter, priceBoundaries []int64) ([]*models.ProductSearchHit, *models.ProductSearchFacets, error)
+}
+
+type ProductMongoRepositoryImpl struct {
+	Collection *mongo.Collection
+}
+
+func NewProductMongoRepository() ProductMongoRepository {
+	return &ProductMongoRepositoryImpl{
+		Collection: GetCollection(config.GetMongoDBConfig().Collections.Products),
+	}
+}
+
+func (repository *ProductMongoRepositoryImpl) CreateProduct(product *models.Product) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	if _, err := repository.Collection.InsertOne(ctx, product); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *ProductMongoRepositoryImpl) GetProductByID(id primitive.ObjectID) (*models.Product, error) {
+	return repository.findOne(bson.M{"_id": id})
+}
+
+func (repository *ProductMongoRepositoryImpl) GetProductBySlug(slug string) (*models.Product, error) {
+	return repository.findOne(bson.M{"slug": slug})
+}
+
+func (repository *ProductMongoRepositoryImpl) findOne(filter bson.M) (*models.Product, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	var product models.Product
+	if err := repository.Collection.FindOne(ctx, filter).Decode(&product); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// GetProducts returns a page of the products matching the filter, newest first, and the total number of matches
+func (repository *ProductMongoRepositoryImpl) GetProducts(filter models.ProductFilter, page int64, limit int64) ([]*models.Product, int64, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.StoreID != nil {
+		query["store_id"] = filter.StoreID
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.SKU != "" {
+		query["variants.sku"] = filter.SKU
+	}
+	if filter.CategoryIDs != nil {
+		query["category_ids"] = bson.M{"$in": filter.CategoryIDs}
+	}
+
+	total, err := repository.Collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := repository.Collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	products := []*models.Product{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, 0, err
+	}
+
+	return products, total, nil
+}
+
+// UpdateProduct stores the editable fields of a product, its store and creation date never change
+func (repository *ProductMongoRepositoryImpl) UpdateProduct(product *models.Product) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": product.ID}
+	update := bson.M{"$set": bson.M{
+		"title":        product.Title,
+		"slug":         product.Slug,
+		"description":  product.Description,
+		"price":        product.Price,
+		"currency":     product.Currency,
+		"images":       product.Images,
+		"attributes":   product.Attributes,
+		"category_ids": product.CategoryIDs,
+		"search_terms": product.SearchTerms,
+		"status":       product.Status,
+		"updated_at":   product.UpdatedAt,
+	}}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+func (repository *ProductMongoRepositoryImpl) DeleteProduct(id primitive.ObjectID) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	result, err := repository.Collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount == 1, nil
+}
+
+// ArchiveProductsByStoreID takes every product of a store off sale, they are kept for the orders referencing them
+func (repository *ProductMongoRepositoryImpl) ArchiveProductsByStoreID(storeId primitive.ObjectID) error {
+	ctx, cancel := helpers.ContextWithTimeout(30)
+	defer cancel()
+
+	filter := bson.M{"store_id": storeId, "status": bson.M{"$ne": models.ProductStatusArchived}}
+	update := bson.M{"$set": bson.M{"status": models.ProductStatusArchived, "updated_at": time.Now()}}
+	if _, err := repository.Collection.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *ProductMongoRepositoryImpl) SetProductOptions(id primitive.ObjectID, productOptions []models.ProductOption, updatedAt time.Time) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"options": productOptions, "updated_at": updatedAt}}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+// AddProductVariants appends variants to a product unless one of their SKUs is already used by the
+// product or the product would have more than maxVariants variants. The unique index on variants.sku
+// only rejects SKUs used by other products.
+func (repository *ProductMongoRepositoryImpl) AddProductVariants(id primitive.ObjectID, variants []models.ProductVariant, maxVariants int) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	skus := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		skus = append(skus, variant.SKU)
+	}
+
+	filter := bson.M{
+		"_id":          id,
+		"variants.sku": bson.M{"$nin": skus},
+		fmt.Sprintf("variants.%d", maxVariants-len(variants)): bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$push": bson.M{"variants": bson.M{"$each": variants}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+// UpdateProductVariant replaces a variant of a product unless another variant of the product has its SKU
+func (repository *ProductMongoRepositoryImpl) UpdateProductVariant(id primitive.ObjectID, variant *models.ProductVariant) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":          id,
+		"variants._id": variant.ID,
+		"variants": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+			"sku": variant.SKU,
+			"_id": bson.M{"$ne": variant.ID},
+		}}},
+	}
+	update := bson.M{"$set": bson.M{
+		"variants.$[variant]": variant,
+		"updated_at":          variant.UpdatedAt,
+	}}
+	updateOptions := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"variant._id": variant.ID}},
+	})
+	result, err := repository.Collection.UpdateOne(ctx, filter, update, updateOptions)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+func (repository *ProductMongoRepositoryImpl) RemoveProductVariant(id primitive.ObjectID, variantId primitive.ObjectID) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "variants._id": variantId}
+	update := bson.M{
+		"$pull": bson.M{"variants": bson.M{"_id": variantId}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+// RemoveCategoryFromProducts unassigns a deleted category from every product
+func (repository *ProductMongoRepositoryImpl) RemoveCategoryFromProducts(categoryId primitive.ObjectID) error {
+	ctx, cancel := helpers.ContextWithTimeout(30)
+	defer cancel()
+
+	filter := bson.M{"category_ids": categoryId}
+	update := bson.M{
+		"$pull": bson.M{"category_ids": categoryId},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	if _, err := repository.Collection.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AddProductImage appends an image to a product unless it already has maxImages images
+func (repository *ProductMongoRepositoryImpl) AddProductImage(id primitive.ObjectID, url string, maxImages int) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": id, fmt.Sprintf("images.%d", maxImages-1): bson.M{"$exists": false}}
+	update := bson.M{
+		"$push": bson.M{"images": url},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+func (repository *ProductMongoRepositoryImpl) RemoveProductImage(id primitive.ObjectID, url string) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "images": url}
+	update := bson.M{
+		"$pull": bson.M{"images": url},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	if _, err := repository.Collection.UpdateOne(ctx, filter, update); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type searchFacetBucket struct {
+	ID    interface{} `bson:"_id"`
+	Count int64       `bson:"count"`
+}
+
+type searchAttributeBucket struct {
+	ID struct {
+		Key   string `bson:"k"`
+		Value string `bson:"v"`
+	} `bson:"_id"`
+	Count int64 `bson:"count"`
+}
+
+type searchFacetResult struct {
+	Results    []*models.ProductSearchHit `bson:"results"`
+	Total      []searchFacetBucket        `bson:"total"`
+	Categories []searchFacetBucket        `bson:"categories"`
+	Brands     []searchFacetBucket        `bson:"brands"`
+	Prices     []searchFacetBucket        `bson:"prices"`
+	Attributes []searchAttributeBucket    `bson:"attributes"`
+}
+
+// SearchProducts returns up to query.Limit active products matching the query after the given position,
+// with the facets of all matching products when they are requested. Prices are counted in the ranges
+// between the boundaries and the highest range is reported without an upper end.
+func (repository *ProductMongoRepositoryImpl) SearchProducts(query models.ProductSearchQuery, after *models.ProductSearchAfter, priceBoundaries []int64) ([]*models.ProductSearchHit, *models.ProductSearchFacets, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	match := bson.M{"status": models.ProductStatusActive}
+	if query.Text != "" {
+		match["$text"] = bson.M{"$search": query.Text}
+	}
+	if query.CategoryIDs != nil {
+		match["category_ids"] = bson.M{"$in": query.CategoryIDs}
+	}
+	if query.MinPrice != nil || query.MaxPrice != nil {
+		price := bson.M{}
+		if query.MinPrice != nil {
+			price["$gte"] = *query.MinPrice
+		}
+		if query.MaxPrice != nil {
+			price["$lte"] = *query.MaxPrice
+		}
+		match["price"] = price
+	}
+	if query.Brand != "" {
+		match["attributes.brand"] = query.Brand
+	}
+	for key, value := range query.Attributes {
+		match["attributes."+key] = value
+	}
+
+	var sortValue interface{}
+	direction := -1
+	switch query.Sort {
+	case models.ProductSearchSortRelevance:
+		sortValue = bson.M{"$meta": "textScore"}
+	case models.ProductSearchSortPriceAsc:
+		sortValue = "$price"
+		direction = 1
+	case models.ProductSearchSortPriceDesc:
+		sortValue = "$price"
+	case models.ProductSearchSortRating:
+		sortValue = bson.M{"$ifNull": bson.A{"$rating", 0}}
+	default:
+		sortValue = "$created_at"
+	}
+
+	results := mongo.Pipeline{}
+	if after != nil {
+		operator := "$lt"
+		if direction == 1 {
+			operator = "$gt"
+		}
+
+		results = append(results, bson.D{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"sort_value": bson.M{operator: after.SortValue}},
+			bson.M{"sort_value": after.SortValue, "_id": bson.M{operator: after.ID}},
+		}}}})
+	}
+	results = append(results,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "sort_value", Value: direction}, {Key: "_id", Value: direction}}}},
+		bson.D{{Key: "$limit", Value: query.Limit}},
+	)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$addFields", Value: bson.M{"sort_value": sortValue}}},
+	}
+
+	if !query.WithFacets {
+		cursor, err := repository.Collection.Aggregate(ctx, append(pipeline, results...))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		hits := []*models.ProductSearchHit{}
+		if err := cursor.All(ctx, &hits); err != nil {
+			return nil, nil, err
+		}
+
+		return hits, nil, nil
+	}
+
+	boundaries := bson.A{}
+	for _, boundary := range priceBoundaries {
+		boundaries = append(boundaries, boundary)
+	}
+
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: bson.M{
+		"results": results,
+		"total":   bson.A{bson.M{"$count": "count"}},
+		"categories": bson.A{
+			bson.M{"$unwind": "$category_ids"},
+			bson.M{"$group": bson.M{"_id": "$category_ids", "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			bson.M{"$limit": 20},
+		},
+		"brands": bson.A{
+			bson.M{"$match": bson.M{"attributes.brand": bson.M{"$nin": bson.A{nil, ""}}}},
+			bson.M{"$group": bson.M{"_id": "$attributes.brand", "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			bson.M{"$limit": 20},
+		},
+		"prices": bson.A{
+			bson.M{"$bucket": bson.M{
+				"groupBy":    "$price",
+				"boundaries": boundaries,
+				"default":    "other",
+			}},
+		},
+		"attributes": bson.A{
+			bson.M{"$project": bson.M{"attributes": bson.M{"$objectToArray": "$attributes"}}},
+			bson.M{"$unwind": "$attributes"},
+			bson.M{"$match": bson.M{"attributes.k": bson.M{"$ne": "brand"}}},
+			bson.M{"$group": bson.M{"_id": "$attributes", "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id.k", Value: 1}, {Key: "_id.v", Value: 1}}},
+			bson.M{"$limit": 100},
+		},
+	}}})
+
+	cursor, err := repository.Collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var facetResults []searchFacetResult
+	if err := cursor.All(ctx, &facetResults); err != nil {
+		return nil, nil, err
+	}
+
+	if len(facetResults) == 0 {
+		return []*models.ProductSearchHit{}, &models.ProductSearchFacets{}, nil
+	}
+
+	result := facetResults[0]
+	facets := &models.ProductSearchFacets{
+		Categories:  []models.FacetCount{},
+		Brands:      []models.FacetCount{},
+		PriceRanges: []models.PriceRangeFacet{},
+		Attributes:  map[string][]models.FacetCount{},
+	}
+
+	if len(result.Total) > 0 {
+		facets.Total = result.Total[0].Count
+	}
+
+	for _, bucket := range result.Categories {
+		if id, ok := bucket.ID.(primitive.ObjectID); ok {
+			facets.Categories = append(facets.Categories, models.FacetCount{Value: id.Hex(), Count: bucket.Count})
+		}
+	}
+
+	for _, bucket := range result.Brands {
+		if brand, ok := bucket.ID.(string); ok {
+			facets.Brands = append(facets.Brands, models.FacetCount{Value: brand, Count: bucket.Count})
+		}
+	}
+
+	// Buckets are identified by their lower boundary, products priced outside of the boundaries are not counted
+	for _, bucket := range result.Prices {
+		min, ok := bucket.ID.(int64)
+		if !ok {
+			continue
+		}
+
+		priceRange := models.PriceRangeFacet{Min: min, Count: bucket.Count}
+		for i := 0; i < len(priceBoundaries)-2; i++ {
+			if priceBoundaries[i] == min {
+				max := priceBoundaries[i+1]
+				priceRange.Max = &max
+			}
+		}
+		facets.PriceRanges = append(facets.PriceRanges, priceRange)
+	}
+
+	for _, bucket := range result.Attributes {
+		facets.Attributes[bucket.ID.Key] = append(facets.Attributes[bucket.ID.Key], models.FacetCount{
+			Value: bucket.ID.Value,
+			Count: bucket.Count,
+		})
+	}
+
+	if result.Results == nil {
+		result.Results = []*models.ProductSearchHit{}
+	}
+
+	return result.Results, facets, nil
+}
@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"errors"
+	"fmt"
 	"github.com/mercan/ecommerce/internal/config"
 	"github.com/mercan/ecommerce/internal/helpers"
 	"github.com/mercan/ecommerce/internal/models"
@@ -14,15 +15,42 @@ import (
 
 type UserMongoRepository interface {
 	CreateUser(user *models.User) error
-	ChangePassword(userId primitive.ObjectID, password string) error
-	ChangeEmail(userId primitive.ObjectID, email string) error
+	ChangePassword(userId primitive.ObjectID, password string, passwordHistory []string) error
+	UpgradePasswordHash(userId primitive.ObjectID, oldHash string, newHash string) error
+	SetPendingEmail(userId primitive.ObjectID, email string) error
+	CommitPendingEmail(userId primitive.ObjectID, email string) (bool, error)
+	RevertEmail(userId primitive.ObjectID, email string, newEmail string, emailVerified bool) (bool, error)
+	ChangePhone(userId primitive.ObjectID, phoneNumber string) error
+	ChangeRole(userId primitive.ObjectID, role models.Role) error
 	GetUserByID(id primitive.ObjectID) (*models.User, error)
+	CheckUserActive(userId primitive.ObjectID) (bool, error)
+	RequirePasswordReset(userId primitive.ObjectID) error
+	Deactivate(userId primitive.ObjectID, deletionScheduledAt *time.Time) error
+	Reactivate(userId primitive.ObjectID) (bool, error)
+	GetUsersDueForDeletion(now time.Time) ([]*models.User, error)
+	DeleteScheduledUser(userId primitive.ObjectID, now time.Time) (bool, error)
 	GetUserByEmail(email string, options *options.FindOneOptions) (*models.User, error)
+	GetUserByPhone(phoneNumber string) (*models.User, error)
+	GetUserByLinkedIdentity(provider string, subject string) (*models.User, error)
+	AddLinkedIdentity(userId primitive.ObjectID, identity models.LinkedIdentity) (bool, error)
+	RemoveLinkedIdentity(userId primitive.ObjectID, provider string) (bool, error)
 	CheckPhoneExists(phoneNumber string) (bool, error)
 	CheckEmailExists(email string) (bool, error)
 	CheckEmailVerified(userId primitive.ObjectID) (bool, error)
 	UpdateEmailVerificationStatus(userId primitive.ObjectID) error
 	UpdatePhoneVerificationStatus(userId primitive.ObjectID) error
+	SetTwoFactorPendingSecret(userId primitive.ObjectID, secret string) error
+	EnableTwoFactor(userId primitive.ObjectID, secret string, recoveryCodes []string) error
+	DisableTwoFactor(userId primitive.ObjectID) error
+	SetRecoveryCodes(userId primitive.ObjectID, recoveryCodes []string) error
+	UseRecoveryCode(userId primitive.ObjectID, recoveryCode string) (bool, error)
+	GetUserByWebAuthnCredentialID(credentialId string) (*models.User, error)
+	AddWebAuthnCredential(userId primitive.ObjectID, credential models.WebAuthnCredential, maxCredentials int) (bool, error)
+	UpdateWebAuthnSignCount(userId primitive.ObjectID, credentialId string, oldSignCount uint32, signCount uint32) (bool, error)
+	RemoveWebAuthnCredential(userId primitive.ObjectID, credentialId string) (bool, error)
+	RemoveWebAuthnCredentialsSince(userId primitive.ObjectID, since time.Time) error
+	SetImage(userId primitive.ObjectID, field string, url string) error
+	UnsetImage(userId primitive.ObjectID, field string, url string) error
 }
 
 type UserMongoRepositoryImpl struct {
@@ -46,7 +74,9 @@ func (repository *UserMongoRepositoryImpl) CreateUser(user *models.User) error {
 	return nil
 }
 
-func (repository *UserMongoRepositoryImpl) ChangePassword(userId primitive.ObjectID, password string) error {
+// ChangePassword hashes and stores a new password together with the hashes of the previous ones.
+// A required password reset is fulfilled by any new password.
+func (repository *UserMongoRepositoryImpl) ChangePassword(userId primitive.ObjectID, password string, passwordHistory []string) error {
 	ctx, cancel := helpers.ContextWithTimeout(10)
 	defer cancel()
 
@@ -56,7 +86,14 @@ func (repository *UserMongoRepositoryImpl) ChangePassword(userId primitive.Objec
 	}
 
 	filter := bson.M{"_id": userId}
-	update := bson.M{"$set": bson.M{"password": hashedPassword, "updated_at": time.Now()}}
+	set := bson.M{"password": hashedPassword, "updated_at": time.Now()}
+	unset := bson.M{"password_reset_required": ""}
+	if len(passwordHistory) > 0 {
+		set["password_history"] = passwordHistory
+	} else {
+		unset["password_history"] = ""
+	}
+	update := bson.M{"$set": set, "$unset": unset}
 	_, err = repository.Collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
@@ -65,12 +102,99 @@ func (repository *UserMongoRepositoryImpl) ChangePassword(userId primitive.Objec
 	return nil
 }
 
-func (repository *UserMongoRepositoryImpl) ChangeEmail(userId primitive.ObjectID, email string) error {
+// UpgradePasswordHash replaces a password hash with a rehash of the same password,
+// unless the password was changed in the meantime
+func (repository *UserMongoRepositoryImpl) UpgradePasswordHash(userId primitive.ObjectID, oldHash string, newHash string) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, "password": oldHash}
+	update := bson.M{"$set": bson.M{"password": newHash}}
+	_, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetPendingEmail stages an email change, the email is only changed by CommitPendingEmail
+func (repository *UserMongoRepositoryImpl) SetPendingEmail(userId primitive.ObjectID, email string) error {
 	ctx, cancel := helpers.ContextWithTimeout(10)
 	defer cancel()
 
 	filter := bson.M{"_id": userId}
-	update := bson.M{"$set": bson.M{"email": email, "email_verified": false, "updated_at": time.Now()}}
+	update := bson.M{"$set": bson.M{"pending_email": email, "updated_at": time.Now()}}
+	_, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CommitPendingEmail replaces the email with the verified pending email and reports whether
+// the given email was still pending
+func (repository *UserMongoRepositoryImpl) CommitPendingEmail(userId primitive.ObjectID, email string) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, "pending_email": email}
+	update := bson.M{
+		"$set":   bson.M{"email": email, "email_verified": true, "updated_at": time.Now()},
+		"$unset": bson.M{"pending_email": ""},
+	}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// RevertEmail restores an email and cancels any pending email change. It only applies while newEmail,
+// the address the change was made to, is still the email or the pending email of the user, and
+// reports whether it did.
+func (repository *UserMongoRepositoryImpl) RevertEmail(userId primitive.ObjectID, email string, newEmail string, emailVerified bool) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, "$or": bson.A{
+		bson.M{"email": newEmail},
+		bson.M{"email": email, "pending_email": newEmail},
+	}}
+	update := bson.M{
+		"$set":   bson.M{"email": email, "email_verified": emailVerified, "updated_at": time.Now()},
+		"$unset": bson.M{"pending_email": ""},
+	}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+func (repository *UserMongoRepositoryImpl) ChangePhone(userId primitive.ObjectID, phoneNumber string) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId}
+	update := bson.M{"$set": bson.M{"phone_number": phoneNumber, "phone_number_verified": false, "updated_at": time.Now()}}
+	_, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *UserMongoRepositoryImpl) ChangeRole(userId primitive.ObjectID, role models.Role) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId}
+	update := bson.M{"$set": bson.M{"role": role, "updated_at": time.Now()}}
 	_, err := repository.Collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
@@ -123,11 +247,32 @@ func (repository *UserMongoRepositoryImpl) GetUserByEmail(email string, options
 	return user, nil
 }
 
+// GetUserByPhone returns the user who verified a phone number, other users may have entered it without
+// verifying it
+func (repository *UserMongoRepositoryImpl) GetUserByPhone(phoneNumber string) (*models.User, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"phone_number": phoneNumber, "phone_number_verified": true}
+
+	var user *models.User
+	if err := repository.Collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CheckPhoneExists reports whether a user verified a phone number, unverified numbers are not taken
 func (repository *UserMongoRepositoryImpl) CheckPhoneExists(phoneNumber string) (bool, error) {
 	ctx, cancel := helpers.ContextWithTimeout(10)
 	defer cancel()
 
-	filter := bson.M{"phone_number": phoneNumber}
+	filter := bson.M{"phone_number": phoneNumber, "phone_number_verified": true}
 	count, err := repository.Collection.CountDocuments(ctx, filter)
 	if err != nil {
 		return false, err
@@ -140,8 +285,8 @@ func (repository *UserMongoRepositoryImpl) CheckEmailExists(email string) (bool,
 	ctx, cancel := helpers.ContextWithTimeout(10)
 	defer cancel()
 
-	filter := bson.D{{"email", email}}
-	project := bson.D{{"_id", 0}, {"email", 1}}
+	filter := bson.D{{Key: "email", Value: email}}
+	project := bson.D{{Key: "_id", Value: 0}, {Key: "email", Value: 1}}
 	setProjection := options.FindOne().SetProjection(project)
 
 	var result bson.M
@@ -197,3 +342,351 @@ func (repository *UserMongoRepositoryImpl) UpdatePhoneVerificationStatus(userId
 
 	return nil
 }
+
+func (repository *UserMongoRepositoryImpl) SetTwoFactorPendingSecret(userId primitive.ObjectID, secret string) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId}
+	update := bson.M{"$set": bson.M{"two_factor.pending_secret": secret, "updated_at": time.Now()}}
+	_, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *UserMongoRepositoryImpl) EnableTwoFactor(userId primitive.ObjectID, secret string, recoveryCodes []string) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId}
+	update := bson.M{
+		"$set": bson.M{
+			"two_factor.enabled":        true,
+			"two_factor.secret":         secret,
+			"two_factor.recovery_codes": recoveryCodes,
+			"two_factor.enabled_at":     time.Now(),
+			"updated_at":                time.Now(),
+		},
+		"$unset": bson.M{"two_factor.pending_secret": ""},
+	}
+	_, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *UserMongoRepositoryImpl) DisableTwoFactor(userId primitive.ObjectID) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId}
+	update := bson.M{"$unset": bson.M{"two_factor": ""}, "$set": bson.M{"updated_at": time.Now()}}
+	_, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *UserMongoRepositoryImpl) SetRecoveryCodes(userId primitive.ObjectID, recoveryCodes []string) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId}
+	update := bson.M{"$set": bson.M{"two_factor.recovery_codes": recoveryCodes, "updated_at": time.Now()}}
+	_, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UseRecoveryCode atomically removes a hashed recovery code and reports whether it was present
+func (repository *UserMongoRepositoryImpl) UseRecoveryCode(userId primitive.ObjectID, recoveryCode string) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, "two_factor.recovery_codes": recoveryCode}
+	update := bson.M{"$pull": bson.M{"two_factor.recovery_codes": recoveryCode}, "$set": bson.M{"updated_at": time.Now()}}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+func (repository *UserMongoRepositoryImpl) GetUserByLinkedIdentity(provider string, subject string) (*models.User, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"linked_identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}
+
+	var user *models.User
+	if err := repository.Collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// AddLinkedIdentity links an identity and reports whether it was added,
+// a user can only have one identity per provider
+func (repository *UserMongoRepositoryImpl) AddLinkedIdentity(userId primitive.ObjectID, identity models.LinkedIdentity) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, "linked_identities.provider": bson.M{"$ne": identity.Provider}}
+	update := bson.M{"$push": bson.M{"linked_identities": identity}, "$set": bson.M{"updated_at": time.Now()}}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// RemoveLinkedIdentity unlinks the identity of a provider and reports whether it was linked
+func (repository *UserMongoRepositoryImpl) RemoveLinkedIdentity(userId primitive.ObjectID, provider string) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, "linked_identities.provider": provider}
+	update := bson.M{"$pull": bson.M{"linked_identities": bson.M{"provider": provider}}, "$set": bson.M{"updated_at": time.Now()}}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+func (repository *UserMongoRepositoryImpl) GetUserByWebAuthnCredentialID(credentialId string) (*models.User, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"webauthn_credentials.credential_id": credentialId}
+
+	var user *models.User
+	if err := repository.Collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// AddWebAuthnCredential registers a credential and reports whether it was added,
+// it isn't when the user already has maxCredentials credentials
+func (repository *UserMongoRepositoryImpl) AddWebAuthnCredential(userId primitive.ObjectID, credential models.WebAuthnCredential, maxCredentials int) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, fmt.Sprintf("webauthn_credentials.%d", maxCredentials-1): bson.M{"$exists": false}}
+	update := bson.M{"$push": bson.M{"webauthn_credentials": credential}, "$set": bson.M{"updated_at": time.Now()}}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// UpdateWebAuthnSignCount records a use of a credential. It only succeeds while the stored counter is
+// still oldSignCount, so that two assertions with the same counter can't both be accepted.
+func (repository *UserMongoRepositoryImpl) UpdateWebAuthnSignCount(userId primitive.ObjectID, credentialId string, oldSignCount uint32, signCount uint32) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":                  userId,
+		"webauthn_credentials": bson.M{"$elemMatch": bson.M{"credential_id": credentialId, "sign_count": oldSignCount}},
+	}
+	update := bson.M{"$set": bson.M{
+		"webauthn_credentials.$.sign_count":   signCount,
+		"webauthn_credentials.$.last_used_at": time.Now(),
+	}}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+// RemoveWebAuthnCredential removes a credential and reports whether the user had it
+func (repository *UserMongoRepositoryImpl) RemoveWebAuthnCredential(userId primitive.ObjectID, credentialId string) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, "webauthn_credentials.credential_id": credentialId}
+	update := bson.M{"$pull": bson.M{"webauthn_credentials": bson.M{"credential_id": credentialId}}, "$set": bson.M{"updated_at": time.Now()}}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// RemoveWebAuthnCredentialsSince removes the credentials registered at or after a time
+func (repository *UserMongoRepositoryImpl) RemoveWebAuthnCredentialsSince(userId primitive.ObjectID, since time.Time) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId}
+	update := bson.M{"$pull": bson.M{"webauthn_credentials": bson.M{"created_at": bson.M{"$gte": since}}}, "$set": bson.M{"updated_at": time.Now()}}
+	if _, err := repository.Collection.UpdateOne(ctx, filter, update); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *UserMongoRepositoryImpl) CheckUserActive(userId primitive.ObjectID) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, "is_active": true}
+	count, err := repository.Collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// RequirePasswordReset blocks password logins of a user until the password is reset
+func (repository *UserMongoRepositoryImpl) RequirePasswordReset(userId primitive.ObjectID) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId}
+	update := bson.M{"$set": bson.M{"password_reset_required": true, "updated_at": time.Now()}}
+	_, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Deactivate deactivates a user, when deletionScheduledAt is set the user is deleted at that time
+func (repository *UserMongoRepositoryImpl) Deactivate(userId primitive.ObjectID, deletionScheduledAt *time.Time) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"_id": userId}
+	set := bson.M{"is_active": false, "deactivated_at": now, "updated_at": now}
+	update := bson.M{"$set": set}
+	if deletionScheduledAt != nil {
+		set["deletion_scheduled_at"] = deletionScheduledAt
+	} else {
+		update["$unset"] = bson.M{"deletion_scheduled_at": ""}
+	}
+
+	_, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Reactivate reactivates an account and cancels its scheduled deletion. Accounts whose deletion grace
+// period is over may already be purged and are not reactivated, it reports whether the account was.
+func (repository *UserMongoRepositoryImpl) Reactivate(userId primitive.ObjectID) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, "$or": bson.A{
+		bson.M{"deletion_scheduled_at": bson.M{"$exists": false}},
+		bson.M{"deletion_scheduled_at": bson.M{"$gt": time.Now()}},
+	}}
+	update := bson.M{
+		"$set":   bson.M{"is_active": true, "updated_at": time.Now()},
+		"$unset": bson.M{"deactivated_at": "", "deletion_scheduled_at": ""},
+	}
+	result, err := repository.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount == 1, nil
+}
+
+func (repository *UserMongoRepositoryImpl) GetUsersDueForDeletion(now time.Time) ([]*models.User, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"is_active": false, "deletion_scheduled_at": bson.M{"$lte": now}}
+	cursor, err := repository.Collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// DeleteScheduledUser deletes a user whose deletion grace period is over and reports whether it did,
+// a user that was reactivated in the meantime is kept
+func (repository *UserMongoRepositoryImpl) DeleteScheduledUser(userId primitive.ObjectID, now time.Time) (bool, error) {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, "is_active": false, "deletion_scheduled_at": bson.M{"$lte": now}}
+	result, err := repository.Collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount == 1, nil
+}
+
+// SetImage sets the profile_image or banner_image of a user
+func (repository *UserMongoRepositoryImpl) SetImage(userId primitive.ObjectID, field string, url string) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId}
+	update := bson.M{"$set": bson.M{field: url, "updated_at": time.Now()}}
+	if _, err := repository.Collection.UpdateOne(ctx, filter, update); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UnsetImage removes the profile_image or banner_image of a user if it is still the given image
+func (repository *UserMongoRepositoryImpl) UnsetImage(userId primitive.ObjectID, field string, url string) error {
+	ctx, cancel := helpers.ContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"_id": userId, field: url}
+	update := bson.M{"$unset": bson.M{field: ""}, "$set": bson.M{"updated_at": time.Now()}}
+	if _, err := repository.Collection.UpdateOne(ctx, filter, update); err != nil {
+		return err
+	}
+
+	return nil
+}
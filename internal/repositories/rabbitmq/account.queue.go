@@ -0,0 +1,109 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/streadway/amqp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/services"
+)
+
+type AccountQueueManager interface {
+	PublishDataExport(userId string)
+	ConsumeDataExportQueue()
+}
+
+type AccountQueueManagerImpl struct {
+	Channel         *amqp.Channel
+	DataExportQueue string
+	AccountService  services.AccountService
+	MailService     services.MailService
+}
+
+func NewAccountQueueManager() AccountQueueManager {
+	return &AccountQueueManagerImpl{
+		Channel:         channel,
+		DataExportQueue: config.GetRabbitMQConfig().DataExportQueue,
+		AccountService:  services.NewAccountService(),
+		MailService:     services.NewMailService(),
+	}
+}
+
+func (queue *AccountQueueManagerImpl) PublishDataExport(userId string) {
+	body, err := json.Marshal(map[string]string{"userId": userId})
+	if err != nil {
+		log.Printf(" [X] Failed to marshal data export message: %s", err.Error())
+		return
+	}
+
+	err = queue.Channel.Publish(
+		"",
+		queue.DataExportQueue,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+		},
+	)
+	if err != nil {
+		log.Printf(" [X] Failed to publish data export: %s", err.Error())
+		return
+	}
+
+	log.Printf(" [X] Published Data Export Message: %s", userId)
+}
+
+func (queue *AccountQueueManagerImpl) ConsumeDataExportQueue() {
+	msgs, err := channel.Consume(
+		config.GetRabbitMQConfig().DataExportQueue,
+		"",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	forever := make(chan bool)
+
+	go func() {
+		for d := range msgs {
+			var message map[string]string
+			if err := json.Unmarshal(d.Body, &message); err != nil {
+				fmt.Println("Error while unmarshalling: ", err.Error())
+				continue
+			}
+
+			log.Printf(" [X] Received Data Export Message: %s", message["userId"])
+			userId, err := primitive.ObjectIDFromHex(message["userId"])
+			if err != nil {
+				fmt.Println("Error while parsing user id: ", err.Error())
+				continue
+			}
+
+			email, archive, err := queue.AccountService.BuildDataExport(userId)
+			if err != nil {
+				fmt.Println("Error while building data export: ", err.Error())
+				continue
+			}
+
+			if err := queue.MailService.SendDataExportEmail(email, archive); err != nil {
+				fmt.Println("Error while sending data export email: ", err.Error())
+				continue
+			}
+
+			log.Printf(" [X] Data Export Sent: %s", message["userId"])
+		}
+	}()
+
+	log.Printf(" [*] Data Export Queue is waiting for messages...")
+	<-forever
+}
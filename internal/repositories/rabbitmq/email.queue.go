@@ -6,26 +6,39 @@ import (
 	"log"
 
 	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/models"
 	"github.com/mercan/ecommerce/internal/services"
 	"github.com/streadway/amqp"
 )
 
 type EmailQueueManager interface {
 	PublishEmailVerification(firstName, email string)
+	PublishForgotPassword(email string)
+	PublishSecurityNotification(notification models.SecurityNotification)
+	PublishMagicLink(email string)
 	ConsumeEmailVerificationQueue()
+	ConsumeForgotPasswordQueue()
+	ConsumeSecurityNotificationQueue()
+	ConsumeMagicLinkQueue()
 }
 
 type EmailQueueManagerImpl struct {
-	Channel                *amqp.Channel
-	EmailVerificationQueue string
-	MailService            services.MailService
+	Channel                   *amqp.Channel
+	EmailVerificationQueue    string
+	ForgotPasswordQueue       string
+	SecurityNotificationQueue string
+	MagicLinkQueue            string
+	MailService               services.MailService
 }
 
 func NewEmailQueueManager() EmailQueueManager {
 	return &EmailQueueManagerImpl{
-		Channel:                channel,
-		EmailVerificationQueue: config.GetRabbitMQConfig().EmailVerificationQueue,
-		MailService:            services.NewMailService(),
+		Channel:                   channel,
+		EmailVerificationQueue:    config.GetRabbitMQConfig().EmailVerificationQueue,
+		ForgotPasswordQueue:       config.GetRabbitMQConfig().ForgotPasswordQueue,
+		SecurityNotificationQueue: config.GetRabbitMQConfig().SecurityNotificationQueue,
+		MagicLinkQueue:            config.GetRabbitMQConfig().MagicLinkQueue,
+		MailService:               services.NewMailService(),
 	}
 }
 
@@ -49,6 +62,81 @@ func (queue *EmailQueueManagerImpl) PublishEmailVerification(firstName, email st
 	log.Printf(" [X] Published Message: %s", body)
 }
 
+func (queue *EmailQueueManagerImpl) PublishForgotPassword(email string) {
+	body, err := json.Marshal(map[string]string{"email": email})
+	if err != nil {
+		log.Printf(" [X] Failed to marshal forgot password message: %s", err.Error())
+		return
+	}
+
+	err = queue.Channel.Publish(
+		"",
+		queue.ForgotPasswordQueue,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+		},
+	)
+	if err != nil {
+		log.Printf(" [X] Failed to publish forgot password: %s", err.Error())
+		return
+	}
+
+	log.Printf(" [X] Published Forgot Password Message: %s", email)
+}
+
+func (queue *EmailQueueManagerImpl) PublishSecurityNotification(notification models.SecurityNotification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.Printf(" [X] Failed to marshal security notification message: %s", err.Error())
+		return
+	}
+
+	err = queue.Channel.Publish(
+		"",
+		queue.SecurityNotificationQueue,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+		},
+	)
+	if err != nil {
+		log.Printf(" [X] Failed to publish security notification: %s", err.Error())
+		return
+	}
+
+	log.Printf(" [X] Published Security Notification Message Type: %s Email: %s", notification.Type, notification.Email)
+}
+
+func (queue *EmailQueueManagerImpl) PublishMagicLink(email string) {
+	body, err := json.Marshal(map[string]string{"email": email})
+	if err != nil {
+		log.Printf(" [X] Failed to marshal magic link message: %s", err.Error())
+		return
+	}
+
+	err = queue.Channel.Publish(
+		"",
+		queue.MagicLinkQueue,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+		},
+	)
+	if err != nil {
+		log.Printf(" [X] Failed to publish magic link: %s", err.Error())
+		return
+	}
+
+	log.Printf(" [X] Published Magic Link Message: %s", email)
+}
+
 func (queue *EmailQueueManagerImpl) ConsumeEmailVerificationQueue() {
 	msgs, err := channel.Consume(
 		config.GetRabbitMQConfig().EmailVerificationQueue,
@@ -88,3 +176,117 @@ func (queue *EmailQueueManagerImpl) ConsumeEmailVerificationQueue() {
 	log.Printf(" [*] Email Verification Queue is waiting for messages...")
 	<-forever
 }
+
+func (queue *EmailQueueManagerImpl) ConsumeForgotPasswordQueue() {
+	msgs, err := channel.Consume(
+		config.GetRabbitMQConfig().ForgotPasswordQueue,
+		"",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	forever := make(chan bool)
+
+	go func() {
+		for d := range msgs {
+			var user map[string]string
+			if err := json.Unmarshal(d.Body, &user); err != nil {
+				fmt.Println("Error while unmarshalling: ", err.Error())
+				continue
+			}
+
+			log.Printf(" [X] Received Forgot Password Message Email: %s", user["email"])
+			if err := queue.MailService.SendForgotPasswordEmail(user["email"]); err != nil {
+				fmt.Println("Error while sending forgot password email: ", err.Error())
+				continue
+			}
+
+			log.Printf(" [X] Forgot Password Message Sent Email: %s", user["email"])
+		}
+	}()
+
+	log.Printf(" [*] Forgot Password Queue is waiting for messages...")
+	<-forever
+}
+
+func (queue *EmailQueueManagerImpl) ConsumeSecurityNotificationQueue() {
+	msgs, err := channel.Consume(
+		config.GetRabbitMQConfig().SecurityNotificationQueue,
+		"",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	forever := make(chan bool)
+
+	go func() {
+		for d := range msgs {
+			var notification models.SecurityNotification
+			if err := json.Unmarshal(d.Body, &notification); err != nil {
+				fmt.Println("Error while unmarshalling: ", err.Error())
+				continue
+			}
+
+			log.Printf(" [X] Received Security Notification Message Type: %s Email: %s", notification.Type, notification.Email)
+			if err := queue.MailService.SendSecurityNotificationEmail(notification); err != nil {
+				fmt.Println("Error while sending security notification email: ", err.Error())
+				continue
+			}
+
+			log.Printf(" [X] Security Notification Message Sent Type: %s Email: %s", notification.Type, notification.Email)
+		}
+	}()
+
+	log.Printf(" [*] Security Notification Queue is waiting for messages...")
+	<-forever
+}
+
+func (queue *EmailQueueManagerImpl) ConsumeMagicLinkQueue() {
+	msgs, err := channel.Consume(
+		config.GetRabbitMQConfig().MagicLinkQueue,
+		"",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	forever := make(chan bool)
+
+	go func() {
+		for d := range msgs {
+			var user map[string]string
+			if err := json.Unmarshal(d.Body, &user); err != nil {
+				fmt.Println("Error while unmarshalling: ", err.Error())
+				continue
+			}
+
+			log.Printf(" [X] Received Magic Link Message Email: %s", user["email"])
+			if err := queue.MailService.SendMagicLinkEmail(user["email"]); err != nil {
+				fmt.Println("Error while sending magic link email: ", err.Error())
+				continue
+			}
+
+			log.Printf(" [X] Magic Link Message Sent Email: %s", user["email"])
+		}
+	}()
+
+	log.Printf(" [*] Magic Link Queue is waiting for messages...")
+	<-forever
+}
@@ -12,12 +12,15 @@ import (
 
 type PhoneQueueManager interface {
 	PublishPhoneVerification(phone string)
+	PublishLoginOTP(phone string)
 	ConsumePhoneVerificationQueue()
+	ConsumeLoginOTPQueue()
 }
 
 type PhoneQueueManagerImpl struct {
 	Channel                *amqp.Channel
 	PhoneVerificationQueue string
+	LoginOTPQueue          string
 	SMSService             services.SMSService
 }
 
@@ -25,6 +28,7 @@ func NewPhoneQueueManager() PhoneQueueManager {
 	return &PhoneQueueManagerImpl{
 		Channel:                channel,
 		PhoneVerificationQueue: config.GetRabbitMQConfig().PhoneVerificationQueue,
+		LoginOTPQueue:          config.GetRabbitMQConfig().LoginOTPQueue,
 		SMSService:             services.NewSMSService(),
 	}
 }
@@ -48,6 +52,31 @@ func (queue *PhoneQueueManagerImpl) PublishPhoneVerification(phone string) {
 	log.Printf(" [X] Sent Phone %s", body)
 }
 
+func (queue *PhoneQueueManagerImpl) PublishLoginOTP(phone string) {
+	body, err := json.Marshal(map[string]string{"phone": phone})
+	if err != nil {
+		log.Printf(" [X] Failed to marshal login code message: %s", err.Error())
+		return
+	}
+
+	err = queue.Channel.Publish(
+		"",
+		queue.LoginOTPQueue,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+		},
+	)
+	if err != nil {
+		log.Printf(" [X] Failed to publish login code: %s", err.Error())
+		return
+	}
+
+	log.Printf(" [X] Sent Login Code Phone %s", phone)
+}
+
 func (queue *PhoneQueueManagerImpl) ConsumePhoneVerificationQueue() {
 	msgs, err := channel.Consume(
 		config.GetRabbitMQConfig().PhoneVerificationQueue,
@@ -87,3 +116,41 @@ func (queue *PhoneQueueManagerImpl) ConsumePhoneVerificationQueue() {
 	log.Printf(" [*] Phone Verification Queue is waiting for messages...")
 	<-forever
 }
+
+func (queue *PhoneQueueManagerImpl) ConsumeLoginOTPQueue() {
+	msgs, err := channel.Consume(
+		config.GetRabbitMQConfig().LoginOTPQueue,
+		"",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	forever := make(chan bool)
+
+	go func() {
+		for d := range msgs {
+			var user map[string]string
+			if err := json.Unmarshal(d.Body, &user); err != nil {
+				fmt.Println("Error while unmarshalling: ", err.Error())
+				continue
+			}
+
+			log.Printf(" [X] Received Login Code Message: %s", user["phone"])
+			if err := queue.SMSService.SendLoginCode(user["phone"]); err != nil {
+				fmt.Println("Error while sending login code: ", err.Error())
+				continue
+			}
+
+			log.Printf(" [X] Login Code Message Sent: %s", user["phone"])
+		}
+	}()
+
+	log.Printf(" [*] Login Code Queue is waiting for messages...")
+	<-forever
+}
@@ -22,6 +22,11 @@ func Connect() (*amqp.Connection, *amqp.Channel) {
 
 	queueDeclare(ch, config.GetRabbitMQConfig().EmailVerificationQueue)
 	queueDeclare(ch, config.GetRabbitMQConfig().PhoneVerificationQueue)
+	queueDeclare(ch, config.GetRabbitMQConfig().ForgotPasswordQueue)
+	queueDeclare(ch, config.GetRabbitMQConfig().SecurityNotificationQueue)
+	queueDeclare(ch, config.GetRabbitMQConfig().MagicLinkQueue)
+	queueDeclare(ch, config.GetRabbitMQConfig().LoginOTPQueue)
+	queueDeclare(ch, config.GetRabbitMQConfig().DataExportQueue)
 
 	log.Println("Connected to RabbitMQ")
 	return conn, ch
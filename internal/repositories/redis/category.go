@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const categoryTreeKey = "category-tree"
+
+type CategoryRepository interface {
+	GetCategoryTree() ([]byte, error)
+	SetCategoryTree(tree []byte, expiration time.Duration) error
+	DeleteCategoryTree() error
+}
+
+type CategoryRedisRepository struct {
+	Ctx    context.Context
+	Client *redis.Client
+}
+
+func NewCategoryRedisRepository() CategoryRepository {
+	return &CategoryRedisRepository{
+		Ctx:    context.Background(),
+		Client: getClient(),
+	}
+}
+
+// GetCategoryTree returns the cached category tree as JSON, or nil when it is not cached
+func (cr *CategoryRedisRepository) GetCategoryTree() ([]byte, error) {
+	tree, err := cr.Client.Get(cr.Ctx, categoryTreeKey).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+func (cr *CategoryRedisRepository) SetCategoryTree(tree []byte, expiration time.Duration) error {
+	return cr.Client.Set(cr.Ctx, categoryTreeKey, tree, expiration).Err()
+}
+
+// DeleteCategoryTree invalidates the cached tree, it is rebuilt on the next read
+func (cr *CategoryRedisRepository) DeleteCategoryTree() error {
+	return cr.Client.Del(cr.Ctx, categoryTreeKey).Err()
+}
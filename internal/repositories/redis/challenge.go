@@ -0,0 +1,91 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type ChallengeRepository interface {
+	IncrCounter(key string, window time.Duration) (int64, error)
+	GetCounters(keys ...string) ([]int64, error)
+	SetHashcashNonce(nonce string, expiration time.Duration) error
+	ConsumeHashcashNonce(nonce string) (bool, error)
+}
+
+type ChallengeRedisRepository struct {
+	Ctx    context.Context
+	Client *redis.Client
+}
+
+func NewChallengeRedisRepository() ChallengeRepository {
+	return &ChallengeRedisRepository{
+		Ctx:    context.Background(),
+		Client: getClient(),
+	}
+}
+
+// ChallengeRequestsKey counts the requests of an IP address to the endpoints of a scope
+func ChallengeRequestsKey(scope string, ip string) string {
+	return "challenge-requests:" + scope + ":" + ip
+}
+
+// ChallengeFailuresKey counts the failed requests of an IP address to the endpoints of a scope
+func ChallengeFailuresKey(scope string, ip string) string {
+	return "challenge-failures:" + scope + ":" + ip
+}
+
+// IncrCounter increments a counter, the window starts with the first increment
+func (cr *ChallengeRedisRepository) IncrCounter(key string, window time.Duration) (int64, error) {
+	count, err := cr.Client.Incr(cr.Ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if count == 1 {
+		if err := cr.Client.Expire(cr.Ctx, key, window).Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
+
+// GetCounters returns the values of counters in the order of the keys, zero for missing counters
+func (cr *ChallengeRedisRepository) GetCounters(keys ...string) ([]int64, error) {
+	values, err := cr.Client.MGet(cr.Ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make([]int64, len(values))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+
+		counter, err := strconv.ParseInt(value.(string), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		counters[i] = counter
+	}
+
+	return counters, nil
+}
+
+func (cr *ChallengeRedisRepository) SetHashcashNonce(nonce string, expiration time.Duration) error {
+	return cr.Client.Set(cr.Ctx, "hashcash:"+nonce, 1, expiration).Err()
+}
+
+// ConsumeHashcashNonce deletes a nonce and reports whether it existed, so that each nonce is only solved once
+func (cr *ChallengeRedisRepository) ConsumeHashcashNonce(nonce string) (bool, error) {
+	deleted, err := cr.Client.Del(cr.Ctx, "hashcash:"+nonce).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return deleted == 1, nil
+}
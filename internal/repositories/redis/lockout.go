@@ -0,0 +1,89 @@
+package redis
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type LockoutRepository interface {
+	IncrFailures(key string, window time.Duration) (int64, error)
+	ResetFailures(key string) error
+	SetLock(key string, duration time.Duration) error
+	GetLockTTL(key string) (time.Duration, error)
+}
+
+type LockoutRedisRepository struct {
+	Ctx    context.Context
+	Client *redis.Client
+}
+
+func NewLockoutRedisRepository() LockoutRepository {
+	return &LockoutRedisRepository{
+		Ctx:    context.Background(),
+		Client: getClient(),
+	}
+}
+
+// LoginFailuresKey counts failed logins of an account, whatever IP they come from
+func LoginFailuresKey(email string) string {
+	return "login-failures:" + email
+}
+
+// LoginLockKey blocks logins of an account while it exists
+func LoginLockKey(email string) string {
+	return "login-lock:" + email
+}
+
+// SecondFactorFailuresKey counts wrong second factors of an account, whatever login they belong to
+func SecondFactorFailuresKey(userId string) string {
+	return "second-factor-failures:" + userId
+}
+
+// SecondFactorLockKey blocks second factors of an account while it exists
+func SecondFactorLockKey(userId string) string {
+	return "second-factor-lock:" + userId
+}
+
+// VerificationFailuresKey counts wrong guesses of a code, kind is "email", "phone" or "login-otp"
+func VerificationFailuresKey(kind string, identifier string) string {
+	return "verification-failures:" + kind + ":" + identifier
+}
+
+// IncrFailures increments a failure counter, the window starts with the first failure. The counter is
+// created with its expiration in the same transaction, so it can not outlive the window.
+func (lr *LockoutRedisRepository) IncrFailures(key string, window time.Duration) (int64, error) {
+	var failures *redis.IntCmd
+	_, err := lr.Client.TxPipelined(lr.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetNX(lr.Ctx, key, 0, window)
+		failures = pipe.Incr(lr.Ctx, key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return failures.Val(), nil
+}
+
+func (lr *LockoutRedisRepository) ResetFailures(key string) error {
+	return lr.Client.Del(lr.Ctx, key).Err()
+}
+
+func (lr *LockoutRedisRepository) SetLock(key string, duration time.Duration) error {
+	return lr.Client.Set(lr.Ctx, key, 1, duration).Err()
+}
+
+// GetLockTTL returns the remaining lock time, zero when there is no lock
+func (lr *LockoutRedisRepository) GetLockTTL(key string) (time.Duration, error) {
+	ttl, err := lr.Client.PTTL(lr.Ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
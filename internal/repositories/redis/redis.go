@@ -5,9 +5,23 @@ import (
 	"github.com/mercan/ecommerce/internal/helpers"
 	"github.com/redis/go-redis/v9"
 	"log"
+	"sync"
 )
 
-var client = Connect()
+// The client connects when the first repository is created rather than when the package is loaded,
+// so that packages depending on it can be tested without a server
+var (
+	client      *redis.Client
+	connectOnce sync.Once
+)
+
+func getClient() *redis.Client {
+	connectOnce.Do(func() {
+		client = Connect()
+	})
+
+	return client
+}
 
 func Connect() *redis.Client {
 	ctx, cancel := helpers.ContextWithTimeout(10)
@@ -2,8 +2,12 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
 	"github.com/redis/go-redis/v9"
+	"strconv"
 	"time"
 )
 
@@ -16,9 +20,40 @@ type AuthenticationRepository interface {
 	SetVerificationEmail(email string, verificationCode string) error
 	GetVerificationEmail(email string) (string, error)
 	DelVerificationEmail(email string) error
-	SetForgotPasswordToken(email string, token string) error
-	GetForgotPasswordToken(email string) (string, error)
-	DelForgotPasswordToken(email string) error
+	SetForgotPasswordToken(token string, email string) error
+	GetForgotPasswordEmail(token string) (string, error)
+	ConsumeForgotPasswordToken(token string) (string, error)
+	SetRefreshToken(token string, userId string, sessionId string) error
+	GetRefreshToken(token string) (string, string, error)
+	MarkRefreshTokenUsed(token string) (bool, error)
+	SetSession(session *models.Session) error
+	UpdateSession(session *models.Session) (bool, error)
+	GetSession(sessionId string) (*models.Session, error)
+	GetUserSessions(userId string) ([]*models.Session, error)
+	DelSession(sessionId string) error
+	DelUserSessions(userId string) error
+	SetMFAPendingToken(token string, userId string) error
+	GetMFAPendingToken(token string) (string, error)
+	IncrMFAPendingAttempts(token string) (int64, error)
+	DelMFAPendingToken(token string) error
+	MarkTOTPStepUsed(userId string, step int64) (bool, error)
+	SetMagicLinkToken(token string, email string) error
+	ConsumeMagicLinkToken(token string) (string, error)
+	SetLoginOTP(phone string, code string) error
+	GetLoginOTP(phone string) (string, error)
+	DelLoginOTP(phone string) (bool, error)
+	SetOAuthState(state string, oauthState *models.OAuthState) error
+	ConsumeOAuthState(state string) (*models.OAuthState, error)
+	SetEmailChangeRevertToken(token string, revert *models.EmailChangeRevert) error
+	ConsumeEmailChangeRevertToken(token string) (*models.EmailChangeRevert, error)
+	DelEmailChangeRevertTokens(userId string) error
+	AddKnownDevice(userId string, fingerprint string) (bool, bool, error)
+	GetKnownDevices(userId string) ([]string, error)
+	DelKnownDevices(userId string) error
+	SetNewDeviceReportToken(token string, report *models.NewDeviceReport) error
+	ConsumeNewDeviceReportToken(token string) (*models.NewDeviceReport, error)
+	SetWebAuthnSession(challenge string, session *models.WebAuthnSession) error
+	ConsumeWebAuthnSession(challenge string) (*models.WebAuthnSession, error)
 	NilError() error
 }
 
@@ -30,7 +65,7 @@ type AuthenticationRedisRepository struct {
 func NewAuthenticationRedisRepository() AuthenticationRepository {
 	return &AuthenticationRedisRepository{
 		Ctx:    context.Background(),
-		Client: client,
+		Client: getClient(),
 	}
 }
 
@@ -55,7 +90,12 @@ func (ar *AuthenticationRedisRepository) IsTokenInBlacklist(token string) (bool,
 func (ar *AuthenticationRedisRepository) SetVerificationPhone(phone string, verificationCode string) error {
 	expiration := config.GetTimeConfig().PhoneExpireTime * time.Second
 
-	return ar.Client.Set(ar.Ctx, "phone:"+phone, verificationCode, expiration).Err()
+	pipe := ar.Client.TxPipeline()
+	pipe.Set(ar.Ctx, "phone:"+phone, verificationCode, expiration)
+	pipe.Del(ar.Ctx, VerificationFailuresKey("phone", phone))
+	_, err := pipe.Exec(ar.Ctx)
+
+	return err
 }
 
 func (ar *AuthenticationRedisRepository) GetVerificationPhone(phone string) (string, error) {
@@ -63,13 +103,18 @@ func (ar *AuthenticationRedisRepository) GetVerificationPhone(phone string) (str
 }
 
 func (ar *AuthenticationRedisRepository) DelVerificationPhone(phone string) error {
-	return ar.Client.Del(ar.Ctx, "phone:"+phone).Err()
+	return ar.Client.Del(ar.Ctx, "phone:"+phone, VerificationFailuresKey("phone", phone)).Err()
 }
 
 func (ar *AuthenticationRedisRepository) SetVerificationEmail(email string, verificationCode string) error {
 	expiration := config.GetTimeConfig().EmailExpireTime * time.Second
 
-	return ar.Client.Set(ar.Ctx, "email:"+email, verificationCode, expiration).Err()
+	pipe := ar.Client.TxPipeline()
+	pipe.Set(ar.Ctx, "email:"+email, verificationCode, expiration)
+	pipe.Del(ar.Ctx, VerificationFailuresKey("email", email))
+	_, err := pipe.Exec(ar.Ctx)
+
+	return err
 }
 
 func (ar *AuthenticationRedisRepository) GetVerificationEmail(email string) (string, error) {
@@ -77,19 +122,416 @@ func (ar *AuthenticationRedisRepository) GetVerificationEmail(email string) (str
 }
 
 func (ar *AuthenticationRedisRepository) DelVerificationEmail(email string) error {
-	return ar.Client.Del(ar.Ctx, "email:"+email).Err()
+	return ar.Client.Del(ar.Ctx, "email:"+email, VerificationFailuresKey("email", email)).Err()
 }
 
-func (ar *AuthenticationRedisRepository) SetForgotPasswordToken(email string, token string) error {
+// Reset tokens are stored by hash, like every token sent in a link
+
+func (ar *AuthenticationRedisRepository) SetForgotPasswordToken(token string, email string) error {
 	expiration := config.GetTimeConfig().ForgotPasswordExpireTime * time.Second
 
-	return ar.Client.Set(ar.Ctx, "forgot-password:"+email, token, expiration).Err()
+	return ar.Client.Set(ar.Ctx, "forgot-password:"+helpers.HashToken(token), email, expiration).Err()
+}
+
+func (ar *AuthenticationRedisRepository) GetForgotPasswordEmail(token string) (string, error) {
+	return ar.Client.Get(ar.Ctx, "forgot-password:"+helpers.HashToken(token)).Result()
+}
+
+// ConsumeForgotPasswordToken returns the email of a reset token and deletes it atomically, so that
+// only one of two concurrent resets can use it
+func (ar *AuthenticationRedisRepository) ConsumeForgotPasswordToken(token string) (string, error) {
+	return ar.Client.GetDel(ar.Ctx, "forgot-password:"+helpers.HashToken(token)).Result()
+}
+
+// Refresh tokens are stored by hash and belong to a session. Every rotation adds a new
+// token to the session; presenting a token that was already used revokes the session.
+
+func (ar *AuthenticationRedisRepository) SetRefreshToken(token string, userId string, sessionId string) error {
+	expiration := config.GetJWTConfig().RefreshExpiration * time.Hour
+	key := "refresh-token:" + helpers.HashToken(token)
+
+	pipe := ar.Client.TxPipeline()
+	pipe.HSet(ar.Ctx, key, map[string]interface{}{
+		"user_id":    userId,
+		"session_id": sessionId,
+	})
+	pipe.Expire(ar.Ctx, key, expiration)
+	_, err := pipe.Exec(ar.Ctx)
+
+	return err
+}
+
+// GetRefreshToken returns the user id and session id of a refresh token
+func (ar *AuthenticationRedisRepository) GetRefreshToken(token string) (string, string, error) {
+	result, err := ar.Client.HGetAll(ar.Ctx, "refresh-token:"+helpers.HashToken(token)).Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(result) == 0 {
+		return "", "", redis.Nil
+	}
+
+	return result["user_id"], result["session_id"], nil
+}
+
+// MarkRefreshTokenUsed atomically flags a refresh token as used and reports whether this was its first use
+func (ar *AuthenticationRedisRepository) MarkRefreshTokenUsed(token string) (bool, error) {
+	expiration := config.GetJWTConfig().RefreshExpiration * time.Hour
+
+	return ar.Client.SetNX(ar.Ctx, "refresh-token-used:"+helpers.HashToken(token), 1, expiration).Result()
+}
+
+// SetSession creates a session that lives for a full refresh token expiration
+func (ar *AuthenticationRedisRepository) SetSession(session *models.Session) error {
+	expiration := config.GetJWTConfig().RefreshExpiration * time.Hour
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	pipe := ar.Client.TxPipeline()
+	pipe.Set(ar.Ctx, "session:"+session.ID, data, expiration)
+	pipe.SAdd(ar.Ctx, "sessions:"+session.UserID, session.ID)
+	pipe.Expire(ar.Ctx, "sessions:"+session.UserID, expiration)
+	_, err = pipe.Exec(ar.Ctx)
+
+	return err
+}
+
+// UpdateSession saves a session and extends its lifetime to a full refresh token expiration. A session
+// that was revoked in the meantime is not recreated, it reports false instead.
+func (ar *AuthenticationRedisRepository) UpdateSession(session *models.Session) (bool, error) {
+	expiration := config.GetJWTConfig().RefreshExpiration * time.Hour
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return false, err
+	}
+
+	var updated *redis.BoolCmd
+	_, err = ar.Client.TxPipelined(ar.Ctx, func(pipe redis.Pipeliner) error {
+		updated = pipe.SetXX(ar.Ctx, "session:"+session.ID, data, expiration)
+		pipe.Expire(ar.Ctx, "sessions:"+session.UserID, expiration)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return false, err
+	}
+
+	return updated.Val(), nil
+}
+
+func (ar *AuthenticationRedisRepository) GetSession(sessionId string) (*models.Session, error) {
+	data, err := ar.Client.Get(ar.Ctx, "session:"+sessionId).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var session models.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// GetUserSessions returns the active sessions of a user, pruning ids of expired sessions
+func (ar *AuthenticationRedisRepository) GetUserSessions(userId string) ([]*models.Session, error) {
+	sessionIds, err := ar.Client.SMembers(ar.Ctx, "sessions:"+userId).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*models.Session, 0, len(sessionIds))
+	for _, sessionId := range sessionIds {
+		session, err := ar.GetSession(sessionId)
+		if err != nil {
+			if err == redis.Nil {
+				ar.Client.SRem(ar.Ctx, "sessions:"+userId, sessionId)
+				continue
+			}
+
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+func (ar *AuthenticationRedisRepository) DelSession(sessionId string) error {
+	session, err := ar.GetSession(sessionId)
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+
+		return err
+	}
+
+	pipe := ar.Client.TxPipeline()
+	pipe.Del(ar.Ctx, "session:"+sessionId)
+	pipe.SRem(ar.Ctx, "sessions:"+session.UserID, sessionId)
+	_, err = pipe.Exec(ar.Ctx)
+
+	return err
+}
+
+func (ar *AuthenticationRedisRepository) DelUserSessions(userId string) error {
+	sessionIds, err := ar.Client.SMembers(ar.Ctx, "sessions:"+userId).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := ar.Client.TxPipeline()
+	for _, sessionId := range sessionIds {
+		pipe.Del(ar.Ctx, "session:"+sessionId)
+	}
+	pipe.Del(ar.Ctx, "sessions:"+userId)
+	_, err = pipe.Exec(ar.Ctx)
+
+	return err
+}
+
+func (ar *AuthenticationRedisRepository) SetMFAPendingToken(token string, userId string) error {
+	expiration := config.GetTimeConfig().MFAPendingExpireTime * time.Second
+
+	return ar.Client.Set(ar.Ctx, "mfa-pending:"+helpers.HashToken(token), userId, expiration).Err()
+}
+
+func (ar *AuthenticationRedisRepository) GetMFAPendingToken(token string) (string, error) {
+	return ar.Client.Get(ar.Ctx, "mfa-pending:"+helpers.HashToken(token)).Result()
+}
+
+func (ar *AuthenticationRedisRepository) IncrMFAPendingAttempts(token string) (int64, error) {
+	expiration := config.GetTimeConfig().MFAPendingExpireTime * time.Second
+	key := "mfa-pending-attempts:" + helpers.HashToken(token)
+
+	pipe := ar.Client.TxPipeline()
+	attempts := pipe.Incr(ar.Ctx, key)
+	pipe.Expire(ar.Ctx, key, expiration)
+	if _, err := pipe.Exec(ar.Ctx); err != nil {
+		return 0, err
+	}
+
+	return attempts.Val(), nil
+}
+
+func (ar *AuthenticationRedisRepository) DelMFAPendingToken(token string) error {
+	hash := helpers.HashToken(token)
+
+	return ar.Client.Del(ar.Ctx, "mfa-pending:"+hash, "mfa-pending-attempts:"+hash).Err()
+}
+
+// MarkTOTPStepUsed records that a TOTP code of the given time step was accepted and reports
+// whether it had not been used before
+func (ar *AuthenticationRedisRepository) MarkTOTPStepUsed(userId string, step int64) (bool, error) {
+	key := "totp-used:" + userId + ":" + strconv.FormatInt(step, 10)
+
+	return ar.Client.SetNX(ar.Ctx, key, 1, 2*time.Minute).Result()
+}
+
+// Magic link tokens and login codes are stored hashed and can only be used once
+
+func (ar *AuthenticationRedisRepository) SetMagicLinkToken(token string, email string) error {
+	expiration := config.GetTimeConfig().MagicLinkExpireTime * time.Second
+
+	return ar.Client.Set(ar.Ctx, "magic-link:"+helpers.HashToken(token), email, expiration).Err()
+}
+
+// ConsumeMagicLinkToken returns the email of a magic link token and deletes it atomically
+func (ar *AuthenticationRedisRepository) ConsumeMagicLinkToken(token string) (string, error) {
+	return ar.Client.GetDel(ar.Ctx, "magic-link:"+helpers.HashToken(token)).Result()
+}
+
+func (ar *AuthenticationRedisRepository) SetLoginOTP(phone string, code string) error {
+	expiration := config.GetTimeConfig().LoginOTPExpireTime * time.Second
+
+	pipe := ar.Client.TxPipeline()
+	pipe.Set(ar.Ctx, "login-otp:"+phone, helpers.HashToken(code), expiration)
+	pipe.Del(ar.Ctx, VerificationFailuresKey("login-otp", phone))
+	_, err := pipe.Exec(ar.Ctx)
+
+	return err
+}
+
+// GetLoginOTP returns the hash of the login code sent to the phone
+func (ar *AuthenticationRedisRepository) GetLoginOTP(phone string) (string, error) {
+	return ar.Client.Get(ar.Ctx, "login-otp:"+phone).Result()
+}
+
+// DelLoginOTP deletes the login code and reports whether it still existed,
+// so that only one of two concurrent requests can use it
+func (ar *AuthenticationRedisRepository) DelLoginOTP(phone string) (bool, error) {
+	deleted, err := ar.Client.Del(ar.Ctx, "login-otp:"+phone).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if err := ar.Client.Del(ar.Ctx, VerificationFailuresKey("login-otp", phone)).Err(); err != nil {
+		return false, err
+	}
+
+	return deleted == 1, nil
+}
+
+func (ar *AuthenticationRedisRepository) SetOAuthState(state string, oauthState *models.OAuthState) error {
+	expiration := config.GetTimeConfig().OAuthStateExpireTime * time.Second
+
+	data, err := json.Marshal(oauthState)
+	if err != nil {
+		return err
+	}
+
+	return ar.Client.Set(ar.Ctx, "oauth-state:"+helpers.HashToken(state), data, expiration).Err()
+}
+
+func (ar *AuthenticationRedisRepository) SetWebAuthnSession(challenge string, session *models.WebAuthnSession) error {
+	expiration := config.GetTimeConfig().WebAuthnChallengeTime * time.Second
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	return ar.Client.Set(ar.Ctx, "webauthn:"+helpers.HashToken(challenge), data, expiration).Err()
+}
+
+// ConsumeWebAuthnSession returns the ceremony started with a challenge and deletes it atomically,
+// so that every challenge is only answered once
+func (ar *AuthenticationRedisRepository) ConsumeWebAuthnSession(challenge string) (*models.WebAuthnSession, error) {
+	data, err := ar.Client.GetDel(ar.Ctx, "webauthn:"+helpers.HashToken(challenge)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var session models.WebAuthnSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// ConsumeOAuthState returns the data stored for an OAuth state and deletes it atomically
+func (ar *AuthenticationRedisRepository) ConsumeOAuthState(state string) (*models.OAuthState, error) {
+	data, err := ar.Client.GetDel(ar.Ctx, "oauth-state:"+helpers.HashToken(state)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var oauthState models.OAuthState
+	if err := json.Unmarshal(data, &oauthState); err != nil {
+		return nil, err
+	}
+
+	return &oauthState, nil
+}
+
+func (ar *AuthenticationRedisRepository) SetEmailChangeRevertToken(token string, revert *models.EmailChangeRevert) error {
+	expiration := config.GetTimeConfig().EmailChangeRevertTime * time.Second
+
+	data, err := json.Marshal(revert)
+	if err != nil {
+		return err
+	}
+
+	// The user's tokens are indexed so that they can all be invalidated
+	pipe := ar.Client.TxPipeline()
+	pipe.Set(ar.Ctx, "email-change-revert:"+helpers.HashToken(token), data, expiration)
+	pipe.SAdd(ar.Ctx, "email-change-reverts:"+revert.UserID, helpers.HashToken(token))
+	pipe.Expire(ar.Ctx, "email-change-reverts:"+revert.UserID, expiration)
+	_, err = pipe.Exec(ar.Ctx)
+
+	return err
+}
+
+// ConsumeEmailChangeRevertToken returns the data stored for a revert token and deletes it atomically
+func (ar *AuthenticationRedisRepository) ConsumeEmailChangeRevertToken(token string) (*models.EmailChangeRevert, error) {
+	data, err := ar.Client.GetDel(ar.Ctx, "email-change-revert:"+helpers.HashToken(token)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var revert models.EmailChangeRevert
+	if err := json.Unmarshal(data, &revert); err != nil {
+		return nil, err
+	}
+
+	return &revert, nil
 }
 
-func (ar *AuthenticationRedisRepository) GetForgotPasswordToken(email string) (string, error) {
-	return ar.Client.Get(ar.Ctx, "forgot-password:"+email).Result()
+// DelEmailChangeRevertTokens invalidates every revert link sent to the user
+func (ar *AuthenticationRedisRepository) DelEmailChangeRevertTokens(userId string) error {
+	hashes, err := ar.Client.SMembers(ar.Ctx, "email-change-reverts:"+userId).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := ar.Client.TxPipeline()
+	for _, hash := range hashes {
+		pipe.Del(ar.Ctx, "email-change-revert:"+hash)
+	}
+	pipe.Del(ar.Ctx, "email-change-reverts:"+userId)
+	_, err = pipe.Exec(ar.Ctx)
+
+	return err
 }
 
-func (ar *AuthenticationRedisRepository) DelForgotPasswordToken(email string) error {
-	return ar.Client.Del(ar.Ctx, "forgot-password:"+email).Err()
+// AddKnownDevice remembers a device fingerprint of a user. It reports whether the fingerprint was
+// unknown and whether the user had known devices before, devices unused for a while are forgotten.
+func (ar *AuthenticationRedisRepository) AddKnownDevice(userId string, fingerprint string) (bool, bool, error) {
+	expiration := config.GetTimeConfig().KnownDeviceExpireTime * time.Second
+	key := "known-devices:" + userId
+
+	var count *redis.IntCmd
+	var added *redis.IntCmd
+	_, err := ar.Client.TxPipelined(ar.Ctx, func(pipe redis.Pipeliner) error {
+		count = pipe.SCard(ar.Ctx, key)
+		added = pipe.SAdd(ar.Ctx, key, fingerprint)
+		pipe.Expire(ar.Ctx, key, expiration)
+		return nil
+	})
+	if err != nil {
+		return false, false, err
+	}
+
+	return added.Val() == 1, count.Val() > 0, nil
+}
+
+func (ar *AuthenticationRedisRepository) GetKnownDevices(userId string) ([]string, error) {
+	return ar.Client.SMembers(ar.Ctx, "known-devices:"+userId).Result()
+}
+
+func (ar *AuthenticationRedisRepository) DelKnownDevices(userId string) error {
+	return ar.Client.Del(ar.Ctx, "known-devices:"+userId).Err()
+}
+
+func (ar *AuthenticationRedisRepository) SetNewDeviceReportToken(token string, report *models.NewDeviceReport) error {
+	expiration := config.GetTimeConfig().NewDeviceReportTime * time.Second
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	return ar.Client.Set(ar.Ctx, "new-device-report:"+helpers.HashToken(token), data, expiration).Err()
+}
+
+// ConsumeNewDeviceReportToken returns the sign-in stored for a report token and deletes it atomically
+func (ar *AuthenticationRedisRepository) ConsumeNewDeviceReportToken(token string) (*models.NewDeviceReport, error) {
+	data, err := ar.Client.GetDel(ar.Ctx, "new-device-report:"+helpers.HashToken(token)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var report models.NewDeviceReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
 }
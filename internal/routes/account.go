@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/mercan/ecommerce/internal/controllers"
+	"github.com/mercan/ecommerce/internal/middleware"
+	"github.com/mercan/ecommerce/internal/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SetupAccountRoutes sets up the routes of the signed in user's own account
+func SetupAccountRoutes(app *fiber.App) {
+	accountController := controllers.NewAccountController()
+	apiKeyController := controllers.NewAPIKeyController()
+
+	// Account Group
+	account := app.Group("/me", middleware.IsAuthenticated)
+
+	// Exports are expensive, a few per hour are enough
+	exportLimiter := limiter.New(limiter.Config{
+		Max:        3,
+		Expiration: 1 * time.Hour,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.Locals("userId").(primitive.ObjectID).Hex()
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(types.BaseResponse{
+				Success: false,
+				Error:   "Too many requests",
+			})
+		},
+	})
+
+	account.Post("/deactivate", middleware.CheckContentType, middleware.BlockImpersonation, accountController.Deactivate)
+	account.Delete("/", middleware.CheckContentType, middleware.BlockImpersonation, accountController.Delete)
+	account.Get("/export", middleware.BlockImpersonation, exportLimiter, accountController.Export)
+	account.Get("/security-events", accountController.GetSecurityEvents)
+
+	account.Get("/api-keys", apiKeyController.List)
+	account.Post("/api-keys", middleware.CheckContentType, middleware.BlockImpersonation, apiKeyController.Create)
+	account.Get("/api-keys/:id", apiKeyController.Get)
+	account.Patch("/api-keys/:id", middleware.CheckContentType, middleware.BlockImpersonation, apiKeyController.Update)
+	account.Delete("/api-keys/:id", middleware.BlockImpersonation, apiKeyController.Delete)
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mercan/ecommerce/internal/controllers"
+	"github.com/mercan/ecommerce/internal/middleware"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+// SetupAdminRoutes sets up admin routes
+func SetupAdminRoutes(app *fiber.App) {
+	adminController := controllers.NewAdminController()
+	categoryController := controllers.NewCategoryController()
+
+	// Admin Group
+	admin := app.Group("/admin", middleware.IsAuthenticated)
+
+	admin.Patch("/users/:id/role", middleware.CheckContentType, middleware.RequirePermission(models.PermissionUsersManage), adminController.ChangeUserRole)
+	admin.Post("/users/:id/impersonate", middleware.RequirePermission(models.PermissionUsersManage), middleware.BlockImpersonation, adminController.Impersonate)
+	admin.Get("/audit-events", middleware.RequirePermission(models.PermissionUsersRead), adminController.GetAuditEvents)
+
+	admin.Post("/categories", middleware.CheckContentType, middleware.RequirePermission(models.PermissionCategoriesManage), categoryController.Create)
+	admin.Patch("/categories/:id", middleware.CheckContentType, middleware.RequirePermission(models.PermissionCategoriesManage), categoryController.Update)
+	admin.Post("/categories/:id/move", middleware.CheckContentType, middleware.RequirePermission(models.PermissionCategoriesManage), categoryController.Move)
+	admin.Delete("/categories/:id", middleware.RequirePermission(models.PermissionCategoriesManage), categoryController.Delete)
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mercan/ecommerce/internal/controllers"
+)
+
+// SetupCategoryRoutes sets up the public category routes, categories are managed under the admin routes
+func SetupCategoryRoutes(app *fiber.App) {
+	categoryController := controllers.NewCategoryController()
+
+	// Category Group
+	categories := app.Group("/categories")
+
+	categories.Get("/", categoryController.GetTree)
+	categories.Get("/:id", categoryController.Get)
+}
@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"bytes"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/controllers"
+	"github.com/mercan/ecommerce/internal/middleware"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/services"
+	"github.com/valyala/fasthttp"
+)
+
+// SetupMediaRoutes sets up the image upload routes and serves the files of the local media storage
+func SetupMediaRoutes(app *fiber.App) {
+	mediaController := controllers.NewMediaController()
+
+	// Only uploads may exceed the default body limit of the app
+	app.Server().HeaderReceived = mediaRequestConfig
+
+	// Files of the local media storage are served by this server
+	if services.MediaStorageName() == services.MediaStorageLocal {
+		app.Static("/uploads", config.GetMediaConfig().LocalDir)
+	}
+
+	// Media Group
+	media := app.Group("/media")
+
+	media.Post("/products/:id/images", middleware.IsAuthenticatedOrAPIKey, middleware.RequirePermission(models.PermissionProductsWrite), mediaController.UploadProductImage)
+	media.Delete("/products/:id/images/:mediaId", middleware.IsAuthenticatedOrAPIKey, middleware.RequirePermission(models.PermissionProductsWrite), mediaController.DeleteProductImage)
+	media.Post("/profile-image", middleware.IsAuthenticated, mediaController.UploadProfileImage)
+	media.Post("/banner-image", middleware.IsAuthenticated, mediaController.UploadBannerImage)
+	media.Delete("/:id", middleware.IsAuthenticated, mediaController.DeleteUserImage)
+}
+
+// mediaRequestConfig raises the body limit of uploads to the largest accepted file and room for the
+// multipart envelope around it. It runs once the headers are read, before the body is.
+func mediaRequestConfig(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+	if !header.IsPost() || !bytes.HasPrefix(header.RequestURI(), []byte("/media/")) {
+		return fasthttp.RequestConfig{}
+	}
+
+	return fasthttp.RequestConfig{
+		MaxRequestBodySize: int(config.GetMediaConfig().MaxUploadSize) + 1024*1024,
+	}
+}
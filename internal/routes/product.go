@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mercan/ecommerce/internal/controllers"
+	"github.com/mercan/ecommerce/internal/middleware"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+// SetupProductRoutes sets up the public catalog and the store product management routes
+func SetupProductRoutes(app *fiber.App) {
+	productController := controllers.NewProductController()
+	productVariantController := controllers.NewProductVariantController()
+
+	// Product Group
+	products := app.Group("/products")
+
+	products.Get("/", productController.List)
+	products.Get("/search", productController.Search)
+	products.Get("/:id", productController.Get)
+	products.Get("/:id/variants/:variantId", productVariantController.Get)
+
+	// Store Product Group
+	store := app.Group("/store/products", middleware.IsAuthenticatedOrAPIKey, middleware.RequirePermission(models.PermissionProductsWrite))
+
+	store.Get("/", productController.ListStore)
+	store.Post("/", middleware.CheckContentType, productController.Create)
+	store.Get("/:id", productController.GetStore)
+	store.Patch("/:id", middleware.CheckContentType, productController.Update)
+	store.Delete("/:id", productController.Delete)
+	store.Put("/:id/options", middleware.CheckContentType, productVariantController.SetOptions)
+	store.Post("/:id/variants", middleware.CheckContentType, productVariantController.Create)
+	store.Post("/:id/variants/generate", middleware.CheckContentType, productVariantController.Generate)
+	store.Patch("/:id/variants/:variantId", middleware.CheckContentType, productVariantController.Update)
+	store.Delete("/:id/variants/:variantId", productVariantController.Delete)
+}
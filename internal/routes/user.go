@@ -12,6 +12,10 @@ import (
 // SetupUserRoutes sets up user routes
 func SetupUserRoutes(app *fiber.App) {
 	userController := controllers.NewUserController()
+	totpController := controllers.NewTOTPController()
+	passwordlessController := controllers.NewPasswordlessController()
+	oauthController := controllers.NewOAuthController()
+	webAuthnController := controllers.NewWebAuthnController()
 
 	// Auth Group
 	user := app.Group("/auth")
@@ -29,19 +33,55 @@ func SetupUserRoutes(app *fiber.App) {
 
 	user.Use(limiterMiddleware)
 
-	user.Post("/register", middleware.CheckContentType, userController.Register)
-	user.Post("/login", middleware.CheckContentType, userController.Login)
-	user.Get("/logout", middleware.IsAuthenticated, userController.Logout)
+	user.Post("/register", middleware.CheckContentType, middleware.RequireChallenge("register"), userController.Register)
+	user.Post("/login", middleware.CheckContentType, middleware.RequireChallenge("login"), userController.Login)
+	user.Post("/login/2fa", middleware.CheckContentType, totpController.VerifyLogin)
+	user.Post("/login/magic-link", middleware.CheckContentType, middleware.RequireChallenge("passwordless"), passwordlessController.RequestMagicLink)
+	user.Get("/login/magic-link/verify", passwordlessController.MagicLinkPage)
+	user.Post("/login/magic-link/verify", passwordlessController.VerifyMagicLink)
+	user.Post("/login/otp", middleware.CheckContentType, middleware.RequireChallenge("passwordless"), passwordlessController.RequestLoginOTP)
+	user.Post("/login/otp/verify", middleware.CheckContentType, passwordlessController.VerifyLoginOTP)
+	user.Post("/login/webauthn/options", webAuthnController.BeginLogin)
+	user.Post("/login/webauthn", middleware.CheckContentType, webAuthnController.FinishLogin)
+	user.Post("/login/2fa/webauthn/options", middleware.CheckContentType, webAuthnController.BeginSecondFactor)
+	user.Post("/login/2fa/webauthn", middleware.CheckContentType, webAuthnController.FinishSecondFactor)
+	user.Get("/oauth/:provider", oauthController.StartLogin)
+	user.Get("/oauth/:provider/callback", oauthController.Callback)
+	user.Post("/oauth/:provider/link", middleware.IsAuthenticated, middleware.BlockImpersonation, oauthController.StartLink)
+	user.Delete("/oauth/:provider", middleware.IsAuthenticated, middleware.BlockImpersonation, oauthController.Unlink)
 
-	user.Post("/change-password", middleware.CheckContentType, middleware.IsAuthenticated, middleware.IsEmailVerified, userController.ChangePassword)
-	user.Post("/change-email", middleware.CheckContentType, middleware.IsAuthenticated, userController.ChangeEmail)
+	user.Post("/refresh", middleware.CheckContentType, userController.Refresh)
+	user.Get("/logout", middleware.IsAuthenticated, middleware.BlockImpersonation, userController.Logout)
+	user.Post("/logout-all", middleware.IsAuthenticated, middleware.BlockImpersonation, userController.LogoutAll)
+	user.Post("/impersonation/end", middleware.IsAuthenticated, userController.EndImpersonation)
+
+	user.Get("/sessions", middleware.IsAuthenticated, userController.GetSessions)
+	user.Delete("/sessions/:id", middleware.IsAuthenticated, middleware.BlockImpersonation, userController.RevokeSession)
+
+	user.Post("/change-password", middleware.CheckContentType, middleware.IsAuthenticated, middleware.BlockImpersonation, middleware.IsEmailVerified, userController.ChangePassword)
+	user.Post("/change-email", middleware.CheckContentType, middleware.IsAuthenticated, middleware.BlockImpersonation, userController.ChangeEmail)
+	user.Post("/change-email/verify", middleware.CheckContentType, middleware.IsAuthenticated, middleware.BlockImpersonation, userController.VerifyEmailChange)
+	user.Get("/change-email/revert", userController.RevertEmailChangePage)
+	user.Post("/change-email/revert", userController.RevertEmailChange)
+	user.Get("/new-device/report", userController.ReportNewDevicePage)
+	user.Post("/new-device/report", userController.ReportNewDevice)
+	user.Post("/change-phone", middleware.CheckContentType, middleware.IsAuthenticated, middleware.BlockImpersonation, userController.ChangePhone)
 
 	user.Get("/verify-email", middleware.IsAuthenticated, userController.VerifyEmail)
 	user.Get("/resend-verification-email", middleware.IsAuthenticated, userController.ResendEmailVerification)
 	user.Get("/verify-phone", middleware.IsAuthenticated, userController.VerifyPhone)
 	user.Get("/resend-verification-phone", middleware.IsAuthenticated, userController.ResendPhoneVerification)
 
-	user.Get("/forgot-password", userController.ForgotPassword)
-	//auth.Post("/refresh", userAuthController.Refresh)
+	user.Post("/2fa/setup", middleware.IsAuthenticated, middleware.BlockImpersonation, totpController.Setup)
+	user.Post("/2fa/enable", middleware.CheckContentType, middleware.IsAuthenticated, middleware.BlockImpersonation, totpController.Enable)
+	user.Post("/2fa/disable", middleware.CheckContentType, middleware.IsAuthenticated, middleware.BlockImpersonation, totpController.Disable)
+	user.Post("/2fa/recovery-codes", middleware.CheckContentType, middleware.IsAuthenticated, middleware.BlockImpersonation, totpController.RegenerateRecoveryCodes)
+
+	user.Post("/webauthn/register/options", middleware.CheckContentType, middleware.IsAuthenticated, middleware.BlockImpersonation, webAuthnController.BeginRegistration)
+	user.Post("/webauthn/register", middleware.CheckContentType, middleware.IsAuthenticated, middleware.BlockImpersonation, webAuthnController.FinishRegistration)
+	user.Get("/webauthn/credentials", middleware.IsAuthenticated, webAuthnController.ListCredentials)
+	user.Delete("/webauthn/credentials/:id", middleware.IsAuthenticated, middleware.BlockImpersonation, webAuthnController.DeleteCredential)
 
+	user.Post("/forgot-password", middleware.CheckContentType, middleware.RequireChallenge("forgot-password"), userController.ForgotPassword)
+	user.Post("/reset-password/:token", middleware.CheckContentType, userController.ResetPassword)
 }
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mercan/ecommerce/internal/controllers"
+)
+
+// SetupWellKnownRoutes sets up the /.well-known discovery routes
+func SetupWellKnownRoutes(app *fiber.App) {
+	jwksController := controllers.NewJWKSController()
+
+	wellKnown := app.Group("/.well-known")
+
+	wellKnown.Get("/jwks.json", jwksController.GetJWKS)
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/repositories/redis"
+	"github.com/mercan/ecommerce/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AccountService interface {
+	Deactivate(userId primitive.ObjectID, user models.UserPasswordConfirmRequest, client models.ClientInfo) error
+	ScheduleDeletion(userId primitive.ObjectID, user models.UserPasswordConfirmRequest, client models.ClientInfo) (time.Time, error)
+	PurgeScheduledDeletions() error
+	RunDeletionScheduler()
+	RecordDataExportRequest(userId primitive.ObjectID, client models.ClientInfo)
+	BuildDataExport(userId primitive.ObjectID) (string, []byte, error)
+}
+
+// dataExportPageSize is the number of audit events and products read at a time for a data export
+const dataExportPageSize = 500
+
+type AccountServiceImpl struct {
+	userRepo      mongodb.UserMongoRepository
+	orderRepo     mongodb.OrderMongoRepository
+	apiKeyRepo    mongodb.APIKeyMongoRepository
+	productRepo   mongodb.ProductMongoRepository
+	auditRepo     mongodb.AuditEventMongoRepository
+	mediaRepo     mongodb.MediaMongoRepository
+	authRedisRepo redis.AuthenticationRepository
+	mediaService  MediaService
+	TokenService  TokenService
+	AuditService  AuditService
+}
+
+func NewAccountService() AccountService {
+	return &AccountServiceImpl{
+		userRepo:      mongodb.NewUserMongoRepository(),
+		orderRepo:     mongodb.NewOrderMongoRepository(),
+		apiKeyRepo:    mongodb.NewAPIKeyMongoRepository(),
+		productRepo:   mongodb.NewProductMongoRepository(),
+		auditRepo:     mongodb.NewAuditEventMongoRepository(),
+		mediaRepo:     mongodb.NewMediaMongoRepository(),
+		authRedisRepo: redis.NewAuthenticationRedisRepository(),
+		mediaService:  NewMediaService(),
+		TokenService:  NewTokenService(),
+		AuditService:  NewAuditService(),
+	}
+}
+
+// Deactivate signs the user out everywhere and blocks the account until the user signs in again
+func (service *AccountServiceImpl) Deactivate(userId primitive.ObjectID, user models.UserPasswordConfirmRequest, client models.ClientInfo) (err error) {
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionAccountDeactivate, userId, client, err))
+	}()
+
+	if err := service.confirmPassword(userId, user); err != nil {
+		return err
+	}
+
+	if err := service.userRepo.Deactivate(userId, nil); err != nil {
+		return err
+	}
+
+	return service.TokenService.RevokeAllSessions(userId)
+}
+
+// ScheduleDeletion deactivates the account and deletes it once the grace period is over.
+// Signing in again before that cancels the deletion.
+func (service *AccountServiceImpl) ScheduleDeletion(userId primitive.ObjectID, user models.UserPasswordConfirmRequest, client models.ClientInfo) (deletionScheduledAt time.Time, err error) {
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionAccountDelete, userId, client, err))
+	}()
+
+	if err := service.confirmPassword(userId, user); err != nil {
+		return time.Time{}, err
+	}
+
+	deletionScheduledAt = time.Now().Add(config.GetTimeConfig().AccountDeletionGrace * time.Hour)
+	if err := service.userRepo.Deactivate(userId, &deletionScheduledAt); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := service.TokenService.RevokeAllSessions(userId); err != nil {
+		return time.Time{}, err
+	}
+
+	return deletionScheduledAt, nil
+}
+
+// PurgeScheduledDeletions hard deletes every account whose grace period is over.
+// Orders are anonymized and products archived rather than removed, profile images are deleted.
+// Accounts past their grace period can no longer be reactivated by signing in, so the cleanup
+// never runs for an account that is kept.
+func (service *AccountServiceImpl) PurgeScheduledDeletions() error {
+	now := time.Now()
+	users, err := service.userRepo.GetUsersDueForDeletion(now)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := service.orderRepo.AnonymizeUserOrders(user.ID); err != nil {
+			log.Printf("Error while anonymizing orders of user %s: %s", user.ID.Hex(), err.Error())
+			continue
+		}
+
+		if err := service.productRepo.ArchiveProductsByStoreID(user.ID); err != nil {
+			log.Printf("Error while archiving products of user %s: %s", user.ID.Hex(), err.Error())
+			continue
+		}
+
+		if err := service.mediaService.DeleteUserMedia(user.ID); err != nil {
+			log.Printf("Error while deleting media of user %s: %s", user.ID.Hex(), err.Error())
+			continue
+		}
+
+		if err := service.TokenService.RevokeAllSessions(user.ID); err != nil {
+			log.Printf("Error while revoking sessions of user %s: %s", user.ID.Hex(), err.Error())
+		}
+
+		if err := service.apiKeyRepo.DeleteAPIKeysByUserID(user.ID); err != nil {
+			log.Printf("Error while deleting API keys of user %s: %s", user.ID.Hex(), err.Error())
+			continue
+		}
+
+		deleted, err := service.userRepo.DeleteScheduledUser(user.ID, now)
+		if err != nil {
+			log.Printf("Error while deleting user %s: %s", user.ID.Hex(), err.Error())
+			continue
+		}
+
+		if !deleted {
+			log.Printf("Skipped deleting user %s, the account is no longer scheduled for deletion", user.ID.Hex())
+			continue
+		}
+
+		log.Printf("Deleted user %s after the deletion grace period", user.ID.Hex())
+
+		// The deletion is made by the system, the account is only the target
+		userId := user.ID
+		service.AuditService.Record(&models.AuditEvent{
+			Action:   models.AuditActionAccountPurge,
+			TargetID: &userId,
+			Outcome:  models.AuditOutcomeSuccess,
+		})
+	}
+
+	return nil
+}
+
+// RunDeletionScheduler purges scheduled deletions periodically, it never returns
+func (service *AccountServiceImpl) RunDeletionScheduler() {
+	ticker := time.NewTicker(config.GetTimeConfig().AccountDeletionInterval * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		if err := service.PurgeScheduledDeletions(); err != nil {
+			log.Println("Error while purging scheduled account deletions: ", err.Error())
+		}
+
+		<-ticker.C
+	}
+}
+
+// RecordDataExportRequest records in the audit log that the user requested a data export, the
+// export itself is built in the background by BuildDataExport
+func (service *AccountServiceImpl) RecordDataExportRequest(userId primitive.ObjectID, client models.ClientInfo) {
+	service.AuditService.Record(newAuditEvent(models.AuditActionDataExport, userId, client, nil))
+}
+
+// BuildDataExport returns the email of the user and a JSON archive of everything stored about them
+func (service *AccountServiceImpl) BuildDataExport(userId primitive.ObjectID) (string, []byte, error) {
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if userDoc == nil {
+		return "", nil, errors.New("User not found")
+	}
+
+	sessions, err := service.TokenService.GetSessions(userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	knownDevices, err := service.authRedisRepo.GetKnownDevices(userId.Hex())
+	if err != nil {
+		return "", nil, err
+	}
+
+	apiKeys, err := service.apiKeyRepo.GetAPIKeysByUserID(userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	auditEvents, err := service.getAllAuditEvents(userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	orders, err := service.orderRepo.GetOrdersByUserID(userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	products, err := service.getAllStoreProducts(userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	media, err := service.mediaRepo.GetMediaByOwnerID(userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	twoFactorEnabled := userDoc.TwoFactor.Enabled
+
+	// Credentials are never exported, passkeys are exported without their public keys
+	userDoc.Password = ""
+
+	archive, err := json.MarshalIndent(models.DataExport{
+		ExportedAt:       time.Now(),
+		Profile:          userDoc,
+		TwoFactorEnabled: twoFactorEnabled,
+		Passkeys:         userDoc.WebAuthnCredentials,
+		Sessions:         sessions,
+		KnownDevices:     knownDevices,
+		APIKeys:          apiKeys,
+		AuditEvents:      auditEvents,
+		Orders:           orders,
+		Products:         products,
+		Media:            media,
+	}, "", "  ")
+	if err != nil {
+		return "", nil, err
+	}
+
+	return userDoc.Email, archive, nil
+}
+
+// getAllAuditEvents returns every audit event the user is the actor or the target of
+func (service *AccountServiceImpl) getAllAuditEvents(userId primitive.ObjectID) ([]*models.AuditEvent, error) {
+	events := []*models.AuditEvent{}
+	for page := int64(1); ; page++ {
+		pageEvents, total, err := service.auditRepo.GetEvents(models.AuditEventFilter{UserID: &userId}, page, dataExportPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, pageEvents...)
+		if len(pageEvents) < dataExportPageSize || int64(len(events)) >= total {
+			return events, nil
+		}
+	}
+}
+
+// getAllStoreProducts returns every product of the user's store in every status
+func (service *AccountServiceImpl) getAllStoreProducts(userId primitive.ObjectID) ([]*models.Product, error) {
+	products := []*models.Product{}
+	for page := int64(1); ; page++ {
+		pageProducts, total, err := service.productRepo.GetProducts(models.ProductFilter{StoreID: &userId}, page, dataExportPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		products = append(products, pageProducts...)
+		if len(pageProducts) < dataExportPageSize || int64(len(products)) >= total {
+			return products, nil
+		}
+	}
+}
+
+func (service *AccountServiceImpl) confirmPassword(userId primitive.ObjectID, user models.UserPasswordConfirmRequest) error {
+	if err := validators.ValidateStruct(user); err != nil {
+		return err
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return err
+	}
+
+	if userDoc == nil {
+		return errors.New("User not found")
+	}
+
+	if result := helpers.VerifyPassword(userDoc.Password, user.Password); result != true {
+		return errors.New("Invalid password")
+	}
+
+	return nil
+}
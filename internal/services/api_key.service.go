@@ -0,0 +1,238 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxAPIKeysPerUser = 10
+	// Usage is recorded at most once per interval so that busy keys don't write on every request
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidAPIKey  = errors.New("Invalid API key")
+)
+
+type APIKeyService interface {
+	// Create returns the new key and the plain text key, which is never available again
+	Create(userId primitive.ObjectID, role models.Role, apiKey models.APIKeyCreateRequest, client models.ClientInfo) (*models.APIKey, string, error)
+	List(userId primitive.ObjectID) ([]*models.APIKey, error)
+	Get(userId primitive.ObjectID, keyId string) (*models.APIKey, error)
+	Update(userId primitive.ObjectID, role models.Role, keyId string, apiKey models.APIKeyUpdateRequest, client models.ClientInfo) (*models.APIKey, error)
+	Delete(userId primitive.ObjectID, keyId string, client models.ClientInfo) error
+	// Authenticate returns the key and its owner when the key may be used by the client
+	Authenticate(key string, client models.ClientInfo) (*models.APIKey, *models.User, error)
+}
+
+type APIKeyServiceImpl struct {
+	apiKeyRepo   mongodb.APIKeyMongoRepository
+	userRepo     mongodb.UserMongoRepository
+	AuditService AuditService
+}
+
+func NewAPIKeyService() APIKeyService {
+	return &APIKeyServiceImpl{
+		apiKeyRepo:   mongodb.NewAPIKeyMongoRepository(),
+		userRepo:     mongodb.NewUserMongoRepository(),
+		AuditService: NewAuditService(),
+	}
+}
+
+func (service *APIKeyServiceImpl) Create(userId primitive.ObjectID, role models.Role, apiKey models.APIKeyCreateRequest, client models.ClientInfo) (*models.APIKey, string, error) {
+	if err := validators.ValidateStruct(apiKey); err != nil {
+		return nil, "", err
+	}
+
+	if err := validateAPIKeyScopes(role, apiKey.Scopes); err != nil {
+		return nil, "", err
+	}
+
+	if apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(time.Now()) {
+		return nil, "", errors.New("Expiry date must be in the future")
+	}
+
+	count, err := service.apiKeyRepo.CountAPIKeysByUserID(userId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if count >= maxAPIKeysPerUser {
+		return nil, "", fmt.Errorf("You can't have more than %d API keys", maxAPIKeysPerUser)
+	}
+
+	key, prefix := helpers.GenerateAPIKey()
+	now := time.Now()
+	newKey := &models.APIKey{
+		ID:         primitive.NewObjectID(),
+		UserID:     userId,
+		Name:       apiKey.Name,
+		Prefix:     prefix,
+		KeyHash:    helpers.HashToken(key),
+		Scopes:     apiKey.Scopes,
+		AllowedIPs: apiKey.AllowedIPs,
+		ExpiresAt:  apiKey.ExpiresAt,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if newKey.AllowedIPs == nil {
+		newKey.AllowedIPs = []string{}
+	}
+
+	if err := service.apiKeyRepo.CreateAPIKey(newKey); err != nil {
+		return nil, "", err
+	}
+
+	service.AuditService.Record(newAuditEvent(models.AuditActionAPIKeyCreate, userId, client, nil))
+
+	return newKey, key, nil
+}
+
+func (service *APIKeyServiceImpl) List(userId primitive.ObjectID) ([]*models.APIKey, error) {
+	return service.apiKeyRepo.GetAPIKeysByUserID(userId)
+}
+
+func (service *APIKeyServiceImpl) Get(userId primitive.ObjectID, keyId string) (*models.APIKey, error) {
+	id, err := primitive.ObjectIDFromHex(keyId)
+	if err != nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	apiKey, err := service.apiKeyRepo.GetAPIKey(userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return apiKey, nil
+}
+
+// Update renames a key or changes its scopes or IP allowlist, the key itself and its expiry never change
+func (service *APIKeyServiceImpl) Update(userId primitive.ObjectID, role models.Role, keyId string, apiKey models.APIKeyUpdateRequest, client models.ClientInfo) (*models.APIKey, error) {
+	if err := validators.ValidateStruct(apiKey); err != nil {
+		return nil, err
+	}
+
+	existingKey, err := service.Get(userId, keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey.Name != "" {
+		existingKey.Name = apiKey.Name
+	}
+
+	if apiKey.Scopes != nil {
+		if err := validateAPIKeyScopes(role, apiKey.Scopes); err != nil {
+			return nil, err
+		}
+
+		existingKey.Scopes = apiKey.Scopes
+	}
+
+	if apiKey.AllowedIPs != nil {
+		existingKey.AllowedIPs = *apiKey.AllowedIPs
+	}
+
+	existingKey.UpdatedAt = time.Now()
+	updated, err := service.apiKeyRepo.UpdateAPIKey(existingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !updated {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	service.AuditService.Record(newAuditEvent(models.AuditActionAPIKeyUpdate, userId, client, nil))
+
+	return existingKey, nil
+}
+
+func (service *APIKeyServiceImpl) Delete(userId primitive.ObjectID, keyId string, client models.ClientInfo) error {
+	apiKey, err := service.Get(userId, keyId)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := service.apiKeyRepo.DeleteAPIKey(userId, apiKey.ID)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrAPIKeyNotFound
+	}
+
+	service.AuditService.Record(newAuditEvent(models.AuditActionAPIKeyDelete, userId, client, nil))
+
+	return nil
+}
+
+func (service *APIKeyServiceImpl) Authenticate(key string, client models.ClientInfo) (*models.APIKey, *models.User, error) {
+	prefix, ok := helpers.APIKeyPrefix(key)
+	if !ok {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := service.apiKeyRepo.GetAPIKeyByPrefix(prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if apiKey == nil || subtle.ConstantTimeCompare([]byte(helpers.HashToken(key)), []byte(apiKey.KeyHash)) != 1 {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if apiKey.IsExpired(now) {
+		return nil, nil, errors.New("API key has expired")
+	}
+
+	if !apiKey.AllowsIP(client.IP) {
+		return nil, nil, errors.New("API key is not allowed from this IP address")
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(apiKey.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Keys stop working while their owner is deactivated, only signing in reactivates the account
+	if userDoc == nil || !userDoc.IsActive {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		if err := service.apiKeyRepo.TouchAPIKey(apiKey.ID, client.IP); err != nil {
+			log.Println("Error while recording API key usage: ", err.Error())
+		}
+	}
+
+	return apiKey, userDoc, nil
+}
+
+// validateAPIKeyScopes checks that every scope is a permission held by the role of the key owner
+func validateAPIKeyScopes(role models.Role, scopes []models.Permission) error {
+	for _, scope := range scopes {
+		if !role.HasPermission(scope) {
+			return fmt.Errorf("Invalid scope %q", scope)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultAuditEventLimit int64 = 20
+)
+
+type AuditService interface {
+	// Record appends an event to the audit log, a failure is logged and never fails the audited action
+	Record(event *models.AuditEvent)
+	GetUserEvents(userId primitive.ObjectID, query models.AuditEventListRequest) (*AuditEventPage, error)
+	QueryEvents(query models.AuditEventQueryRequest) (*AuditEventPage, error)
+}
+
+// AuditEventPage is a page of audit events and the total number of matching events
+type AuditEventPage struct {
+	Events []*models.AuditEvent
+	Page   int64
+	Limit  int64
+	Total  int64
+}
+
+type AuditServiceImpl struct {
+	auditRepo mongodb.AuditEventMongoRepository
+}
+
+func NewAuditService() AuditService {
+	return &AuditServiceImpl{
+		auditRepo: mongodb.NewAuditEventMongoRepository(),
+	}
+}
+
+func (service *AuditServiceImpl) Record(event *models.AuditEvent) {
+	event.CreatedAt = time.Now()
+
+	if err := service.auditRepo.CreateEvent(event); err != nil {
+		log.Printf("Error while recording audit event %s: %s", event.Action, err.Error())
+	}
+}
+
+// GetUserEvents returns the events where the user is either the actor or the target
+func (service *AuditServiceImpl) GetUserEvents(userId primitive.ObjectID, query models.AuditEventListRequest) (*AuditEventPage, error) {
+	if err := validators.ValidateStruct(query); err != nil {
+		return nil, err
+	}
+
+	return service.getEvents(models.AuditEventFilter{UserID: &userId}, query.Page, query.Limit)
+}
+
+func (service *AuditServiceImpl) QueryEvents(query models.AuditEventQueryRequest) (*AuditEventPage, error) {
+	if err := validators.ValidateStruct(query); err != nil {
+		return nil, err
+	}
+
+	filter := models.AuditEventFilter{
+		Action:  models.AuditAction(query.Action),
+		Outcome: models.AuditOutcome(query.Outcome),
+		IP:      query.IP,
+		Email:   query.Email,
+	}
+
+	if query.UserID != "" {
+		userId, err := primitive.ObjectIDFromHex(query.UserID)
+		if err != nil {
+			return nil, err
+		}
+		filter.UserID = &userId
+	}
+
+	// Both dates were validated as RFC 3339 timestamps
+	if query.From != "" {
+		from, _ := time.Parse(time.RFC3339, query.From)
+		filter.From = &from
+	}
+
+	if query.To != "" {
+		to, _ := time.Parse(time.RFC3339, query.To)
+		filter.To = &to
+	}
+
+	return service.getEvents(filter, query.Page, query.Limit)
+}
+
+func (service *AuditServiceImpl) getEvents(filter models.AuditEventFilter, page int64, limit int64) (*AuditEventPage, error) {
+	if page == 0 {
+		page = 1
+	}
+
+	if limit == 0 {
+		limit = defaultAuditEventLimit
+	}
+
+	events, total, err := service.auditRepo.GetEvents(filter, page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuditEventPage{
+		Events: events,
+		Page:   page,
+		Limit:  limit,
+		Total:  total,
+	}, nil
+}
+
+// newAuditEvent builds an event of an action performed by a user on their own account.
+// The outcome is a failure when err is set and the error message becomes the reason.
+func newAuditEvent(action models.AuditAction, userId primitive.ObjectID, client models.ClientInfo, err error) *models.AuditEvent {
+	event := &models.AuditEvent{
+		Action:    action,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Outcome:   models.AuditOutcomeSuccess,
+	}
+
+	if !userId.IsZero() {
+		event.ActorID = &userId
+		event.TargetID = &userId
+	}
+
+	if err != nil {
+		event.Outcome = models.AuditOutcomeFailure
+		event.Reason = err.Error()
+	}
+
+	return event
+}
+
+// newLoginAuditEvent builds the event of a sign-in. A sign-in that still needs a second factor
+// is not a success yet, the mfa_verify event records whether it completes.
+func newLoginAuditEvent(action models.AuditAction, userId primitive.ObjectID, client models.ClientInfo, tokens *AuthTokens, err error) *models.AuditEvent {
+	event := newAuditEvent(action, userId, client, err)
+	if err == nil && tokens != nil && tokens.MFAToken != "" {
+		event.Outcome = models.AuditOutcomeMFARequired
+	}
+
+	return event
+}
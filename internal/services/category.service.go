@@ -0,0 +1,426 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/repositories/redis"
+	"github.com/mercan/ecommerce/internal/validators"
+)
+
+// Depths start at zero for root categories, a category can be nested five levels deep
+const maxCategoryDepth = 4
+
+var ErrCategoryNotFound = errors.New("Category not found")
+
+// CategoryService manages the category tree. The full tree is served from a Redis cache that is
+// invalidated by every change to a category.
+type CategoryService interface {
+	GetTree() ([]*models.CategoryNode, error)
+	Get(idOrSlug string) (*models.Category, []models.Breadcrumb, error)
+	Create(category models.CategoryCreateRequest) (*models.Category, error)
+	Update(categoryId string, category models.CategoryUpdateRequest) (*models.Category, error)
+	Move(categoryId string, move models.CategoryMoveRequest) (*models.Category, error)
+	Delete(categoryId string) error
+}
+
+type CategoryServiceImpl struct {
+	categoryRepo      mongodb.CategoryMongoRepository
+	productRepo       mongodb.ProductMongoRepository
+	categoryRedisRepo redis.CategoryRepository
+}
+
+func NewCategoryService() CategoryService {
+	return &CategoryServiceImpl{
+		categoryRepo:      mongodb.NewCategoryMongoRepository(),
+		productRepo:       mongodb.NewProductMongoRepository(),
+		categoryRedisRepo: redis.NewCategoryRedisRepository(),
+	}
+}
+
+// GetTree returns the root categories with their subcategories, siblings in order of position
+func (service *CategoryServiceImpl) GetTree() ([]*models.CategoryNode, error) {
+	cachedTree, err := service.categoryRedisRepo.GetCategoryTree()
+	if err != nil {
+		log.Println("Error while reading the cached category tree: ", err.Error())
+	}
+
+	if cachedTree != nil {
+		var tree []*models.CategoryNode
+		if err := json.Unmarshal(cachedTree, &tree); err == nil {
+			return tree, nil
+		}
+	}
+
+	categories, err := service.categoryRepo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	tree := buildCategoryTree(categories)
+	if encodedTree, err := json.Marshal(tree); err == nil {
+		expiration := config.GetTimeConfig().CategoryTreeCacheTime * time.Second
+		if err := service.categoryRedisRepo.SetCategoryTree(encodedTree, expiration); err != nil {
+			log.Println("Error while caching the category tree: ", err.Error())
+		}
+	}
+
+	return tree, nil
+}
+
+// Get returns a category by its id or slug and the breadcrumbs from the root to the category
+func (service *CategoryServiceImpl) Get(idOrSlug string) (*models.Category, []models.Breadcrumb, error) {
+	category, err := findCategory(service.categoryRepo, idOrSlug)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ancestors, err := service.categoryRepo.GetCategoriesByIDs(category.AncestorIDs())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Ancestors are sorted by depth, so the breadcrumbs start at the root
+	breadcrumbs := make([]models.Breadcrumb, 0, len(ancestors)+1)
+	for _, ancestor := range append(ancestors, category) {
+		breadcrumbs = append(breadcrumbs, models.Breadcrumb{
+			ID:   ancestor.ID,
+			Name: ancestor.Name,
+			Slug: ancestor.Slug,
+		})
+	}
+
+	return category, breadcrumbs, nil
+}
+
+func (service *CategoryServiceImpl) Create(category models.CategoryCreateRequest) (*models.Category, error) {
+	if err := validators.ValidateStruct(category); err != nil {
+		return nil, err
+	}
+
+	if category.Slug != "" && helpers.Slugify(category.Slug) != category.Slug {
+		return nil, errors.New("Invalid slug")
+	}
+
+	now := time.Now()
+	newCategory := &models.Category{
+		ID:          primitive.NewObjectID(),
+		Name:        category.Name,
+		Slug:        category.Slug,
+		Description: category.Description,
+		Position:    category.Position,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	newCategory.Path = models.CategoryPath("", newCategory.ID)
+
+	if category.ParentID != "" {
+		parent, err := service.getCategory(category.ParentID)
+		if err != nil {
+			return nil, err
+		}
+
+		if parent.Depth >= maxCategoryDepth {
+			return nil, errors.New("Categories can not be nested deeper")
+		}
+
+		newCategory.ParentID = &parent.ID
+		newCategory.Path = models.CategoryPath(parent.Path, newCategory.ID)
+		newCategory.Depth = parent.Depth + 1
+	}
+
+	if err := service.createCategory(newCategory); err != nil {
+		return nil, err
+	}
+
+	service.invalidateTree()
+	return newCategory, nil
+}
+
+func (service *CategoryServiceImpl) Update(categoryId string, category models.CategoryUpdateRequest) (*models.Category, error) {
+	if err := validators.ValidateStruct(category); err != nil {
+		return nil, err
+	}
+
+	existingCategory, err := service.getCategory(categoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	if category.Slug != nil {
+		if helpers.Slugify(*category.Slug) != *category.Slug {
+			return nil, errors.New("Invalid slug")
+		}
+		existingCategory.Slug = *category.Slug
+	}
+
+	if category.Name != nil {
+		existingCategory.Name = *category.Name
+	}
+
+	if category.Description != nil {
+		existingCategory.Description = *category.Description
+	}
+
+	if category.Position != nil {
+		existingCategory.Position = *category.Position
+	}
+
+	existingCategory.UpdatedAt = time.Now()
+	updated, err := service.categoryRepo.UpdateCategory(existingCategory)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errSlugTaken
+		}
+
+		return nil, err
+	}
+
+	if !updated {
+		return nil, ErrCategoryNotFound
+	}
+
+	service.invalidateTree()
+	return existingCategory, nil
+}
+
+// Move places a category with its subtree under another parent. A category can not be moved into its
+// own subtree and the deepest descendant has to stay within the maximum depth.
+func (service *CategoryServiceImpl) Move(categoryId string, move models.CategoryMoveRequest) (*models.Category, error) {
+	if err := validators.ValidateStruct(move); err != nil {
+		return nil, err
+	}
+
+	category, err := service.getCategory(categoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	var parent *models.Category
+	if move.ParentID != "" {
+		parent, err = service.getCategory(move.ParentID)
+		if err != nil {
+			return nil, err
+		}
+
+		if category.IsAncestorOf(parent) {
+			return nil, errors.New("A category can not be moved into its own subtree")
+		}
+	}
+
+	subtree, err := service.categoryRepo.GetSubtree(category.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	subtreeDepth := 0
+	for _, descendant := range subtree {
+		if descendant.Depth-category.Depth > subtreeDepth {
+			subtreeDepth = descendant.Depth - category.Depth
+		}
+	}
+
+	oldPath := category.Path
+	oldDepth := category.Depth
+	category.ParentID = nil
+	category.Path = models.CategoryPath("", category.ID)
+	category.Depth = 0
+	if parent != nil {
+		category.ParentID = &parent.ID
+		category.Path = models.CategoryPath(parent.Path, category.ID)
+		category.Depth = parent.Depth + 1
+	}
+
+	if category.Depth+subtreeDepth > maxCategoryDepth {
+		return nil, errors.New("Categories can not be nested deeper")
+	}
+
+	if move.Position != nil {
+		category.Position = *move.Position
+	}
+
+	category.UpdatedAt = time.Now()
+	moved, err := service.categoryRepo.MoveCategory(category, oldPath, category.Depth-oldDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	if !moved {
+		return nil, errors.New("The category tree was changed by another request, please try again")
+	}
+
+	service.invalidateTree()
+	return category, nil
+}
+
+// Delete removes a category without subcategories and unassigns it from its products
+func (service *CategoryServiceImpl) Delete(categoryId string) error {
+	category, err := service.getCategory(categoryId)
+	if err != nil {
+		return err
+	}
+
+	hasChildren, err := service.categoryRepo.HasChildren(category.ID)
+	if err != nil {
+		return err
+	}
+
+	if hasChildren {
+		return errors.New("Move or delete the subcategories of the category first")
+	}
+
+	if err := service.productRepo.RemoveCategoryFromProducts(category.ID); err != nil {
+		return err
+	}
+
+	deleted, err := service.categoryRepo.DeleteCategory(category.ID)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrCategoryNotFound
+	}
+
+	service.invalidateTree()
+	return nil
+}
+
+// createCategory inserts a category, a generated slug gets a random suffix when it is taken
+func (service *CategoryServiceImpl) createCategory(category *models.Category) error {
+	if category.Slug != "" {
+		if err := service.categoryRepo.CreateCategory(category); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return errSlugTaken
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	slug := helpers.Slugify(category.Name)
+	if slug == "" {
+		slug = "category"
+	}
+
+	category.Slug = slug
+	for attempt := 1; ; attempt++ {
+		err := service.categoryRepo.CreateCategory(category)
+		if err == nil {
+			return nil
+		}
+
+		if !mongo.IsDuplicateKeyError(err) || attempt == maxSlugAttempts {
+			return err
+		}
+
+		category.Slug = helpers.SlugWithSuffix(slug)
+	}
+}
+
+func (service *CategoryServiceImpl) getCategory(categoryId string) (*models.Category, error) {
+	id, err := primitive.ObjectIDFromHex(categoryId)
+	if err != nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	category, err := service.categoryRepo.GetCategoryByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if category == nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	return category, nil
+}
+
+func (service *CategoryServiceImpl) invalidateTree() {
+	if err := service.categoryRedisRepo.DeleteCategoryTree(); err != nil {
+		log.Println("Error while invalidating the category tree: ", err.Error())
+	}
+}
+
+// findCategory returns a category by its id or its slug
+func findCategory(categoryRepo mongodb.CategoryMongoRepository, idOrSlug string) (*models.Category, error) {
+	var category *models.Category
+	var err error
+
+	if id, idErr := primitive.ObjectIDFromHex(idOrSlug); idErr == nil {
+		category, err = categoryRepo.GetCategoryByID(id)
+	}
+
+	if err == nil && category == nil {
+		category, err = categoryRepo.GetCategoryBySlug(idOrSlug)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if category == nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	return category, nil
+}
+
+// categorySubtreeIDs returns the ids of a category, given by its id or slug, and of all of its descendants
+func categorySubtreeIDs(categoryRepo mongodb.CategoryMongoRepository, idOrSlug string) ([]primitive.ObjectID, error) {
+	category, err := findCategory(categoryRepo, idOrSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	subtree, err := categoryRepo.GetSubtree(category.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(subtree))
+	for _, descendant := range subtree {
+		ids = append(ids, descendant.ID)
+	}
+
+	return ids, nil
+}
+
+// buildCategoryTree nests categories sorted by depth under their parents
+func buildCategoryTree(categories []*models.Category) []*models.CategoryNode {
+	tree := []*models.CategoryNode{}
+	nodes := make(map[primitive.ObjectID]*models.CategoryNode, len(categories))
+	for _, category := range categories {
+		node := &models.CategoryNode{
+			ID:          category.ID,
+			Name:        category.Name,
+			Slug:        category.Slug,
+			Description: category.Description,
+			Position:    category.Position,
+			Children:    []*models.CategoryNode{},
+		}
+		nodes[category.ID] = node
+
+		if category.ParentID == nil {
+			tree = append(tree, node)
+			continue
+		}
+
+		if parent, ok := nodes[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	return tree
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/redis"
+)
+
+const (
+	ChallengeProviderHashcash  = "hashcash"
+	ChallengeProviderHCaptcha  = "hcaptcha"
+	ChallengeProviderTurnstile = "turnstile"
+
+	hcaptchaVerifyURL  = "https://api.hcaptcha.com/siteverify"
+	turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+)
+
+var ErrInvalidChallengeResponse = errors.New("Invalid challenge response")
+
+// Challenge is what a client needs to solve a challenge, the site key of a CAPTCHA widget
+// or the nonce and difficulty of a proof of work
+type Challenge struct {
+	Provider   string
+	SiteKey    string
+	Nonce      string
+	Difficulty int
+	ExpiresAt  *time.Time
+}
+
+// ChallengeProvider issues challenges and verifies the responses of clients that solved them
+type ChallengeProvider interface {
+	Name() string
+	Issue() (*Challenge, error)
+	// Verify returns ErrInvalidChallengeResponse when the response doesn't solve a challenge
+	Verify(response string, client models.ClientInfo) error
+}
+
+// newChallengeProvider returns the configured provider, the proof of work needs no external service
+// and is used when a CAPTCHA provider is not fully configured
+func newChallengeProvider(challengeConfig config.ChallengeConfig, challengeRedisRepo redis.ChallengeRepository) ChallengeProvider {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	switch challengeConfig.Provider {
+	case ChallengeProviderHCaptcha, ChallengeProviderTurnstile:
+		if challengeConfig.SiteKey == "" || challengeConfig.SecretKey == "" {
+			log.Printf("Challenge provider %s has no site key or secret key, falling back to %s", challengeConfig.Provider, ChallengeProviderHashcash)
+			break
+		}
+
+		verifyURL := challengeConfig.VerifyURL
+		if verifyURL == "" && challengeConfig.Provider == ChallengeProviderHCaptcha {
+			verifyURL = hcaptchaVerifyURL
+		} else if verifyURL == "" {
+			verifyURL = turnstileVerifyURL
+		}
+
+		return &captchaProvider{
+			name:       challengeConfig.Provider,
+			siteKey:    challengeConfig.SiteKey,
+			secretKey:  challengeConfig.SecretKey,
+			verifyURL:  verifyURL,
+			httpClient: httpClient,
+		}
+	case ChallengeProviderHashcash:
+	default:
+		log.Printf("Unknown challenge provider %q, falling back to %s", challengeConfig.Provider, ChallengeProviderHashcash)
+	}
+
+	return &hashcashProvider{
+		difficulty:         challengeConfig.HashcashDifficulty,
+		expiration:         challengeConfig.ExpireTime * time.Second,
+		challengeRedisRepo: challengeRedisRepo,
+	}
+}
+
+// captchaProvider verifies the tokens of hCaptcha and Cloudflare Turnstile widgets, both share
+// the same siteverify API
+type captchaProvider struct {
+	name       string
+	siteKey    string
+	secretKey  string
+	verifyURL  string
+	httpClient *http.Client
+}
+
+type captchaVerifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+func (provider *captchaProvider) Name() string {
+	return provider.name
+}
+
+func (provider *captchaProvider) Issue() (*Challenge, error) {
+	return &Challenge{
+		Provider: provider.name,
+		SiteKey:  provider.siteKey,
+	}, nil
+}
+
+func (provider *captchaProvider) Verify(response string, client models.ClientInfo) error {
+	form := url.Values{
+		"secret":   {provider.secretKey},
+		"response": {response},
+		"remoteip": {client.IP},
+		"sitekey":  {provider.siteKey},
+	}
+
+	request, err := http.NewRequest(http.MethodPost, provider.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	httpResponse, err := provider.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("Challenge verification failed with status %d", httpResponse.StatusCode)
+	}
+
+	var result captchaVerifyResponse
+	if err := json.NewDecoder(io.LimitReader(httpResponse.Body, 1<<20)).Decode(&result); err != nil {
+		return err
+	}
+
+	if !result.Success {
+		return ErrInvalidChallengeResponse
+	}
+
+	return nil
+}
+
+// hashcashProvider asks for a proof of work: a counter such that the SHA-256 hash of "<nonce>:<counter>"
+// starts with difficulty zero bits. The response is "<nonce>:<counter>" and every nonce is only accepted once.
+type hashcashProvider struct {
+	difficulty         int
+	expiration         time.Duration
+	challengeRedisRepo redis.ChallengeRepository
+}
+
+func (provider *hashcashProvider) Name() string {
+	return ChallengeProviderHashcash
+}
+
+func (provider *hashcashProvider) Issue() (*Challenge, error) {
+	nonce := helpers.GenerateHashcashNonce()
+	if err := provider.challengeRedisRepo.SetHashcashNonce(nonce, provider.expiration); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(provider.expiration)
+
+	return &Challenge{
+		Provider:   ChallengeProviderHashcash,
+		Nonce:      nonce,
+		Difficulty: provider.difficulty,
+		ExpiresAt:  &expiresAt,
+	}, nil
+}
+
+func (provider *hashcashProvider) Verify(response string, client models.ClientInfo) error {
+	nonce, counter, found := strings.Cut(response, ":")
+	if !found || !helpers.VerifyHashcash(nonce, counter, provider.difficulty) {
+		return ErrInvalidChallengeResponse
+	}
+
+	// The nonce is checked last so that invalid solutions can't burn issued nonces
+	consumed, err := provider.challengeRedisRepo.ConsumeHashcashNonce(nonce)
+	if err != nil {
+		return err
+	}
+
+	if !consumed {
+		return ErrInvalidChallengeResponse
+	}
+
+	return nil
+}
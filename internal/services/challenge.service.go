@@ -0,0 +1,76 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/redis"
+)
+
+type ChallengeService interface {
+	// IsRequired reports whether the client has to solve a challenge before using the endpoints of a scope
+	IsRequired(scope string, client models.ClientInfo) (bool, error)
+	Issue() (*Challenge, error)
+	Verify(response string, client models.ClientInfo) error
+	// RecordAttempt counts a request of the client to the endpoints of a scope, errors are only logged
+	RecordAttempt(scope string, client models.ClientInfo, failed bool)
+}
+
+type ChallengeServiceImpl struct {
+	challengeRedisRepo redis.ChallengeRepository
+	challengeConfig    config.ChallengeConfig
+	provider           ChallengeProvider
+}
+
+func NewChallengeService() ChallengeService {
+	challengeRedisRepo := redis.NewChallengeRedisRepository()
+	challengeConfig := config.GetChallengeConfig()
+
+	return &ChallengeServiceImpl{
+		challengeRedisRepo: challengeRedisRepo,
+		challengeConfig:    challengeConfig,
+		provider:           newChallengeProvider(challengeConfig, challengeRedisRepo),
+	}
+}
+
+// IsRequired challenges clients that have made too many requests or too many failed requests
+// within the window, both counted per IP address
+func (service *ChallengeServiceImpl) IsRequired(scope string, client models.ClientInfo) (bool, error) {
+	counters, err := service.challengeRedisRepo.GetCounters(
+		redis.ChallengeRequestsKey(scope, client.IP),
+		redis.ChallengeFailuresKey(scope, client.IP),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	requests, failures := counters[0], counters[1]
+
+	return requests >= service.challengeConfig.RequestThreshold || failures >= service.challengeConfig.FailureThreshold, nil
+}
+
+func (service *ChallengeServiceImpl) Issue() (*Challenge, error) {
+	return service.provider.Issue()
+}
+
+func (service *ChallengeServiceImpl) Verify(response string, client models.ClientInfo) error {
+	return service.provider.Verify(response, client)
+}
+
+func (service *ChallengeServiceImpl) RecordAttempt(scope string, client models.ClientInfo, failed bool) {
+	window := service.challengeConfig.Window * time.Second
+
+	if _, err := service.challengeRedisRepo.IncrCounter(redis.ChallengeRequestsKey(scope, client.IP), window); err != nil {
+		log.Println("Error while counting challenge requests: ", err.Error())
+	}
+
+	if !failed {
+		return
+	}
+
+	if _, err := service.challengeRedisRepo.IncrCounter(redis.ChallengeFailuresKey(scope, client.IP), window); err != nil {
+		log.Println("Error while counting challenge failures: ", err.Error())
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/redis"
+)
+
+// LockoutError is returned while an account is locked or has to wait before the next attempt.
+// JustLocked is set on the attempt that locked the account, so that the owner is notified once.
+type LockoutError struct {
+	RetryAfter time.Duration
+	JustLocked bool
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("Too many failed attempts, please try again in %d seconds", int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+type LockoutService interface {
+	CheckLogin(email string) error
+	RegisterLoginFailure(email string) error
+	ResetLogin(email string) error
+	RegisterVerificationFailure(kind string, identifier string) (bool, error)
+	CheckSecondFactor(userId string) error
+	RegisterSecondFactorFailure(userId string) error
+	ResetSecondFactor(userId string) error
+}
+
+type LockoutServiceImpl struct {
+	lockoutRedisRepo redis.LockoutRepository
+	lockoutConfig    config.LockoutConfig
+}
+
+func NewLockoutService() LockoutService {
+	return &LockoutServiceImpl{
+		lockoutRedisRepo: redis.NewLockoutRedisRepository(),
+		lockoutConfig:    config.GetLockoutConfig(),
+	}
+}
+
+// CheckLogin returns a *LockoutError when the account may not attempt to log in yet
+func (service *LockoutServiceImpl) CheckLogin(email string) error {
+	return service.checkLock(redis.LoginLockKey(normalizeIdentifier(email)))
+}
+
+// RegisterLoginFailure counts a failed login. Once DelayThreshold failures are reached every further
+// failure doubles the wait before the next attempt, and MaxLoginAttempts failures lock the account.
+func (service *LockoutServiceImpl) RegisterLoginFailure(email string) error {
+	email = normalizeIdentifier(email)
+	return service.registerFailure(redis.LoginFailuresKey(email), redis.LoginLockKey(email))
+}
+
+func (service *LockoutServiceImpl) ResetLogin(email string) error {
+	return service.lockoutRedisRepo.ResetFailures(redis.LoginFailuresKey(normalizeIdentifier(email)))
+}
+
+// CheckSecondFactor returns a *LockoutError when the account may not attempt a second factor yet
+func (service *LockoutServiceImpl) CheckSecondFactor(userId string) error {
+	return service.checkLock(redis.SecondFactorLockKey(userId))
+}
+
+// RegisterSecondFactorFailure counts a wrong second factor like a failed login. Every correct password
+// starts a new "mfa pending" token, so the failures are counted per account rather than per token.
+func (service *LockoutServiceImpl) RegisterSecondFactorFailure(userId string) error {
+	return service.registerFailure(redis.SecondFactorFailuresKey(userId), redis.SecondFactorLockKey(userId))
+}
+
+func (service *LockoutServiceImpl) ResetSecondFactor(userId string) error {
+	return service.lockoutRedisRepo.ResetFailures(redis.SecondFactorFailuresKey(userId))
+}
+
+func (service *LockoutServiceImpl) checkLock(lockKey string) error {
+	ttl, err := service.lockoutRedisRepo.GetLockTTL(lockKey)
+	if err != nil {
+		return err
+	}
+
+	if ttl > 0 {
+		return &LockoutError{RetryAfter: ttl}
+	}
+
+	return nil
+}
+
+func (service *LockoutServiceImpl) registerFailure(failuresKey string, lockKey string) error {
+	window := service.lockoutConfig.FailureWindow * time.Second
+
+	failures, err := service.lockoutRedisRepo.IncrFailures(failuresKey, window)
+	if err != nil {
+		return err
+	}
+
+	if failures >= service.lockoutConfig.MaxLoginAttempts {
+		duration := service.lockoutConfig.LockoutDuration * time.Second
+		if err := service.lockoutRedisRepo.SetLock(lockKey, duration); err != nil {
+			return err
+		}
+
+		// A fresh window starts once the lock expires
+		if err := service.lockoutRedisRepo.ResetFailures(failuresKey); err != nil {
+			return err
+		}
+
+		return &LockoutError{RetryAfter: duration, JustLocked: true}
+	}
+
+	if failures >= service.lockoutConfig.DelayThreshold {
+		delay := time.Duration(1<<uint(failures-service.lockoutConfig.DelayThreshold)) * time.Second
+		if maxDelay := service.lockoutConfig.MaxDelay * time.Second; delay > maxDelay {
+			delay = maxDelay
+		}
+
+		if err := service.lockoutRedisRepo.SetLock(lockKey, delay); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RegisterVerificationFailure counts a wrong verification code and reports whether the
+// maximum number of attempts has been reached, in which case the code must be invalidated
+func (service *LockoutServiceImpl) RegisterVerificationFailure(kind string, identifier string) (bool, error) {
+	var window time.Duration
+	switch kind {
+	case "phone":
+		window = config.GetTimeConfig().PhoneExpireTime * time.Second
+	case "login-otp":
+		window = config.GetTimeConfig().LoginOTPExpireTime * time.Second
+	default:
+		window = config.GetTimeConfig().EmailExpireTime * time.Second
+	}
+
+	failures, err := service.lockoutRedisRepo.IncrFailures(redis.VerificationFailuresKey(kind, identifier), window)
+	if err != nil {
+		return false, err
+	}
+
+	return failures >= service.lockoutConfig.MaxVerificationAttempts, nil
+}
+
+// resetSignInFailures forgives the failed attempts of an account once the last factor of a sign-in passed
+func resetSignInFailures(lockoutService LockoutService, user *models.User) {
+	if err := lockoutService.ResetLogin(user.Email); err != nil {
+		log.Println("Error while resetting login failures in redis: ", err.Error())
+	}
+
+	if err := lockoutService.ResetSecondFactor(user.ID.Hex()); err != nil {
+		log.Println("Error while resetting second factor failures in redis: ", err.Error())
+	}
+}
+
+func normalizeIdentifier(identifier string) string {
+	return strings.ToLower(strings.TrimSpace(identifier))
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+// memoryLockoutRepository keeps counters and locks in memory, locks never expire on their own
+type memoryLockoutRepository struct {
+	failures map[string]int64
+	locks    map[string]time.Duration
+}
+
+func newMemoryLockoutRepository() *memoryLockoutRepository {
+	return &memoryLockoutRepository{failures: make(map[string]int64), locks: make(map[string]time.Duration)}
+}
+
+func (repo *memoryLockoutRepository) IncrFailures(key string, window time.Duration) (int64, error) {
+	repo.failures[key]++
+	return repo.failures[key], nil
+}
+
+func (repo *memoryLockoutRepository) ResetFailures(key string) error {
+	delete(repo.failures, key)
+	return nil
+}
+
+func (repo *memoryLockoutRepository) SetLock(key string, duration time.Duration) error {
+	repo.locks[key] = duration
+	return nil
+}
+
+func (repo *memoryLockoutRepository) GetLockTTL(key string) (time.Duration, error) {
+	return repo.locks[key], nil
+}
+
+func TestLockoutSecondFactor(t *testing.T) {
+	repo := newMemoryLockoutRepository()
+	service := &LockoutServiceImpl{
+		lockoutRedisRepo: repo,
+		lockoutConfig: config.LockoutConfig{
+			MaxLoginAttempts: 5,
+			DelayThreshold:   3,
+			MaxDelay:         60,
+			FailureWindow:    900,
+			LockoutDuration:  900,
+		},
+	}
+	const userId = "65f0c0ffee0000000000000a"
+
+	// Failures below the delay threshold do not hold back the next attempt
+	for i := 0; i < 2; i++ {
+		if err := service.RegisterSecondFactorFailure(userId); err != nil {
+			t.Fatalf("failure %d: RegisterSecondFactorFailure() error = %v", i+1, err)
+		}
+	}
+
+	if err := service.CheckSecondFactor(userId); err != nil {
+		t.Fatalf("CheckSecondFactor() after 2 failures error = %v, want nil", err)
+	}
+
+	// The third and fourth failures delay the next attempt by 1 and 2 seconds
+	for i, want := range []time.Duration{time.Second, 2 * time.Second} {
+		if err := service.RegisterSecondFactorFailure(userId); err != nil {
+			t.Fatalf("failure %d: RegisterSecondFactorFailure() error = %v", i+3, err)
+		}
+
+		var lockoutErr *LockoutError
+		if err := service.CheckSecondFactor(userId); !errors.As(err, &lockoutErr) || lockoutErr.RetryAfter != want {
+			t.Fatalf("CheckSecondFactor() after %d failures = %v, want a wait of %s", i+3, err, want)
+		}
+	}
+
+	// The fifth failure locks second factors of the account, whichever MFA token they come with
+	var lockoutErr *LockoutError
+	if err := service.RegisterSecondFactorFailure(userId); !errors.As(err, &lockoutErr) || !lockoutErr.JustLocked {
+		t.Fatalf("RegisterSecondFactorFailure() at the limit = %v, want a new lock", err)
+	}
+
+	if lockoutErr.RetryAfter != 900*time.Second {
+		t.Errorf("lock duration = %s, want %s", lockoutErr.RetryAfter, 900*time.Second)
+	}
+
+	// Password logins are counted apart
+	if err := service.CheckLogin("owner@example.com"); err != nil {
+		t.Errorf("CheckLogin() error = %v, want nil", err)
+	}
+}
+
+func TestResetSignInFailures(t *testing.T) {
+	repo := newMemoryLockoutRepository()
+	service := &LockoutServiceImpl{
+		lockoutRedisRepo: repo,
+		lockoutConfig:    config.LockoutConfig{MaxLoginAttempts: 10, DelayThreshold: 5, FailureWindow: 900},
+	}
+
+	user := &models.User{ID: primitive.NewObjectID(), Email: "owner@example.com"}
+	if err := service.RegisterLoginFailure(user.Email); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.RegisterSecondFactorFailure(user.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	resetSignInFailures(service, user)
+
+	if len(repo.failures) != 0 {
+		t.Errorf("failures left after resetSignInFailures() = %v, want none", repo.failures)
+	}
+}
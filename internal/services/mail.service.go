@@ -1,9 +1,11 @@
 package services
 
 import (
+	"encoding/base64"
 	"errors"
 	"github.com/mercan/ecommerce/internal/config"
 	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
 	"github.com/mercan/ecommerce/internal/repositories/redis"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
@@ -12,6 +14,9 @@ import (
 type MailService interface {
 	SendVerificationEmail(firstName string, email string) error
 	SendForgotPasswordEmail(email string) error
+	SendSecurityNotificationEmail(notification models.SecurityNotification) error
+	SendMagicLinkEmail(email string) error
+	SendDataExportEmail(email string, archive []byte) error
 }
 
 type MailServiceImpl struct {
@@ -21,6 +26,9 @@ type MailServiceImpl struct {
 	sendgridFromEmail                string
 	sendgridVerificationTemplateID   string
 	sendgridForgotPasswordTemplateID string
+	sendgridSecurityTemplateID       string
+	sendgridMagicLinkTemplateID      string
+	sendgridDataExportTemplateID     string
 }
 
 func NewMailService() MailService {
@@ -31,6 +39,9 @@ func NewMailService() MailService {
 		sendgridFromEmail:                config.GetSendgridConfig().FromEmail,
 		sendgridVerificationTemplateID:   config.GetSendgridConfig().VerificationTemplateID,
 		sendgridForgotPasswordTemplateID: config.GetSendgridConfig().ForgotPasswordTemplateID,
+		sendgridSecurityTemplateID:       config.GetSendgridConfig().SecurityNotificationTemplateID,
+		sendgridMagicLinkTemplateID:      config.GetSendgridConfig().MagicLinkTemplateID,
+		sendgridDataExportTemplateID:     config.GetSendgridConfig().DataExportTemplateID,
 	}
 }
 
@@ -73,10 +84,15 @@ func (service *MailServiceImpl) SendForgotPasswordEmail(email string) error {
 	m := mail.NewV3Mail()
 	e := mail.NewEmail(service.sendgridFromName, service.sendgridFromEmail)
 	forgotPasswordToken := helpers.GenerateForgotPasswordToken()
-	forgotPasswordLink := "http://localhost:" + config.GetServerConfig().Port + "/auth/forgot-password/" + forgotPasswordToken
+	forgotPasswordLink := config.GetServerConfig().BaseURL + "/auth/reset-password/" + forgotPasswordToken
+
+	// The token is stored first so that the link works as soon as it arrives
+	if err := service.authRedisRepo.SetForgotPasswordToken(forgotPasswordToken, email); err != nil {
+		return err
+	}
 
 	m.SetFrom(e)
-	m.SetTemplateID(service.sendgridVerificationTemplateID)
+	m.SetTemplateID(service.sendgridForgotPasswordTemplateID)
 
 	p := mail.NewPersonalization()
 	to := mail.NewEmail("", email)
@@ -89,12 +105,116 @@ func (service *MailServiceImpl) SendForgotPasswordEmail(email string) error {
 	request.Method = "POST"
 	request.Body = mail.GetRequestBody(m)
 
-	if _, err := sendgrid.API(request); err != nil {
+	if response, err := sendgrid.API(request); err != nil {
+		return err
+	} else {
+		if response.StatusCode != 202 {
+			return errors.New(response.Body)
+		}
+	}
+
+	return nil
+}
+
+func (service *MailServiceImpl) SendSecurityNotificationEmail(notification models.SecurityNotification) error {
+	m := mail.NewV3Mail()
+	e := mail.NewEmail(service.sendgridFromName, service.sendgridFromEmail)
+
+	m.SetFrom(e)
+	m.SetTemplateID(service.sendgridSecurityTemplateID)
+
+	p := mail.NewPersonalization()
+	to := mail.NewEmail("", notification.Email)
+
+	p.AddTos(to)
+	p.SetDynamicTemplateData("type", string(notification.Type))
+	for key, value := range notification.Data {
+		p.SetDynamicTemplateData(key, value)
+	}
+	m.AddPersonalizations(p)
+
+	request := sendgrid.GetRequest(service.sendgridAPIKey, "/v3/mail/send", "https://api.sendgrid.com")
+	request.Method = "POST"
+	request.Body = mail.GetRequestBody(m)
+
+	if response, err := sendgrid.API(request); err != nil {
+		return err
+	} else {
+		if response.StatusCode != 202 {
+			return errors.New(response.Body)
+		}
+	}
+
+	return nil
+}
+
+func (service *MailServiceImpl) SendMagicLinkEmail(email string) error {
+	m := mail.NewV3Mail()
+	e := mail.NewEmail(service.sendgridFromName, service.sendgridFromEmail)
+	magicLinkToken := helpers.GenerateRefreshToken()
+	magicLink := config.GetServerConfig().BaseURL + "/auth/login/magic-link/verify?token=" + magicLinkToken
+
+	// The token is stored first so that the link works as soon as it arrives
+	if err := service.authRedisRepo.SetMagicLinkToken(magicLinkToken, email); err != nil {
+		return err
+	}
+
+	m.SetFrom(e)
+	m.SetTemplateID(service.sendgridMagicLinkTemplateID)
+
+	p := mail.NewPersonalization()
+	to := mail.NewEmail("", email)
+
+	p.AddTos(to)
+	p.SetDynamicTemplateData("MagicLink", magicLink)
+	m.AddPersonalizations(p)
+
+	request := sendgrid.GetRequest(service.sendgridAPIKey, "/v3/mail/send", "https://api.sendgrid.com")
+	request.Method = "POST"
+	request.Body = mail.GetRequestBody(m)
+
+	if response, err := sendgrid.API(request); err != nil {
 		return err
+	} else {
+		if response.StatusCode != 202 {
+			return errors.New(response.Body)
+		}
 	}
 
-	if err := service.authRedisRepo.SetForgotPasswordToken(email, forgotPasswordToken); err != nil {
+	return nil
+}
+
+// SendDataExportEmail sends the data export archive as an attachment
+func (service *MailServiceImpl) SendDataExportEmail(email string, archive []byte) error {
+	m := mail.NewV3Mail()
+	e := mail.NewEmail(service.sendgridFromName, service.sendgridFromEmail)
+
+	m.SetFrom(e)
+	m.SetTemplateID(service.sendgridDataExportTemplateID)
+
+	p := mail.NewPersonalization()
+	to := mail.NewEmail("", email)
+
+	p.AddTos(to)
+	m.AddPersonalizations(p)
+
+	attachment := mail.NewAttachment()
+	attachment.SetContent(base64.StdEncoding.EncodeToString(archive))
+	attachment.SetType("application/json")
+	attachment.SetFilename("data-export.json")
+	attachment.SetDisposition("attachment")
+	m.AddAttachment(attachment)
+
+	request := sendgrid.GetRequest(service.sendgridAPIKey, "/v3/mail/send", "https://api.sendgrid.com")
+	request.Method = "POST"
+	request.Body = mail.GetRequestBody(m)
+
+	if response, err := sendgrid.API(request); err != nil {
 		return err
+	} else {
+		if response.StatusCode != 202 {
+			return errors.New(response.Body)
+		}
 	}
 
 	return nil
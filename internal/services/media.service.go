@@ -0,0 +1,369 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+)
+
+const (
+	maxProductImages = 20
+	// Larger images are rejected before they are decoded, a small file can decode to a huge bitmap.
+	// A decoded image takes 4 bytes per pixel, about 100 MB at this size.
+	maxImagePixels = 25_000_000
+	// Images decoded at the same time, which bounds the memory taken by decoded bitmaps
+	maxConcurrentImageDecodes = 4
+)
+
+// imageDecodeSlots limits the uploads that are decoded and resized at the same time
+var imageDecodeSlots = make(chan struct{}, maxConcurrentImageDecodes)
+
+var (
+	ErrMediaNotFound        = errors.New("Media not found")
+	ErrMediaTooLarge        = errors.New("The file is too large")
+	ErrUnsupportedMediaType = errors.New("Unsupported image type, upload a JPEG, PNG or GIF image")
+)
+
+// Every upload is stored in these sizes, each fitting within the given number of pixels. The first
+// variant is attached to the product or profile.
+var mediaVariantSizes = []struct {
+	Name    string
+	MaxSize int
+}{
+	{Name: "large", MaxSize: 2048},
+	{Name: "medium", MaxSize: 800},
+	{Name: "small", MaxSize: 400},
+	{Name: "thumbnail", MaxSize: 150},
+}
+
+// Content types of the uploads that are accepted and of the variants they are stored as. GIFs are
+// stored as PNG, only their first frame is kept.
+var mediaContentTypes = map[string]string{
+	"image/jpeg": "image/jpeg",
+	"image/png":  "image/png",
+	"image/gif":  "image/png",
+}
+
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+var userImageFields = map[models.MediaTarget]string{
+	models.MediaTargetProfileImage: "profile_image",
+	models.MediaTargetBannerImage:  "banner_image",
+}
+
+// MediaService stores uploaded images in the configured media storage. Uploads are re-encoded, which
+// drops metadata such as the location a photo was taken at, and resized into variants.
+type MediaService interface {
+	UploadProductImage(userId primitive.ObjectID, permissions models.Permissions, productId string, data []byte) (*models.Media, error)
+	DeleteProductImage(userId primitive.ObjectID, permissions models.Permissions, productId string, mediaId string) error
+	UploadUserImage(userId primitive.ObjectID, target models.MediaTarget, data []byte) (*models.Media, error)
+	DeleteUserImage(userId primitive.ObjectID, mediaId string) error
+	DeleteUserMedia(userId primitive.ObjectID) error
+	DeleteProductMedia(productId primitive.ObjectID) error
+}
+
+type MediaServiceImpl struct {
+	storage        MediaStorage
+	mediaRepo      mongodb.MediaMongoRepository
+	productRepo    mongodb.ProductMongoRepository
+	userRepo       mongodb.UserMongoRepository
+	productService ProductService
+}
+
+func NewMediaService() MediaService {
+	return newMediaService(NewProductService())
+}
+
+// newMediaService is shared with the product service, which deletes the images of a product with it
+func newMediaService(productService ProductService) *MediaServiceImpl {
+	return &MediaServiceImpl{
+		storage:        newMediaStorage(MediaStorageName(), config.GetMediaConfig(), config.GetCloudinaryConfig()),
+		mediaRepo:      mongodb.NewMediaMongoRepository(),
+		productRepo:    mongodb.NewProductMongoRepository(),
+		userRepo:       mongodb.NewUserMongoRepository(),
+		productService: productService,
+	}
+}
+
+// UploadProductImage stores an image and appends it to the images of a product of the user's store
+func (service *MediaServiceImpl) UploadProductImage(userId primitive.ObjectID, permissions models.Permissions, productId string, data []byte) (*models.Media, error) {
+	product, err := service.productService.GetStoreProduct(userId, permissions, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(product.Images) >= maxProductImages {
+		return nil, errors.New("A product can have at most 20 images")
+	}
+
+	media, err := service.storeImage(userId, models.MediaTargetProduct, product.ID, data)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := service.productRepo.AddProductImage(product.ID, media.URL, maxProductImages)
+	if err == nil && !added {
+		err = errors.New("A product can have at most 20 images")
+	}
+
+	if err != nil {
+		service.deleteMedia(media)
+		return nil, err
+	}
+
+	return media, nil
+}
+
+// DeleteProductImage removes an uploaded image from a product of the user's store and deletes its files
+func (service *MediaServiceImpl) DeleteProductImage(userId primitive.ObjectID, permissions models.Permissions, productId string, mediaId string) error {
+	product, err := service.productService.GetStoreProduct(userId, permissions, productId)
+	if err != nil {
+		return err
+	}
+
+	media, err := service.getMedia(mediaId)
+	if err != nil {
+		return err
+	}
+
+	if media.Target != models.MediaTargetProduct || media.TargetID != product.ID {
+		return ErrMediaNotFound
+	}
+
+	if err := service.productRepo.RemoveProductImage(product.ID, media.URL); err != nil {
+		return err
+	}
+
+	service.deleteMedia(media)
+	return nil
+}
+
+// UploadUserImage stores an image as the profile or banner image of the user, the previous one is deleted
+func (service *MediaServiceImpl) UploadUserImage(userId primitive.ObjectID, target models.MediaTarget, data []byte) (*models.Media, error) {
+	field, ok := userImageFields[target]
+	if !ok {
+		return nil, errors.New("Invalid media target")
+	}
+
+	previousMedia, err := service.mediaRepo.GetMediaByTarget(target, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	media, err := service.storeImage(userId, target, userId, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.userRepo.SetImage(userId, field, media.URL); err != nil {
+		service.deleteMedia(media)
+		return nil, err
+	}
+
+	for _, previous := range previousMedia {
+		service.deleteMedia(previous)
+	}
+
+	return media, nil
+}
+
+// DeleteUserImage removes a profile or banner image of the user and deletes its files
+func (service *MediaServiceImpl) DeleteUserImage(userId primitive.ObjectID, mediaId string) error {
+	media, err := service.getMedia(mediaId)
+	if err != nil {
+		return err
+	}
+
+	field, ok := userImageFields[media.Target]
+	if !ok || media.TargetID != userId {
+		return ErrMediaNotFound
+	}
+
+	if err := service.userRepo.UnsetImage(userId, field, media.URL); err != nil {
+		return err
+	}
+
+	service.deleteMedia(media)
+	return nil
+}
+
+// DeleteUserMedia deletes the profile and banner images of a user whose account is deleted. Product
+// images are kept with the archived products.
+func (service *MediaServiceImpl) DeleteUserMedia(userId primitive.ObjectID) error {
+	for target := range userImageFields {
+		media, err := service.mediaRepo.GetMediaByTarget(target, userId)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range media {
+			service.deleteMedia(m)
+		}
+	}
+
+	return nil
+}
+
+// DeleteProductMedia deletes the uploaded images of a deleted product
+func (service *MediaServiceImpl) DeleteProductMedia(productId primitive.ObjectID) error {
+	media, err := service.mediaRepo.GetMediaByTarget(models.MediaTargetProduct, productId)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range media {
+		service.deleteMedia(m)
+	}
+
+	return nil
+}
+
+// storeImage checks an upload, stores it in every variant size and records it
+func (service *MediaServiceImpl) storeImage(ownerId primitive.ObjectID, target models.MediaTarget, targetId primitive.ObjectID, data []byte) (*models.Media, error) {
+	if int64(len(data)) > config.GetMediaConfig().MaxUploadSize {
+		return nil, ErrMediaTooLarge
+	}
+
+	contentType, ok := mediaContentTypes[helpers.SniffContentType(data)]
+	if !ok {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	variants, err := encodeImageVariants(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	media := &models.Media{
+		ID:          primitive.NewObjectID(),
+		OwnerID:     ownerId,
+		Target:      target,
+		TargetID:    targetId,
+		ContentType: contentType,
+		Variants:    make([]models.MediaVariant, 0, len(mediaVariantSizes)),
+		CreatedAt:   time.Now(),
+	}
+
+	for _, variant := range variants {
+		key := string(target) + "/" + targetId.Hex() + "/" + media.ID.Hex() + "/" + variant.Name + mediaExtensions[contentType]
+		url, err := service.storage.Put(key, contentType, variant.Data)
+		if err != nil {
+			service.deleteVariants(media.Variants)
+			return nil, err
+		}
+
+		media.Variants = append(media.Variants, models.MediaVariant{
+			Name:   variant.Name,
+			URL:    url,
+			Key:    key,
+			Width:  variant.Width,
+			Height: variant.Height,
+			Size:   len(variant.Data),
+		})
+	}
+
+	media.URL = media.Variants[0].URL
+	if err := service.mediaRepo.CreateMedia(media); err != nil {
+		service.deleteVariants(media.Variants)
+		return nil, err
+	}
+
+	return media, nil
+}
+
+// encodedImageVariant is a variant size of an upload, encoded but not stored yet
+type encodedImageVariant struct {
+	Name   string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// encodeImageVariants decodes an upload and encodes it in every variant size. The decoded bitmaps
+// are only kept while a decode slot is held, the encoded variants are much smaller.
+func encodeImageVariants(data []byte, contentType string) ([]encodedImageVariant, error) {
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	if imageConfig.Width*imageConfig.Height > maxImagePixels {
+		return nil, errors.New("The image has too many pixels")
+	}
+
+	imageDecodeSlots <- struct{}{}
+	defer func() { <-imageDecodeSlots }()
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	// Every variant is resized from the previous one, which is faster than starting from the upload
+	variants := make([]encodedImageVariant, 0, len(mediaVariantSizes))
+	for _, size := range mediaVariantSizes {
+		resized := helpers.ResizeImage(img, size.MaxSize)
+		img = resized
+
+		encoded, err := helpers.EncodeImage(resized, contentType)
+		if err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, encodedImageVariant{
+			Name:   size.Name,
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+			Data:   encoded,
+		})
+	}
+
+	return variants, nil
+}
+
+func (service *MediaServiceImpl) getMedia(mediaId string) (*models.Media, error) {
+	id, err := primitive.ObjectIDFromHex(mediaId)
+	if err != nil {
+		return nil, ErrMediaNotFound
+	}
+
+	media, err := service.mediaRepo.GetMediaByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if media == nil {
+		return nil, ErrMediaNotFound
+	}
+
+	return media, nil
+}
+
+// deleteMedia deletes the files and the record of an image, failures only leave unused files behind
+func (service *MediaServiceImpl) deleteMedia(media *models.Media) {
+	service.deleteVariants(media.Variants)
+
+	if _, err := service.mediaRepo.DeleteMedia(media.ID); err != nil {
+		log.Printf("Error while deleting media %s: %s", media.ID.Hex(), err.Error())
+	}
+}
+
+func (service *MediaServiceImpl) deleteVariants(variants []models.MediaVariant) {
+	for _, variant := range variants {
+		if err := service.storage.Delete(variant.Key); err != nil {
+			log.Printf("Error while deleting media file %s: %s", variant.Key, err.Error())
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mercan/ecommerce/internal/config"
+)
+
+const (
+	MediaStorageCloudinary = "cloudinary"
+	MediaStorageLocal      = "local"
+
+	cloudinaryAPIURL = "https://api.cloudinary.com/v1_1/"
+)
+
+// MediaStorage stores the files of uploaded images. Keys are slash separated paths chosen by the
+// media service, e.g. products/<id>/<media>/thumbnail.jpg.
+type MediaStorage interface {
+	Name() string
+	// Put stores a file and returns the public URL it is served from
+	Put(key string, contentType string, data []byte) (string, error)
+	Delete(key string) error
+}
+
+var (
+	mediaStorageName     string
+	mediaStorageNameOnce sync.Once
+)
+
+// MediaStorageName returns the storage uploads are kept in. A Cloudinary storage that is not
+// configured falls back to the local filesystem in development, anywhere else the startup fails.
+func MediaStorageName() string {
+	mediaStorageNameOnce.Do(func() {
+		storage := config.GetMediaConfig().Storage
+		switch storage {
+		case MediaStorageLocal:
+			mediaStorageName = MediaStorageLocal
+		case MediaStorageCloudinary:
+			cloudinaryConfig := config.GetCloudinaryConfig()
+			if cloudinaryConfig.CloudName != "" && cloudinaryConfig.APIKey != "" && cloudinaryConfig.APISecret != "" {
+				mediaStorageName = MediaStorageCloudinary
+				return
+			}
+
+			if config.GetServerConfig().Environment != "development" {
+				log.Fatalf("Media storage %s is not configured", MediaStorageCloudinary)
+			}
+
+			log.Printf("Media storage %s is not configured, falling back to %s", MediaStorageCloudinary, MediaStorageLocal)
+			mediaStorageName = MediaStorageLocal
+		default:
+			log.Fatalf("Unknown media storage %s", storage)
+		}
+	})
+
+	return mediaStorageName
+}
+
+// newMediaStorage returns the storage of the given name
+func newMediaStorage(name string, mediaConfig config.MediaConfig, cloudinaryConfig config.CloudinaryConfig) MediaStorage {
+	if name == MediaStorageCloudinary {
+		return &cloudinaryMediaStorage{
+			cloudName:  cloudinaryConfig.CloudName,
+			apiKey:     cloudinaryConfig.APIKey,
+			apiSecret:  cloudinaryConfig.APISecret,
+			httpClient: &http.Client{Timeout: 30 * time.Second},
+		}
+	}
+
+	return &localMediaStorage{
+		dir:     mediaConfig.LocalDir,
+		baseURL: mediaConfig.LocalURL,
+	}
+}
+
+// localMediaStorage writes files below a directory that is served as static files, for development and tests
+type localMediaStorage struct {
+	dir     string
+	baseURL string
+}
+
+func (storage *localMediaStorage) Name() string {
+	return MediaStorageLocal
+}
+
+func (storage *localMediaStorage) Put(key string, contentType string, data []byte) (string, error) {
+	filePath := storage.path(key)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		return "", err
+	}
+
+	return storage.baseURL + "/" + key, nil
+}
+
+func (storage *localMediaStorage) Delete(key string) error {
+	if err := os.Remove(storage.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// path keeps every key inside the storage directory
+func (storage *localMediaStorage) path(key string) string {
+	return filepath.Join(storage.dir, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+// cloudinaryMediaStorage uploads files with the signed upload API of Cloudinary, the public id of a
+// file is its key without the extension
+type cloudinaryMediaStorage struct {
+	cloudName  string
+	apiKey     string
+	apiSecret  string
+	httpClient *http.Client
+}
+
+type cloudinaryResponse struct {
+	SecureURL string `json:"secure_url"`
+	Result    string `json:"result"`
+	Error     struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (storage *cloudinaryMediaStorage) Name() string {
+	return MediaStorageCloudinary
+}
+
+func (storage *cloudinaryMediaStorage) Put(key string, contentType string, data []byte) (string, error) {
+	params := storage.signedParams(key)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, values := range params {
+		if err := writer.WriteField(name, values[0]); err != nil {
+			return "", err
+		}
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, path.Base(key)))
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	response, err := storage.post("upload", writer.FormDataContentType(), &body)
+	if err != nil {
+		return "", err
+	}
+
+	return response.SecureURL, nil
+}
+
+func (storage *cloudinaryMediaStorage) Delete(key string) error {
+	response, err := storage.post("destroy", "application/x-www-form-urlencoded", strings.NewReader(storage.signedParams(key).Encode()))
+	if err != nil {
+		return err
+	}
+
+	// Deleting a file that is already gone is not an error
+	if response.Result != "ok" && response.Result != "not found" {
+		return fmt.Errorf("Cloudinary destroy failed: %s", response.Result)
+	}
+
+	return nil
+}
+
+// signedParams returns the parameters of a request on the file with the given key. The signature is
+// the SHA-1 of the sorted parameters followed by the API secret.
+func (storage *cloudinaryMediaStorage) signedParams(key string) url.Values {
+	publicId := strings.TrimSuffix(key, path.Ext(key))
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	signature := sha1.Sum([]byte("public_id=" + publicId + "&timestamp=" + timestamp + storage.apiSecret))
+
+	return url.Values{
+		"public_id": {publicId},
+		"timestamp": {timestamp},
+		"api_key":   {storage.apiKey},
+		"signature": {hex.EncodeToString(signature[:])},
+	}
+}
+
+func (storage *cloudinaryMediaStorage) post(action string, contentType string, body io.Reader) (*cloudinaryResponse, error) {
+	request, err := http.NewRequest(http.MethodPost, cloudinaryAPIURL+storage.cloudName+"/image/"+action, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", contentType)
+
+	response, err := storage.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var cloudinaryResp cloudinaryResponse
+	if err := json.Unmarshal(data, &cloudinaryResp); err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Cloudinary %s failed with status %d: %s", action, response.StatusCode, cloudinaryResp.Error.Message)
+	}
+
+	return &cloudinaryResp, nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalMediaStoragePathConfinement(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "uploads")
+	storage := &localMediaStorage{dir: dir, baseURL: "http://localhost:8080/uploads"}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "products/1/2/large.jpg", want: "products/1/2/large.jpg"},
+		{key: "/products/1/2/small.jpg", want: "products/1/2/small.jpg"},
+		{key: "../outside.jpg", want: "outside.jpg"},
+		{key: "../../../../etc/passwd", want: "etc/passwd"},
+		{key: "products/../../uploads-sibling/a.jpg", want: "uploads-sibling/a.jpg"},
+		{key: "products/./1/../2/thumbnail.png", want: "products/2/thumbnail.png"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			url, err := storage.Put(test.key, "image/jpeg", []byte("data"))
+			if err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+
+			if want := storage.baseURL + "/" + test.key; url != want {
+				t.Errorf("Put() URL = %q, want %q", url, want)
+			}
+
+			want := filepath.Join(dir, filepath.FromSlash(test.want))
+			if data, err := os.ReadFile(want); err != nil || string(data) != "data" {
+				t.Fatalf("file %s = %q, %v, want the stored data", want, data, err)
+			}
+			assertFilesWithin(t, root, dir)
+
+			if err := storage.Delete(test.key); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+
+			if _, err := os.Stat(want); !os.IsNotExist(err) {
+				t.Errorf("file %s still exists after Delete(), error = %v", want, err)
+			}
+		})
+	}
+}
+
+func TestLocalMediaStorageDeleteMissing(t *testing.T) {
+	storage := &localMediaStorage{dir: t.TempDir()}
+
+	if err := storage.Delete("products/1/2/missing.jpg"); err != nil {
+		t.Errorf("Delete() of a missing file error = %v, want nil", err)
+	}
+}
+
+// assertFilesWithin fails the test if a file below root is not inside dir
+func assertFilesWithin(t *testing.T, root string, dir string) {
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() && !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			t.Errorf("file %s was written outside the storage directory", path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
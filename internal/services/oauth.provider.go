@@ -0,0 +1,375 @@
+package services
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/mercan/ecommerce/internal/config"
+)
+
+const (
+	OAuthProviderGoogle = "google"
+	OAuthProviderGitHub = "github"
+)
+
+// OAuthIdentity is the user as described by an identity provider
+type OAuthIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+// OAuthProvider runs the authorization code flow with PKCE against an identity provider
+type OAuthProvider interface {
+	Name() string
+	AuthCodeURL(state string, nonce string, codeChallenge string) string
+	Exchange(code string, codeVerifier string, nonce string) (*OAuthIdentity, error)
+}
+
+// newOAuthProviders returns the providers that have a client id configured
+func newOAuthProviders() map[string]OAuthProvider {
+	oauthConfig := config.GetOAuthConfig()
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	providers := make(map[string]OAuthProvider)
+
+	if oauthConfig.Google.ClientID != "" {
+		providers[OAuthProviderGoogle] = &oidcProvider{
+			name:       OAuthProviderGoogle,
+			config:     oauthConfig.Google,
+			httpClient: httpClient,
+		}
+	}
+
+	if oauthConfig.GitHub.ClientID != "" {
+		providers[OAuthProviderGitHub] = &githubProvider{
+			config:     oauthConfig.GitHub,
+			httpClient: httpClient,
+		}
+	}
+
+	return providers
+}
+
+func oauthRedirectURL(provider string) string {
+	return config.GetServerConfig().BaseURL + "/auth/oauth/" + provider + "/callback"
+}
+
+type oauthTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchangeAuthorizationCode redeems an authorization code at the token endpoint of a provider
+func exchangeAuthorizationCode(httpClient *http.Client, providerConfig config.OAuthProviderConfig,
+	redirectURL string, code string, codeVerifier string) (*oauthTokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {providerConfig.ClientID},
+		"client_secret": {providerConfig.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+
+	request, err := http.NewRequest(http.MethodPost, providerConfig.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	var token oauthTokenResponse
+	if err := doJSONRequest(httpClient, request, &token); err != nil {
+		return nil, err
+	}
+
+	if token.Error != "" {
+		return nil, fmt.Errorf("Identity provider rejected the authorization code: %s", token.Error)
+	}
+
+	if token.AccessToken == "" {
+		return nil, errors.New("Identity provider returned no access token")
+	}
+
+	return &token, nil
+}
+
+func doJSONRequest(httpClient *http.Client, request *http.Request, v interface{}) error {
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	// Token endpoints report errors in the body, which is decoded on 400 as well
+	if response.StatusCode >= 300 && response.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("Identity provider request failed with status %d", response.StatusCode)
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// oidcProvider is an OpenID Connect provider, the user is read from the signed ID token
+type oidcProvider struct {
+	name       string
+	config     config.OAuthProviderConfig
+	httpClient *http.Client
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PublicKey
+}
+
+func (provider *oidcProvider) Name() string {
+	return provider.name
+}
+
+func (provider *oidcProvider) AuthCodeURL(state string, nonce string, codeChallenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.config.ClientID},
+		"redirect_uri":          {oauthRedirectURL(provider.name)},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	return provider.config.AuthURL + "?" + query.Encode()
+}
+
+func (provider *oidcProvider) Exchange(code string, codeVerifier string, nonce string) (*OAuthIdentity, error) {
+	token, err := exchangeAuthorizationCode(provider.httpClient, provider.config, oauthRedirectURL(provider.name), code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.IDToken == "" {
+		return nil, errors.New("Identity provider returned no ID token")
+	}
+
+	claims, err := provider.verifyIDToken(token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("Invalid ID token")
+	}
+
+	email, _ := claims["email"].(string)
+	firstName, _ := claims["given_name"].(string)
+	lastName, _ := claims["family_name"].(string)
+
+	// Some providers send email_verified as a string
+	emailVerified := false
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		emailVerified = v
+	case string:
+		emailVerified = v == "true"
+	}
+
+	return &OAuthIdentity{
+		Subject:       subject,
+		Email:         email,
+		EmailVerified: emailVerified,
+		FirstName:     firstName,
+		LastName:      lastName,
+	}, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func (provider *oidcProvider) verifyIDToken(idToken string, nonce string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		return provider.publicKey(kid)
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("Invalid ID token")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyIssuer(provider.config.Issuer, true) || !claims.VerifyAudience(provider.config.ClientID, true) {
+		return nil, errors.New("Invalid ID token")
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, errors.New("Invalid ID token nonce")
+	}
+
+	return claims, nil
+}
+
+// publicKey returns the signing key with the given id, the key set is fetched again
+// when the id is unknown so that key rotation is picked up
+func (provider *oidcProvider) publicKey(kid string) (*rsa.PublicKey, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+
+	if key, ok := provider.keys[kid]; ok {
+		return key, nil
+	}
+
+	keys, err := provider.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+	provider.keys = keys
+
+	if key, ok := provider.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, errors.New("Unknown ID token signing key")
+}
+
+func (provider *oidcProvider) fetchKeys() (map[string]*rsa.PublicKey, error) {
+	request, err := http.NewRequest(http.MethodGet, provider.config.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := doJSONRequest(provider.httpClient, request, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range jwks.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			continue
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			continue
+		}
+
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+// githubProvider is a plain OAuth2 provider, the user is read from the GitHub API
+type githubProvider struct {
+	config     config.OAuthProviderConfig
+	httpClient *http.Client
+}
+
+func (provider *githubProvider) Name() string {
+	return OAuthProviderGitHub
+}
+
+// AuthCodeURL ignores the nonce, GitHub does not issue ID tokens
+func (provider *githubProvider) AuthCodeURL(state string, nonce string, codeChallenge string) string {
+	query := url.Values{
+		"client_id":             {provider.config.ClientID},
+		"redirect_uri":          {oauthRedirectURL(OAuthProviderGitHub)},
+		"scope":                 {"read:user user:email"},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	return provider.config.AuthURL + "?" + query.Encode()
+}
+
+func (provider *githubProvider) Exchange(code string, codeVerifier string, nonce string) (*OAuthIdentity, error) {
+	token, err := exchangeAuthorizationCode(provider.httpClient, provider.config, oauthRedirectURL(OAuthProviderGitHub), code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := provider.get("/user", token.AccessToken, &user); err != nil {
+		return nil, err
+	}
+
+	if user.ID == 0 {
+		return nil, errors.New("Identity provider returned no user")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := provider.get("/user/emails", token.AccessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &OAuthIdentity{Subject: strconv.FormatInt(user.ID, 10)}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+			break
+		}
+	}
+
+	names := strings.SplitN(strings.TrimSpace(user.Name), " ", 2)
+	identity.FirstName = names[0]
+	if len(names) == 2 {
+		identity.LastName = names[1]
+	}
+
+	return identity, nil
+}
+
+func (provider *githubProvider) get(path string, accessToken string, v interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, provider.config.APIURL+path, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	request.Header.Set("Accept", "application/vnd.github+json")
+
+	return doJSONRequest(provider.httpClient, request, v)
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/mercan/ecommerce/internal/config"
+)
+
+const (
+	stubClientID     = "client-id"
+	stubClientSecret = "client-secret"
+	stubCode         = "authorization-code"
+	stubVerifier     = "code-verifier"
+	stubNonce        = "nonce"
+	stubKeyID        = "key-1"
+)
+
+// stubIdP is an identity provider with a token endpoint, a key set and the GitHub user API. The ID
+// token it issues is built from claims, which the tests change to break a single check.
+type stubIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	keyID  string
+	claims jwt.MapClaims
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &stubIdP{key: key, keyID: stubKeyID}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", idp.token(t))
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		writeStubJSON(w, map[string]interface{}{"id": 42, "name": "Ada Lovelace"})
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		writeStubJSON(w, []map[string]interface{}{
+			{"email": "other@example.com", "primary": false, "verified": true},
+			{"email": "ada@example.com", "primary": true, "verified": true},
+		})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	idp.claims = jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            stubClientID,
+		"sub":            "subject-1",
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"given_name":     "Ada",
+		"family_name":    "Lovelace",
+		"nonce":          stubNonce,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+
+	return idp
+}
+
+func (idp *stubIdP) config() config.OAuthProviderConfig {
+	return config.OAuthProviderConfig{
+		ClientID:     stubClientID,
+		ClientSecret: stubClientSecret,
+		AuthURL:      idp.server.URL + "/authorize",
+		TokenURL:     idp.server.URL + "/token",
+		Issuer:       idp.server.URL,
+		JWKSURL:      idp.server.URL + "/jwks",
+		APIURL:       idp.server.URL,
+	}
+}
+
+// token redeems stubCode for an access token and an ID token, like a provider it checks the
+// client credentials and the PKCE code verifier
+func (idp *stubIdP) token(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+
+		if r.PostForm.Get("code") != stubCode || r.PostForm.Get("code_verifier") != stubVerifier ||
+			r.PostForm.Get("client_id") != stubClientID || r.PostForm.Get("client_secret") != stubClientSecret {
+			w.WriteHeader(http.StatusBadRequest)
+			writeStubJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims)
+		token.Header["kid"] = idp.keyID
+		idToken, err := token.SignedString(idp.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		writeStubJSON(w, map[string]string{"access_token": "access-token", "id_token": idToken})
+	}
+}
+
+func (idp *stubIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	writeStubJSON(w, map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": stubKeyID,
+		"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+	}}})
+}
+
+func writeStubJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(idp *stubIdP)
+		code    string
+		nonce   string
+		wantErr bool
+	}{
+		{name: "valid ID token"},
+		{name: "wrong authorization code", code: "other-code", wantErr: true},
+		{name: "nonce of another flow", nonce: "other-nonce", wantErr: true},
+		{name: "missing nonce", modify: func(idp *stubIdP) { delete(idp.claims, "nonce") }, wantErr: true},
+		{name: "wrong issuer", modify: func(idp *stubIdP) { idp.claims["iss"] = "https://attacker.example.com" }, wantErr: true},
+		{name: "wrong audience", modify: func(idp *stubIdP) { idp.claims["aud"] = "other-client" }, wantErr: true},
+		{name: "expired", modify: func(idp *stubIdP) { idp.claims["exp"] = time.Now().Add(-time.Minute).Unix() }, wantErr: true},
+		{name: "missing subject", modify: func(idp *stubIdP) { delete(idp.claims, "sub") }, wantErr: true},
+		{name: "unknown signing key", modify: func(idp *stubIdP) { idp.keyID = "key-2" }, wantErr: true},
+		{
+			name: "signed with another key",
+			modify: func(idp *stubIdP) {
+				key, err := rsa.GenerateKey(rand.Reader, 2048)
+				if err != nil {
+					t.Fatal(err)
+				}
+				idp.key = key
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			idp := newStubIdP(t)
+			provider := &oidcProvider{
+				name:       OAuthProviderGoogle,
+				config:     idp.config(),
+				httpClient: idp.server.Client(),
+			}
+
+			// The key set is fetched before the test changes the signing key
+			if _, err := provider.publicKey(stubKeyID); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.modify != nil {
+				test.modify(idp)
+			}
+
+			code, nonce := stubCode, stubNonce
+			if test.code != "" {
+				code = test.code
+			}
+			if test.nonce != "" {
+				nonce = test.nonce
+			}
+
+			identity, err := provider.Exchange(code, stubVerifier, nonce)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Exchange() = %+v, want an error", identity)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+
+			want := OAuthIdentity{
+				Subject:       "subject-1",
+				Email:         "ada@example.com",
+				EmailVerified: true,
+				FirstName:     "Ada",
+				LastName:      "Lovelace",
+			}
+			if *identity != want {
+				t.Errorf("Exchange() = %+v, want %+v", *identity, want)
+			}
+		})
+	}
+}
+
+func TestGitHubProviderExchange(t *testing.T) {
+	idp := newStubIdP(t)
+	provider := &githubProvider{
+		config:     idp.config(),
+		httpClient: idp.server.Client(),
+	}
+
+	identity, err := provider.Exchange(stubCode, stubVerifier, "")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+
+	want := OAuthIdentity{
+		Subject:       "42",
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		FirstName:     "Ada",
+		LastName:      "Lovelace",
+	}
+	if *identity != want {
+		t.Errorf("Exchange() = %+v, want %+v", *identity, want)
+	}
+
+	if _, err := provider.Exchange("other-code", stubVerifier, ""); err == nil {
+		t.Error("Exchange() with a wrong authorization code succeeded")
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/repositories/redis"
+	"github.com/mercan/ecommerce/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrOAuthProviderNotFound = errors.New("Unknown identity provider")
+
+type OAuthService interface {
+	StartLogin(provider string) (*OAuthAuthorization, error)
+	StartLink(userId primitive.ObjectID, provider string) (*OAuthAuthorization, error)
+	Callback(provider string, binding string, callback models.UserOAuthCallbackRequest, client models.ClientInfo) (*AuthTokens, error)
+	Unlink(userId primitive.ObjectID, provider string, client models.ClientInfo) error
+}
+
+// OAuthAuthorization starts a flow at an identity provider. Binding has to be stored in the browser
+// that is sent to URL and passed back with the callback, so that a callback can't be replayed in
+// another browser to sign it in or link an identity to its account.
+type OAuthAuthorization struct {
+	URL     string
+	Binding string
+}
+
+type OAuthServiceImpl struct {
+	userRepo      mongodb.UserMongoRepository
+	authRedisRepo redis.AuthenticationRepository
+	TOTPService   TOTPService
+	AuditService  AuditService
+	providers     map[string]OAuthProvider
+}
+
+func NewOAuthService() OAuthService {
+	return &OAuthServiceImpl{
+		userRepo:      mongodb.NewUserMongoRepository(),
+		authRedisRepo: redis.NewAuthenticationRedisRepository(),
+		TOTPService:   NewTOTPService(),
+		AuditService:  NewAuditService(),
+		providers:     newOAuthProviders(),
+	}
+}
+
+// StartLogin returns the authorization to sign in or sign up with a provider
+func (service *OAuthServiceImpl) StartLogin(provider string) (*OAuthAuthorization, error) {
+	return service.authorize(provider, "")
+}
+
+// StartLink returns the authorization to link a provider to a signed in user
+func (service *OAuthServiceImpl) StartLink(userId primitive.ObjectID, provider string) (*OAuthAuthorization, error) {
+	return service.authorize(provider, userId.Hex())
+}
+
+func (service *OAuthServiceImpl) authorize(provider string, userId string) (*OAuthAuthorization, error) {
+	oauthProvider, ok := service.providers[provider]
+	if !ok {
+		return nil, ErrOAuthProviderNotFound
+	}
+
+	state := helpers.GenerateOAuthState()
+	binding := helpers.GenerateOAuthState()
+	oauthState := &models.OAuthState{
+		Provider:     provider,
+		CodeVerifier: helpers.GenerateCodeVerifier(),
+		Nonce:        helpers.GenerateOAuthState(),
+		UserID:       userId,
+		BindingHash:  helpers.HashToken(binding),
+	}
+
+	if err := service.authRedisRepo.SetOAuthState(state, oauthState); err != nil {
+		return nil, err
+	}
+
+	return &OAuthAuthorization{
+		URL:     oauthProvider.AuthCodeURL(state, oauthState.Nonce, helpers.CodeChallengeS256(oauthState.CodeVerifier)),
+		Binding: binding,
+	}, nil
+}
+
+// Callback completes the authorization code flow in the browser that started it, binding is the
+// value stored there. When the flow was started with StartLink the identity is linked and no tokens
+// are returned, otherwise the user is signed in.
+func (service *OAuthServiceImpl) Callback(provider string, binding string, callback models.UserOAuthCallbackRequest, client models.ClientInfo) (tokens *AuthTokens, err error) {
+	action := models.AuditActionOAuthLogin
+	var userId primitive.ObjectID
+	defer func() { service.AuditService.Record(newLoginAuditEvent(action, userId, client, tokens, err)) }()
+
+	if err := validators.ValidateStruct(callback); err != nil {
+		return nil, err
+	}
+
+	oauthProvider, ok := service.providers[provider]
+	if !ok {
+		return nil, ErrOAuthProviderNotFound
+	}
+
+	// The state is consumed even if the provider reports an error so that it cannot be replayed
+	oauthState, err := service.authRedisRepo.ConsumeOAuthState(callback.State)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return nil, errors.New("Invalid or expired OAuth state")
+		}
+
+		return nil, err
+	}
+
+	if oauthState.Provider != provider {
+		return nil, errors.New("Invalid or expired OAuth state")
+	}
+
+	if binding == "" || subtle.ConstantTimeCompare([]byte(helpers.HashToken(binding)), []byte(oauthState.BindingHash)) != 1 {
+		return nil, errors.New("The sign-in was started in another browser")
+	}
+
+	if callback.Error != "" {
+		return nil, errors.New("Authorization failed: " + callback.Error)
+	}
+
+	if callback.Code == "" {
+		return nil, errors.New("Authorization code is required")
+	}
+
+	identity, err := oauthProvider.Exchange(callback.Code, oauthState.CodeVerifier, oauthState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if oauthState.UserID != "" {
+		action = models.AuditActionOAuthLink
+		if userId, err = primitive.ObjectIDFromHex(oauthState.UserID); err != nil {
+			return nil, errors.New("Invalid or expired OAuth state")
+		}
+
+		return nil, service.link(userId, provider, identity)
+	}
+
+	userDoc, err := service.findOrCreateUser(provider, identity)
+	if err != nil {
+		return nil, err
+	}
+	userId = userDoc.ID
+
+	return service.TOTPService.CompleteLogin(userDoc, client)
+}
+
+func (service *OAuthServiceImpl) Unlink(userId primitive.ObjectID, provider string, client models.ClientInfo) (err error) {
+	defer func() { service.AuditService.Record(newAuditEvent(models.AuditActionOAuthUnlink, userId, client, err)) }()
+
+	removed, err := service.userRepo.RemoveLinkedIdentity(userId, provider)
+	if err != nil {
+		return err
+	}
+
+	if !removed {
+		return errors.New("Account is not linked to this provider")
+	}
+
+	return nil
+}
+
+func (service *OAuthServiceImpl) link(userId primitive.ObjectID, provider string, identity *OAuthIdentity) error {
+	linkedUser, err := service.userRepo.GetUserByLinkedIdentity(provider, identity.Subject)
+	if err != nil {
+		return err
+	}
+
+	if linkedUser != nil {
+		if linkedUser.ID == userId {
+			return errors.New("Account is already linked to this provider")
+		}
+
+		return errors.New("This account is already linked to another user")
+	}
+
+	added, err := service.userRepo.AddLinkedIdentity(userId, newLinkedIdentity(provider, identity))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("This account is already linked to another user")
+		}
+
+		return err
+	}
+
+	if !added {
+		return errors.New("Account is already linked to this provider")
+	}
+
+	return nil
+}
+
+// findOrCreateUser returns the user of a linked identity. Unknown identities are linked to the
+// account with the same email, or a new account is created, as long as the provider verified the email.
+func (service *OAuthServiceImpl) findOrCreateUser(provider string, identity *OAuthIdentity) (*models.User, error) {
+	userDoc, err := service.userRepo.GetUserByLinkedIdentity(provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc != nil {
+		return userDoc, nil
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, errors.New("Email address is not verified by the identity provider")
+	}
+
+	userDoc, err = service.userRepo.GetUserByEmail(identity.Email, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc != nil {
+		// Otherwise whoever registered the unverified address could take over the account
+		if !userDoc.EmailVerified {
+			return nil, errors.New("Please verify your email address and link the account from your profile")
+		}
+
+		if err := service.link(userDoc.ID, provider, identity); err != nil {
+			return nil, err
+		}
+
+		return userDoc, nil
+	}
+
+	// The password is random and never shown, it can be set with the forgot password flow
+	hashedPassword, err := helpers.HashPassword(helpers.GenerateForgotPasswordToken())
+	if err != nil {
+		return nil, errors.New("Password hashing failed")
+	}
+
+	user := models.NewUser()
+	user.FirstName = identity.FirstName
+	user.LastName = identity.LastName
+	user.Email = identity.Email
+	user.EmailVerified = true
+	user.Password = hashedPassword
+	user.LinkedIdentities = []models.LinkedIdentity{newLinkedIdentity(provider, identity)}
+
+	if err := service.userRepo.CreateUser(user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("Email already exists")
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func newLinkedIdentity(provider string, identity *OAuthIdentity) models.LinkedIdentity {
+	return models.LinkedIdentity{
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: time.Now(),
+	}
+}
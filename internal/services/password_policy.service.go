@@ -0,0 +1,340 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+const (
+	PasswordRuleMinLength    = "min_length"
+	PasswordRuleMaxLength    = "max_length"
+	PasswordRuleUppercase    = "uppercase"
+	PasswordRuleLowercase    = "lowercase"
+	PasswordRuleDigit        = "digit"
+	PasswordRuleSymbol       = "symbol"
+	PasswordRulePersonalInfo = "personal_info"
+	PasswordRuleHistory      = "history"
+	PasswordRuleBreached     = "breached"
+
+	// Names and email local parts shorter than this are not matched inside passwords
+	minPersonalInfoLength = 3
+	// Lines of the breached password list are a hash and a count, one read usually holds a line
+	breachedListReadSize = 128
+)
+
+// PasswordPolicyError lists every rule that a password does not satisfy
+type PasswordPolicyError struct {
+	Violations []models.PasswordPolicyViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+
+	return "Password does not meet the password policy: " + strings.Join(messages, ", ")
+}
+
+type PasswordPolicyService interface {
+	// Validate checks a new password for the given user and returns a *PasswordPolicyError
+	// when any rule is not satisfied
+	Validate(password string, user *models.User) error
+	// AppendHistory returns the password history after the user's current hash is replaced
+	AppendHistory(user *models.User) []string
+}
+
+type PasswordPolicyServiceImpl struct {
+	passwordConfig  config.PasswordConfig
+	breachedChecker BreachedPasswordChecker
+}
+
+func NewPasswordPolicyService() PasswordPolicyService {
+	return &PasswordPolicyServiceImpl{
+		passwordConfig:  config.GetPasswordConfig(),
+		breachedChecker: getLocalBreachedPasswordChecker(),
+	}
+}
+
+func (service *PasswordPolicyServiceImpl) Validate(password string, user *models.User) error {
+	var violations []models.PasswordPolicyViolation
+	addViolation := func(rule string, message string) {
+		violations = append(violations, models.PasswordPolicyViolation{Rule: rule, Message: message})
+	}
+
+	length := len([]rune(password))
+	if length < service.passwordConfig.MinLength {
+		addViolation(PasswordRuleMinLength, fmt.Sprintf("must be at least %d characters long", service.passwordConfig.MinLength))
+	}
+
+	if service.passwordConfig.MaxLength > 0 && length > service.passwordConfig.MaxLength {
+		addViolation(PasswordRuleMaxLength, fmt.Sprintf("must be at most %d characters long", service.passwordConfig.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if service.passwordConfig.RequireUppercase && !hasUpper {
+		addViolation(PasswordRuleUppercase, "must contain an uppercase letter")
+	}
+
+	if service.passwordConfig.RequireLowercase && !hasLower {
+		addViolation(PasswordRuleLowercase, "must contain a lowercase letter")
+	}
+
+	if service.passwordConfig.RequireDigit && !hasDigit {
+		addViolation(PasswordRuleDigit, "must contain a digit")
+	}
+
+	if service.passwordConfig.RequireSymbol && !hasSymbol {
+		addViolation(PasswordRuleSymbol, "must contain a symbol")
+	}
+
+	if containsPersonalInfo(password, user) {
+		addViolation(PasswordRulePersonalInfo, "must not contain your name or email address")
+	}
+
+	if service.isReused(password, user) {
+		addViolation(PasswordRuleHistory, fmt.Sprintf("must not be one of your last %d passwords", service.passwordConfig.HistorySize))
+	}
+
+	if service.breachedChecker != nil {
+		if breached, err := service.breachedChecker.IsBreached(password); err != nil {
+			log.Println("Error while checking breached passwords: ", err.Error())
+		} else if breached {
+			addViolation(PasswordRuleBreached, "has appeared in a data breach, please choose another password")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+func (service *PasswordPolicyServiceImpl) AppendHistory(user *models.User) []string {
+	historySize := service.passwordConfig.HistorySize
+	if historySize <= 0 || user.Password == "" {
+		return nil
+	}
+
+	history := append(append([]string{}, user.PasswordHistory...), user.Password)
+	if len(history) > historySize {
+		history = history[len(history)-historySize:]
+	}
+
+	return history
+}
+
+// isReused reports whether the password is the current one or one of the remembered ones
+func (service *PasswordPolicyServiceImpl) isReused(password string, user *models.User) bool {
+	if service.passwordConfig.HistorySize <= 0 || user == nil {
+		return false
+	}
+
+	if user.Password != "" && helpers.VerifyPassword(user.Password, password) {
+		return true
+	}
+
+	for _, hashedPassword := range user.PasswordHistory {
+		if helpers.VerifyPassword(hashedPassword, password) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsPersonalInfo(password string, user *models.User) bool {
+	if user == nil {
+		return false
+	}
+
+	password = strings.ToLower(password)
+	localPart, _, _ := strings.Cut(user.Email, "@")
+
+	for _, value := range []string{user.FirstName, user.LastName, localPart} {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if len([]rune(value)) >= minPersonalInfoLength && strings.Contains(password, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BreachedPasswordChecker reports whether a password is known from a data breach
+type BreachedPasswordChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// LocalBreachedPasswordChecker looks passwords up in a file of SHA-1 hex hashes sorted by hash,
+// one per line, like the ordered-by-hash download of Have I Been Pwned. The file is binary
+// searched on disk, so lists of any size are checked without loading them into memory.
+type LocalBreachedPasswordChecker struct {
+	file *os.File
+	size int64
+}
+
+var (
+	localBreachedChecker     *LocalBreachedPasswordChecker
+	localBreachedCheckerOnce sync.Once
+)
+
+// getLocalBreachedPasswordChecker opens the breached password list once. The check is disabled
+// when no list is configured, a configured list that cannot be opened stops the startup.
+func getLocalBreachedPasswordChecker() BreachedPasswordChecker {
+	localBreachedCheckerOnce.Do(func() {
+		path := config.GetPasswordConfig().BreachedListPath
+		if path == "" {
+			log.Println("Breached password check is disabled, PASSWORD_BREACHED_LIST_PATH is not set")
+			return
+		}
+
+		checker, err := OpenLocalBreachedPasswordChecker(path)
+		if err != nil {
+			log.Fatalf("Error while opening the breached password list %s: %v", path, err)
+		}
+
+		localBreachedChecker = checker
+	})
+
+	if localBreachedChecker == nil {
+		return nil
+	}
+
+	return localBreachedChecker
+}
+
+// OpenLocalBreachedPasswordChecker opens a list of uppercase or lowercase SHA-1 hex hashes sorted
+// by hash. The ":count" suffix of the Have I Been Pwned downloads is ignored.
+func OpenLocalBreachedPasswordChecker(path string) (*LocalBreachedPasswordChecker, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if info.IsDir() {
+		file.Close()
+		return nil, errors.New("Breached password list is a directory")
+	}
+
+	return &LocalBreachedPasswordChecker{file: file, size: info.Size()}, nil
+}
+
+func (checker *LocalBreachedPasswordChecker) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// Binary search over byte offsets, the line compared is the first one starting at or after mid
+	low, high := int64(0), checker.size
+	for low < high {
+		mid := low + (high-low)/2
+
+		start, err := checker.lineStart(mid)
+		if err != nil {
+			return false, err
+		}
+
+		if start >= high {
+			high = mid
+			continue
+		}
+
+		line, err := checker.readLine(start)
+		if err != nil {
+			return false, err
+		}
+
+		lineHash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+		switch strings.Compare(strings.ToUpper(lineHash), hash) {
+		case 0:
+			return true, nil
+		case -1:
+			low = start + int64(len(line)) + 1
+		default:
+			high = mid
+		}
+	}
+
+	return false, nil
+}
+
+// lineStart returns the offset of the first line starting at or after offset, or the file size
+func (checker *LocalBreachedPasswordChecker) lineStart(offset int64) (int64, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+
+	buf := make([]byte, breachedListReadSize)
+	for position := offset - 1; position < checker.size; position += int64(len(buf)) {
+		n, err := checker.file.ReadAt(buf, position)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return position + int64(i) + 1, nil
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return checker.size, nil
+}
+
+// readLine returns the line starting at offset without its newline
+func (checker *LocalBreachedPasswordChecker) readLine(offset int64) (string, error) {
+	var line []byte
+	buf := make([]byte, breachedListReadSize)
+	for position := offset; position < checker.size; position += int64(len(buf)) {
+		n, err := checker.file.ReadAt(buf, position)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return string(append(line, buf[:i]...)), nil
+		}
+		line = append(line, buf[:n]...)
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return string(line), nil
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeBreachedList writes the hashes of the passwords sorted by hash with a count, like the
+// ordered-by-hash download of Have I Been Pwned
+func writeBreachedList(t *testing.T, passwords []string) string {
+	hashes := make([]string, len(passwords))
+	for i, password := range passwords {
+		hashes[i] = sha1Hex(password)
+	}
+	sort.Strings(hashes)
+
+	var list strings.Builder
+	for _, hash := range hashes {
+		list.WriteString(hash + ":42\r\n")
+	}
+
+	path := filepath.Join(t.TempDir(), "breached-passwords.txt")
+	if err := os.WriteFile(path, []byte(list.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLocalBreachedPasswordChecker(t *testing.T) {
+	breached := []string{"password", "123456", "qwerty", "letmein", "dragon", "monkey", "111111", "iloveyou"}
+	for i := 0; i < 500; i++ {
+		breached = append(breached, strings.Repeat("x", i%7)+string(rune('a'+i%26))+strings.Repeat("1", i%11))
+	}
+
+	checker, err := OpenLocalBreachedPasswordChecker(writeBreachedList(t, breached))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, password := range breached {
+		if found, err := checker.IsBreached(password); err != nil || !found {
+			t.Errorf("IsBreached(%q) = %v, %v, want true", password, found, err)
+		}
+	}
+
+	for _, password := range []string{"", "correct horse battery staple", "Password", "1234567", "zzzzzzzzzz"} {
+		if found, err := checker.IsBreached(password); err != nil || found {
+			t.Errorf("IsBreached(%q) = %v, %v, want false", password, found, err)
+		}
+	}
+}
+
+func TestLocalBreachedPasswordCheckerEmptyList(t *testing.T) {
+	checker, err := OpenLocalBreachedPasswordChecker(writeBreachedList(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found, err := checker.IsBreached("password"); err != nil || found {
+		t.Errorf("IsBreached() = %v, %v, want false", found, err)
+	}
+}
+
+func TestOpenLocalBreachedPasswordCheckerMissingList(t *testing.T) {
+	if _, err := OpenLocalBreachedPasswordChecker(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("OpenLocalBreachedPasswordChecker() with a missing list succeeded")
+	}
+
+	if _, err := OpenLocalBreachedPasswordChecker(t.TempDir()); err == nil {
+		t.Error("OpenLocalBreachedPasswordChecker() with a directory succeeded")
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	checker, err := OpenLocalBreachedPasswordChecker(writeBreachedList(t, []string{"Breached-Password1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &models.User{FirstName: "Ada", LastName: "Lovelace", Email: "countess@example.com"}
+
+	tests := []struct {
+		name     string
+		config   config.PasswordConfig
+		password string
+		user     *models.User
+		want     []string
+	}{
+		{name: "valid password", password: "Correct-Horse-9", user: user},
+		{name: "too short", password: "Aa1-", want: []string{PasswordRuleMinLength}},
+		{name: "too long", password: "Aa1-" + strings.Repeat("x", 30), want: []string{PasswordRuleMaxLength}},
+		{name: "length counts characters", password: "Ąą1-ççççç", config: config.PasswordConfig{MinLength: 9, MaxLength: 9}},
+		{name: "missing uppercase", password: "correct-horse-9", want: []string{PasswordRuleUppercase}},
+		{name: "missing lowercase", password: "CORRECT-HORSE-9", want: []string{PasswordRuleLowercase}},
+		{name: "missing digit", password: "Correct-Horse", want: []string{PasswordRuleDigit}},
+		{name: "missing symbol", password: "CorrectHorse9", want: []string{PasswordRuleSymbol}},
+		{name: "contains first name", password: "Correct-ADA-9", user: user, want: []string{PasswordRulePersonalInfo}},
+		{name: "contains email local part", password: "Countess-Horse-9", user: user, want: []string{PasswordRulePersonalInfo}},
+		{name: "short names are not matched", password: "Correct-Horse-9", user: &models.User{FirstName: "Or"}},
+		{name: "breached", password: "Breached-Password1", want: []string{PasswordRuleBreached}},
+		{
+			name:     "every violation is reported",
+			password: "ada",
+			user:     user,
+			want:     []string{PasswordRuleMinLength, PasswordRuleUppercase, PasswordRuleDigit, PasswordRuleSymbol, PasswordRulePersonalInfo},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			passwordConfig := test.config
+			if passwordConfig == (config.PasswordConfig{}) {
+				passwordConfig = config.PasswordConfig{
+					MinLength:        10,
+					MaxLength:        32,
+					RequireUppercase: true,
+					RequireLowercase: true,
+					RequireDigit:     true,
+					RequireSymbol:    true,
+				}
+			}
+
+			service := &PasswordPolicyServiceImpl{passwordConfig: passwordConfig, breachedChecker: checker}
+			err := service.Validate(test.password, test.user)
+
+			var got []string
+			if err != nil {
+				policyErr, ok := err.(*PasswordPolicyError)
+				if !ok {
+					t.Fatalf("Validate() error = %v, want a *PasswordPolicyError", err)
+				}
+
+				for _, violation := range policyErr.Violations {
+					got = append(got, violation.Rule)
+				}
+			}
+
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("Validate() violations = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyHistory(t *testing.T) {
+	var hashes []string
+	for _, password := range []string{"First-Password-1", "Second-Password-2", "Third-Password-3"} {
+		hash, err := helpers.HashPassword(password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	user := &models.User{Password: hashes[2], PasswordHistory: hashes[:2]}
+	service := &PasswordPolicyServiceImpl{passwordConfig: config.PasswordConfig{HistorySize: 2}}
+
+	tests := []struct {
+		password string
+		reused   bool
+	}{
+		{password: "First-Password-1", reused: true},
+		{password: "Second-Password-2", reused: true},
+		{password: "Third-Password-3", reused: true},
+		{password: "Fourth-Password-4", reused: false},
+	}
+
+	for _, test := range tests {
+		err := service.Validate(test.password, user)
+		if reused := err != nil; reused != test.reused {
+			t.Errorf("Validate(%q) error = %v, want reused %v", test.password, err, test.reused)
+		}
+	}
+
+	history := service.AppendHistory(user)
+	if len(history) != 2 || history[0] != hashes[1] || history[1] != hashes[2] {
+		t.Errorf("AppendHistory() = %v, want the last two hashes", history)
+	}
+
+	service.passwordConfig.HistorySize = 0
+	if history := service.AppendHistory(user); history != nil {
+		t.Errorf("AppendHistory() without a history = %v, want nil", history)
+	}
+}
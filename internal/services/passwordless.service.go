@@ -0,0 +1,169 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/repositories/redis"
+	"github.com/mercan/ecommerce/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PasswordlessService interface {
+	RequestMagicLink(user models.UserMagicLinkRequest) (bool, error)
+	VerifyMagicLink(user models.UserMagicLinkVerifyRequest, client models.ClientInfo) (*AuthTokens, error)
+	RequestLoginOTP(user models.UserLoginOTPRequest) (bool, error)
+	VerifyLoginOTP(user models.UserLoginOTPVerifyRequest, client models.ClientInfo) (*AuthTokens, error)
+}
+
+type PasswordlessServiceImpl struct {
+	userRepo       mongodb.UserMongoRepository
+	authRedisRepo  redis.AuthenticationRepository
+	LockoutService LockoutService
+	TOTPService    TOTPService
+	AuditService   AuditService
+}
+
+func NewPasswordlessService() PasswordlessService {
+	return &PasswordlessServiceImpl{
+		userRepo:       mongodb.NewUserMongoRepository(),
+		authRedisRepo:  redis.NewAuthenticationRedisRepository(),
+		LockoutService: NewLockoutService(),
+		TOTPService:    NewTOTPService(),
+		AuditService:   NewAuditService(),
+	}
+}
+
+// RequestMagicLink reports whether a magic link should be sent for the given address.
+// Callers must answer identically in both cases so that accounts cannot be enumerated.
+func (service *PasswordlessServiceImpl) RequestMagicLink(user models.UserMagicLinkRequest) (bool, error) {
+	if err := validators.ValidateStruct(user); err != nil {
+		return false, err
+	}
+
+	return service.userRepo.CheckEmailExists(user.Email)
+}
+
+// VerifyMagicLink exchanges a magic link token for a token pair, or for an "mfa pending"
+// token when the user has two-factor authentication enabled
+func (service *PasswordlessServiceImpl) VerifyMagicLink(user models.UserMagicLinkVerifyRequest, client models.ClientInfo) (tokens *AuthTokens, err error) {
+	var userId primitive.ObjectID
+	var email string
+	defer func() {
+		event := newLoginAuditEvent(models.AuditActionMagicLinkLogin, userId, client, tokens, err)
+		event.Email = email
+		service.AuditService.Record(event)
+	}()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return nil, err
+	}
+
+	email, err = service.authRedisRepo.ConsumeMagicLinkToken(user.Token)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return nil, errors.New("Invalid or expired magic link")
+		}
+
+		return nil, err
+	}
+
+	userDoc, err := service.userRepo.GetUserByEmail(email, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc == nil {
+		return nil, errors.New("Invalid or expired magic link")
+	}
+	userId = userDoc.ID
+
+	// Following the link proves ownership of the address
+	if !userDoc.EmailVerified {
+		if err := service.userRepo.UpdateEmailVerificationStatus(userDoc.ID); err != nil {
+			log.Println("Error while updating email verification status: ", err.Error())
+		}
+	}
+
+	return service.TOTPService.CompleteLogin(userDoc, client)
+}
+
+// RequestLoginOTP reports whether a login code should be sent to the given phone number.
+// Only verified phone numbers can be used to log in.
+func (service *PasswordlessServiceImpl) RequestLoginOTP(user models.UserLoginOTPRequest) (bool, error) {
+	if err := validators.ValidateStruct(user); err != nil {
+		return false, err
+	}
+
+	userDoc, err := service.userRepo.GetUserByPhone(user.PhoneNumber)
+	if err != nil {
+		return false, err
+	}
+
+	return userDoc != nil && userDoc.PhoneNumberVerified, nil
+}
+
+// VerifyLoginOTP exchanges a login code for a token pair, or for an "mfa pending" token
+// when the user has two-factor authentication enabled
+func (service *PasswordlessServiceImpl) VerifyLoginOTP(user models.UserLoginOTPVerifyRequest, client models.ClientInfo) (tokens *AuthTokens, err error) {
+	var userId primitive.ObjectID
+	defer func() {
+		service.AuditService.Record(newLoginAuditEvent(models.AuditActionOTPLogin, userId, client, tokens, err))
+	}()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return nil, err
+	}
+
+	hashedCode, err := service.authRedisRepo.GetLoginOTP(user.PhoneNumber)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return nil, errors.New("Invalid or expired login code")
+		}
+
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashedCode), []byte(helpers.HashToken(user.Code))) != 1 {
+		maxReached, err := service.LockoutService.RegisterVerificationFailure("login-otp", user.PhoneNumber)
+		if err != nil {
+			return nil, err
+		}
+
+		if maxReached {
+			if _, err := service.authRedisRepo.DelLoginOTP(user.PhoneNumber); err != nil {
+				log.Println("Error while deleting login code from redis: ", err.Error())
+			}
+
+			return nil, errors.New("Too many attempts, please request a new login code")
+		}
+
+		return nil, errors.New("Invalid or expired login code")
+	}
+
+	// Deleting the code makes it single-use even under concurrent requests
+	deleted, err := service.authRedisRepo.DelLoginOTP(user.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if !deleted {
+		return nil, errors.New("Invalid or expired login code")
+	}
+
+	userDoc, err := service.userRepo.GetUserByPhone(user.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc == nil || !userDoc.PhoneNumberVerified {
+		return nil, errors.New("Invalid or expired login code")
+	}
+	userId = userDoc.ID
+
+	return service.TOTPService.CompleteLogin(userDoc, client)
+}
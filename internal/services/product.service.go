@@ -0,0 +1,413 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/validators"
+)
+
+const (
+	defaultProductLimit int64 = 20
+	// Generated slugs get a random suffix when taken, a few attempts are enough to find a free one
+	maxSlugAttempts = 3
+)
+
+var (
+	ErrProductNotFound = errors.New("Product not found")
+	errSlugTaken       = errors.New("Slug already in use")
+)
+
+// ProductService manages the products of stores. Store owners manage their own products, roles
+// with the products:manage permission manage the products of every store. The public only sees
+// active products.
+type ProductService interface {
+	Create(storeId primitive.ObjectID, product models.ProductCreateRequest) (*models.Product, error)
+	Get(idOrSlug string) (*models.Product, error)
+	List(query models.ProductListRequest) (*ProductPage, error)
+	GetStoreProduct(userId primitive.ObjectID, permissions models.Permissions, productId string) (*models.Product, error)
+	ListStoreProducts(userId primitive.ObjectID, permissions models.Permissions, query models.ProductListRequest) (*ProductPage, error)
+	Update(userId primitive.ObjectID, permissions models.Permissions, productId string, product models.ProductUpdateRequest) (*models.Product, error)
+	Delete(userId primitive.ObjectID, permissions models.Permissions, productId string) error
+}
+
+// ProductPage is a page of products and the total number of matching products
+type ProductPage struct {
+	Products []*models.Product
+	Page     int64
+	Limit    int64
+	Total    int64
+}
+
+type ProductServiceImpl struct {
+	productRepo  mongodb.ProductMongoRepository
+	categoryRepo mongodb.CategoryMongoRepository
+	mediaService MediaService
+}
+
+func NewProductService() ProductService {
+	service := &ProductServiceImpl{
+		productRepo:  mongodb.NewProductMongoRepository(),
+		categoryRepo: mongodb.NewCategoryMongoRepository(),
+	}
+	service.mediaService = newMediaService(service)
+
+	return service
+}
+
+func (service *ProductServiceImpl) Create(storeId primitive.ObjectID, product models.ProductCreateRequest) (*models.Product, error) {
+	if err := validators.ValidateStruct(product); err != nil {
+		return nil, err
+	}
+
+	if product.Slug != "" && helpers.Slugify(product.Slug) != product.Slug {
+		return nil, errors.New("Invalid slug")
+	}
+
+	status := product.Status
+	if status == "" {
+		status = models.ProductStatusDraft
+	}
+
+	images := product.Images
+	if images == nil {
+		images = []string{}
+	}
+
+	categoryIds, err := service.getCategoryIDs(product.CategoryIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	newProduct := &models.Product{
+		ID:          primitive.NewObjectID(),
+		StoreID:     storeId,
+		Title:       product.Title,
+		Slug:        product.Slug,
+		Description: product.Description,
+		Price:       product.Price,
+		Currency:    product.Currency,
+		Images:      images,
+		Attributes:  product.Attributes,
+		CategoryIDs: categoryIds,
+		Status:      status,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	newProduct.BuildSearchTerms()
+
+	// A slug chosen by the store must be free, a generated one is made unique
+	if newProduct.Slug != "" {
+		if err := service.productRepo.CreateProduct(newProduct); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, errSlugTaken
+			}
+
+			return nil, err
+		}
+
+		return newProduct, nil
+	}
+
+	slug := helpers.Slugify(product.Title)
+	if slug == "" {
+		slug = "product"
+	}
+
+	newProduct.Slug = slug
+	for attempt := 1; ; attempt++ {
+		err := service.productRepo.CreateProduct(newProduct)
+		if err == nil {
+			return newProduct, nil
+		}
+
+		if !mongo.IsDuplicateKeyError(err) || attempt == maxSlugAttempts {
+			return nil, err
+		}
+
+		newProduct.Slug = helpers.SlugWithSuffix(slug)
+	}
+}
+
+// Get returns an active product by its id or its slug
+func (service *ProductServiceImpl) Get(idOrSlug string) (*models.Product, error) {
+	var product *models.Product
+	var err error
+
+	if id, idErr := primitive.ObjectIDFromHex(idOrSlug); idErr == nil {
+		product, err = service.productRepo.GetProductByID(id)
+	}
+
+	if err == nil && product == nil {
+		product, err = service.productRepo.GetProductBySlug(idOrSlug)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if product == nil || product.Status != models.ProductStatusActive {
+		return nil, ErrProductNotFound
+	}
+
+	product.ResolveVariantPrices()
+	return product, nil
+}
+
+// List returns a page of active products, optionally of a single store, of a category and its
+// subcategories or with a variant of the given SKU
+func (service *ProductServiceImpl) List(query models.ProductListRequest) (*ProductPage, error) {
+	if err := validators.ValidateStruct(query); err != nil {
+		return nil, err
+	}
+
+	filter := models.ProductFilter{
+		Status: models.ProductStatusActive,
+		SKU:    normalizeSKU(query.SKU),
+	}
+
+	if query.Category != "" {
+		categoryIds, err := categorySubtreeIDs(service.categoryRepo, query.Category)
+		if err != nil {
+			return nil, err
+		}
+		filter.CategoryIDs = categoryIds
+	}
+
+	if query.StoreID != "" {
+		storeId, err := primitive.ObjectIDFromHex(query.StoreID)
+		if err != nil {
+			return nil, err
+		}
+		filter.StoreID = &storeId
+	}
+
+	page, err := service.getProducts(filter, query.Page, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, product := range page.Products {
+		product.ResolveVariantPrices()
+	}
+
+	return page, nil
+}
+
+func (service *ProductServiceImpl) GetStoreProduct(userId primitive.ObjectID, permissions models.Permissions, productId string) (*models.Product, error) {
+	id, err := primitive.ObjectIDFromHex(productId)
+	if err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	product, err := service.productRepo.GetProductByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Products of other stores are reported as missing
+	if product == nil || !canManageProduct(userId, permissions, product) {
+		return nil, ErrProductNotFound
+	}
+
+	return product, nil
+}
+
+// ListStoreProducts returns a page of the products of the user's store in every status. Requests that
+// manage every product list all stores, or the store given in the query.
+func (service *ProductServiceImpl) ListStoreProducts(userId primitive.ObjectID, permissions models.Permissions, query models.ProductListRequest) (*ProductPage, error) {
+	if err := validators.ValidateStruct(query); err != nil {
+		return nil, err
+	}
+
+	filter := models.ProductFilter{
+		StoreID: &userId,
+		Status:  models.ProductStatus(query.Status),
+		SKU:     normalizeSKU(query.SKU),
+	}
+
+	if query.Category != "" {
+		categoryIds, err := categorySubtreeIDs(service.categoryRepo, query.Category)
+		if err != nil {
+			return nil, err
+		}
+		filter.CategoryIDs = categoryIds
+	}
+
+	if permissions.Has(models.PermissionProductsManage) {
+		filter.StoreID = nil
+	}
+
+	if query.StoreID != "" {
+		storeId, err := primitive.ObjectIDFromHex(query.StoreID)
+		if err != nil {
+			return nil, err
+		}
+
+		if storeId != userId && !permissions.Has(models.PermissionProductsManage) {
+			return nil, errors.New("You can only list the products of your own store")
+		}
+		filter.StoreID = &storeId
+	}
+
+	return service.getProducts(filter, query.Page, query.Limit)
+}
+
+func (service *ProductServiceImpl) Update(userId primitive.ObjectID, permissions models.Permissions, productId string, product models.ProductUpdateRequest) (*models.Product, error) {
+	if err := validators.ValidateStruct(product); err != nil {
+		return nil, err
+	}
+
+	existingProduct, err := service.GetStoreProduct(userId, permissions, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	// The slug is kept when the title changes so that links to the product keep working
+	if product.Slug != nil {
+		if helpers.Slugify(*product.Slug) != *product.Slug {
+			return nil, errors.New("Invalid slug")
+		}
+		existingProduct.Slug = *product.Slug
+	}
+
+	if product.Title != nil {
+		existingProduct.Title = *product.Title
+	}
+
+	if product.Description != nil {
+		existingProduct.Description = *product.Description
+	}
+
+	if product.Price != nil {
+		existingProduct.Price = *product.Price
+	}
+
+	if product.Currency != nil {
+		existingProduct.Currency = *product.Currency
+	}
+
+	if product.Images != nil {
+		existingProduct.Images = *product.Images
+		if existingProduct.Images == nil {
+			existingProduct.Images = []string{}
+		}
+	}
+
+	if product.Attributes != nil {
+		existingProduct.Attributes = *product.Attributes
+	}
+
+	if product.CategoryIDs != nil {
+		existingProduct.CategoryIDs, err = service.getCategoryIDs(*product.CategoryIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if product.Status != nil {
+		existingProduct.Status = *product.Status
+	}
+
+	existingProduct.BuildSearchTerms()
+	existingProduct.UpdatedAt = time.Now()
+	updated, err := service.productRepo.UpdateProduct(existingProduct)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errSlugTaken
+		}
+
+		return nil, err
+	}
+
+	if !updated {
+		return nil, ErrProductNotFound
+	}
+
+	return existingProduct, nil
+}
+
+func (service *ProductServiceImpl) Delete(userId primitive.ObjectID, permissions models.Permissions, productId string) error {
+	product, err := service.GetStoreProduct(userId, permissions, productId)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := service.productRepo.DeleteProduct(product.ID)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ErrProductNotFound
+	}
+
+	// The product is gone either way, images that could not be deleted only leave unused files behind
+	if err := service.mediaService.DeleteProductMedia(product.ID); err != nil {
+		log.Printf("Error while deleting the images of product %s: %s", product.ID.Hex(), err.Error())
+	}
+
+	return nil
+}
+
+func (service *ProductServiceImpl) getProducts(filter models.ProductFilter, page int64, limit int64) (*ProductPage, error) {
+	if page == 0 {
+		page = 1
+	}
+
+	if limit == 0 {
+		limit = defaultProductLimit
+	}
+
+	products, total, err := service.productRepo.GetProducts(filter, page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProductPage{
+		Products: products,
+		Page:     page,
+		Limit:    limit,
+		Total:    total,
+	}, nil
+}
+
+// getCategoryIDs parses the ids of the categories a product is assigned to, every category must exist
+func (service *ProductServiceImpl) getCategoryIDs(categoryIds []string) ([]primitive.ObjectID, error) {
+	ids := make([]primitive.ObjectID, 0, len(categoryIds))
+	for _, categoryId := range categoryIds {
+		id, err := primitive.ObjectIDFromHex(categoryId)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	categories, err := service.categoryRepo.GetCategoriesByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(categories) != len(ids) {
+		return nil, ErrCategoryNotFound
+	}
+
+	return ids, nil
+}
+
+// canManageProduct reports whether the user owns the store of the product or may manage every product
+func canManageProduct(userId primitive.ObjectID, permissions models.Permissions, product *models.Product) bool {
+	return product.StoreID == userId || permissions.Has(models.PermissionProductsManage)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+)
+
+const ProductSearchBackendMongoDB = "mongodb"
+
+var errInvalidSearchCursor = errors.New("Invalid cursor")
+
+// Price ranges of the price facet in minor units, the highest range is open ended
+var searchPriceBoundaries = []int64{0, 1000, 5000, 10000, 25000, 50000, 100000, math.MaxInt64}
+
+// ProductSearchBackend runs product searches. Backends return cursors in their own format and only
+// have to accept the cursors they returned themselves.
+type ProductSearchBackend interface {
+	Name() string
+	Search(query models.ProductSearchQuery) (*models.ProductSearchResult, error)
+}
+
+// newProductSearchBackend returns the configured backend, the products collection is searched when the
+// backend is unknown
+func newProductSearchBackend(searchConfig config.SearchConfig) ProductSearchBackend {
+	if searchConfig.Backend != ProductSearchBackendMongoDB {
+		log.Printf("Unknown search backend %s, falling back to %s", searchConfig.Backend, ProductSearchBackendMongoDB)
+	}
+
+	return &mongoProductSearchBackend{
+		productRepo: mongodb.NewProductMongoRepository(),
+	}
+}
+
+// mongoProductSearchBackend searches the products collection with its text index. Cursors hold the
+// sort value and id of the last product of a page.
+type mongoProductSearchBackend struct {
+	productRepo mongodb.ProductMongoRepository
+}
+
+type mongoSearchCursor struct {
+	Sort      models.ProductSearchSort `bson:"s"`
+	SortValue interface{}              `bson:"v"`
+	ID        primitive.ObjectID       `bson:"i"`
+}
+
+// decodedMongoSearchCursor reads the sort value raw, clients control the cursor and its value must
+// not reach the query unless it has the type of the sort
+type decodedMongoSearchCursor struct {
+	Sort      models.ProductSearchSort `bson:"s"`
+	SortValue bson.RawValue            `bson:"v"`
+	ID        primitive.ObjectID       `bson:"i"`
+}
+
+func (backend *mongoProductSearchBackend) Name() string {
+	return ProductSearchBackendMongoDB
+}
+
+func (backend *mongoProductSearchBackend) Search(query models.ProductSearchQuery) (*models.ProductSearchResult, error) {
+	var after *models.ProductSearchAfter
+	if query.Cursor != "" {
+		var err error
+		if after, err = decodeMongoSearchCursor(query.Cursor, query.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	// One more product than requested tells whether there is a next page
+	limit := query.Limit
+	query.Limit++
+
+	hits, facets, err := backend.productRepo.SearchProducts(query, after, searchPriceBoundaries)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ProductSearchResult{
+		Products: make([]*models.Product, 0, len(hits)),
+		Facets:   facets,
+	}
+
+	if int64(len(hits)) > limit {
+		hits = hits[:limit]
+		last := hits[len(hits)-1]
+		if result.NextCursor, err = encodeMongoSearchCursor(mongoSearchCursor{
+			Sort:      query.Sort,
+			SortValue: last.SortValue,
+			ID:        last.ID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, hit := range hits {
+		product := hit.Product
+		result.Products = append(result.Products, &product)
+	}
+
+	return result, nil
+}
+
+func encodeMongoSearchCursor(cursor mongoSearchCursor) (string, error) {
+	data, err := bson.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeMongoSearchCursor rejects cursors of a search with another sort order, their sort values can not be compared
+func decodeMongoSearchCursor(cursor string, sort models.ProductSearchSort) (*models.ProductSearchAfter, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidSearchCursor
+	}
+
+	var searchCursor decodedMongoSearchCursor
+	if err := bson.Unmarshal(data, &searchCursor); err != nil || searchCursor.Sort != sort {
+		return nil, errInvalidSearchCursor
+	}
+
+	sortValue, err := mongoSearchSortValue(sort, searchCursor.SortValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ProductSearchAfter{
+		SortValue: sortValue,
+		ID:        searchCursor.ID,
+	}, nil
+}
+
+// mongoSearchSortValue returns the sort value of a cursor as the type the sort produces, the newest
+// sort compares dates, price sorts integers and the others numbers
+func mongoSearchSortValue(sort models.ProductSearchSort, value bson.RawValue) (interface{}, error) {
+	switch sort {
+	case models.ProductSearchSortNewest:
+		if dateTime, ok := value.DateTimeOK(); ok {
+			return primitive.DateTime(dateTime), nil
+		}
+	case models.ProductSearchSortPriceAsc, models.ProductSearchSortPriceDesc:
+		if price, ok := value.Int64OK(); ok {
+			return price, nil
+		}
+		if price, ok := value.Int32OK(); ok {
+			return int64(price), nil
+		}
+	default:
+		if number, ok := value.DoubleOK(); ok && !math.IsNaN(number) {
+			return number, nil
+		}
+		if number, ok := value.Int32OK(); ok {
+			return float64(number), nil
+		}
+		if number, ok := value.Int64OK(); ok {
+			return float64(number), nil
+		}
+	}
+
+	return nil, errInvalidSearchCursor
+}
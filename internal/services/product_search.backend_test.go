@@ -0,0 +1,99 @@
+package services
+
+import (
+	"encoding/base64"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/models"
+)
+
+func TestMongoSearchCursor(t *testing.T) {
+	id := primitive.NewObjectID()
+	createdAt := primitive.NewDateTimeFromTime(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+
+	// Sort values come back from the aggregation in the type MongoDB stored them
+	tests := []struct {
+		name      string
+		sort      models.ProductSearchSort
+		sortValue interface{}
+		want      interface{}
+	}{
+		{name: "relevance", sort: models.ProductSearchSortRelevance, sortValue: 1.75, want: 1.75},
+		{name: "rating", sort: models.ProductSearchSortRating, sortValue: 4.5, want: 4.5},
+		{name: "rating without ratings", sort: models.ProductSearchSortRating, sortValue: int32(0), want: float64(0)},
+		{name: "rating as int64", sort: models.ProductSearchSortRating, sortValue: int64(4), want: float64(4)},
+		{name: "price ascending", sort: models.ProductSearchSortPriceAsc, sortValue: int64(129900), want: int64(129900)},
+		{name: "price descending as int32", sort: models.ProductSearchSortPriceDesc, sortValue: int32(999), want: int64(999)},
+		{name: "newest", sort: models.ProductSearchSortNewest, sortValue: createdAt, want: createdAt},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor, err := encodeMongoSearchCursor(mongoSearchCursor{Sort: test.sort, SortValue: test.sortValue, ID: id})
+			if err != nil {
+				t.Fatalf("encodeMongoSearchCursor() error = %v", err)
+			}
+
+			after, err := decodeMongoSearchCursor(cursor, test.sort)
+			if err != nil {
+				t.Fatalf("decodeMongoSearchCursor() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(after.SortValue, test.want) {
+				t.Errorf("decodeMongoSearchCursor() sort value = %#v, want %#v", after.SortValue, test.want)
+			}
+
+			if after.ID != id {
+				t.Errorf("decodeMongoSearchCursor() id = %s, want %s", after.ID.Hex(), id.Hex())
+			}
+		})
+	}
+}
+
+func TestMongoSearchCursorInvalid(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	encode := func(cursor interface{}) string {
+		data, err := bson.Marshal(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	cursorOf := func(sort models.ProductSearchSort, sortValue interface{}) string {
+		return encode(mongoSearchCursor{Sort: sort, SortValue: sortValue, ID: id})
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+		sort   models.ProductSearchSort
+	}{
+		{name: "not base64", cursor: "not a cursor!", sort: models.ProductSearchSortRelevance},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte("cursor1")), sort: models.ProductSearchSortRelevance},
+		{name: "not a document", cursor: base64.RawURLEncoding.EncodeToString([]byte("cursor")), sort: models.ProductSearchSortRelevance},
+		{name: "other sort", cursor: cursorOf(models.ProductSearchSortRelevance, 1.5), sort: models.ProductSearchSortRating},
+		{name: "missing sort value", cursor: encode(bson.M{"s": models.ProductSearchSortRelevance, "i": id}), sort: models.ProductSearchSortRelevance},
+		{name: "query operator as sort value", cursor: cursorOf(models.ProductSearchSortPriceAsc, bson.M{"$gt": int64(0)}), sort: models.ProductSearchSortPriceAsc},
+		{name: "string sort value", cursor: cursorOf(models.ProductSearchSortRelevance, "1.5"), sort: models.ProductSearchSortRelevance},
+		{name: "NaN sort value", cursor: cursorOf(models.ProductSearchSortRating, math.NaN()), sort: models.ProductSearchSortRating},
+		{name: "price as a double", cursor: cursorOf(models.ProductSearchSortPriceAsc, 999.5), sort: models.ProductSearchSortPriceAsc},
+		{name: "newest as a number", cursor: cursorOf(models.ProductSearchSortNewest, int64(1714566600000)), sort: models.ProductSearchSortNewest},
+		{name: "invalid id", cursor: encode(bson.M{"s": models.ProductSearchSortRelevance, "v": 1.5, "i": "id"}), sort: models.ProductSearchSortRelevance},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if after, err := decodeMongoSearchCursor(test.cursor, test.sort); err == nil {
+				t.Errorf("decodeMongoSearchCursor(%q) = %+v, want an error", test.cursor, after)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/validators"
+)
+
+// ProductSearchService searches the active products of the catalog with the configured search backend
+type ProductSearchService interface {
+	Search(request models.ProductSearchRequest) (*models.ProductSearchResult, error)
+}
+
+type ProductSearchServiceImpl struct {
+	backend      ProductSearchBackend
+	categoryRepo mongodb.CategoryMongoRepository
+}
+
+func NewProductSearchService() ProductSearchService {
+	return &ProductSearchServiceImpl{
+		backend:      newProductSearchBackend(config.GetSearchConfig()),
+		categoryRepo: mongodb.NewCategoryMongoRepository(),
+	}
+}
+
+// Search returns a page of matching products. Searches with keywords are sorted by relevance and
+// other searches by the newest products unless another sort order is requested. Facets are counted
+// for the first page only, the following pages have the same facets.
+func (service *ProductSearchServiceImpl) Search(request models.ProductSearchRequest) (*models.ProductSearchResult, error) {
+	if err := validators.ValidateStruct(request); err != nil {
+		return nil, err
+	}
+
+	if request.MinPrice != nil && request.MaxPrice != nil && *request.MinPrice > *request.MaxPrice {
+		return nil, errors.New("The minimum price can not be higher than the maximum price")
+	}
+
+	query := models.ProductSearchQuery{
+		Text:       strings.TrimSpace(request.Query),
+		MinPrice:   request.MinPrice,
+		MaxPrice:   request.MaxPrice,
+		Brand:      request.Brand,
+		Sort:       models.ProductSearchSort(request.Sort),
+		Cursor:     request.Cursor,
+		Limit:      request.Limit,
+		WithFacets: request.Cursor == "",
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultProductLimit
+	}
+
+	// Relevance needs keywords to be ranked by
+	if query.Sort == "" || (query.Sort == models.ProductSearchSortRelevance && query.Text == "") {
+		query.Sort = models.ProductSearchSortNewest
+		if query.Text != "" {
+			query.Sort = models.ProductSearchSortRelevance
+		}
+	}
+
+	if request.Category != "" {
+		categoryIds, err := categorySubtreeIDs(service.categoryRepo, request.Category)
+		if err != nil {
+			return nil, err
+		}
+		query.CategoryIDs = categoryIds
+	}
+
+	if request.Attributes != "" {
+		attributes, err := parseAttributeFilters(request.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		query.Attributes = attributes
+	}
+
+	result, err := service.backend.Search(query)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, product := range result.Products {
+		product.ResolveVariantPrices()
+	}
+
+	if result.Facets != nil {
+		service.labelCategoryFacets(result.Facets)
+	}
+
+	return result, nil
+}
+
+// labelCategoryFacets adds the names of the categories to the category facet
+func (service *ProductSearchServiceImpl) labelCategoryFacets(facets *models.ProductSearchFacets) {
+	ids := make([]primitive.ObjectID, 0, len(facets.Categories))
+	for _, facet := range facets.Categories {
+		if id, err := primitive.ObjectIDFromHex(facet.Value); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+
+	categories, err := service.categoryRepo.GetCategoriesByIDs(ids)
+	if err != nil {
+		log.Println("Error while labeling category facets: ", err.Error())
+		return
+	}
+
+	names := make(map[string]string, len(categories))
+	for _, category := range categories {
+		names[category.ID.Hex()] = category.Name
+	}
+
+	for i := range facets.Categories {
+		facets.Categories[i].Label = names[facets.Categories[i].Value]
+	}
+}
+
+// parseAttributeFilters parses a comma separated list of key:value pairs. Keys become part of a field
+// path and can not contain dots or start with a dollar sign.
+func parseAttributeFilters(filters string) (map[string]string, error) {
+	attributes := map[string]string{}
+	for _, filter := range strings.Split(filters, ",") {
+		key, value, ok := strings.Cut(filter, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.Contains(key, ".") || strings.HasPrefix(key, "$") {
+			return nil, errors.New("Invalid attribute filter")
+		}
+
+		attributes[key] = strings.TrimSpace(value)
+	}
+
+	return attributes, nil
+}
@@ -0,0 +1,416 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/validators"
+)
+
+const maxProductVariants = 100
+
+var (
+	ErrProductVariantNotFound = errors.New("Product variant not found")
+	errSKUTaken               = errors.New("SKU already in use")
+	errInvalidSKU             = errors.New("Invalid SKU")
+	errProductVariantsChanged = errors.New("The variants of the product changed, please try again")
+)
+
+// ProductVariantService manages the options and variants of products. Options define the dimensions
+// a product is sold in, every variant picks one value of each option and has its own SKU and stock.
+type ProductVariantService interface {
+	SetOptions(userId primitive.ObjectID, permissions models.Permissions, productId string, options models.ProductOptionsRequest) (*models.Product, error)
+	CreateVariant(userId primitive.ObjectID, permissions models.Permissions, productId string, variant models.ProductVariantCreateRequest) (*models.ProductVariant, error)
+	GenerateVariants(userId primitive.ObjectID, permissions models.Permissions, productId string, request models.ProductVariantGenerateRequest) (*models.Product, error)
+	UpdateVariant(userId primitive.ObjectID, permissions models.Permissions, productId string, variantId string, variant models.ProductVariantUpdateRequest) (*models.ProductVariant, error)
+	DeleteVariant(userId primitive.ObjectID, permissions models.Permissions, productId string, variantId string) error
+	GetVariant(productIdOrSlug string, variantIdOrSKU string) (*models.ProductVariant, error)
+}
+
+type ProductVariantServiceImpl struct {
+	productRepo    mongodb.ProductMongoRepository
+	productService ProductService
+}
+
+func NewProductVariantService() ProductVariantService {
+	return &ProductVariantServiceImpl{
+		productRepo:    mongodb.NewProductMongoRepository(),
+		productService: NewProductService(),
+	}
+}
+
+// SetOptions replaces the options of a product. Options that existing variants depend on can not be
+// removed, the variants have to be changed or deleted first.
+func (service *ProductVariantServiceImpl) SetOptions(userId primitive.ObjectID, permissions models.Permissions, productId string, options models.ProductOptionsRequest) (*models.Product, error) {
+	if err := validators.ValidateStruct(options); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(options.Options))
+	for _, option := range options.Options {
+		if names[option.Name] {
+			return nil, errors.New("Option names must be unique")
+		}
+		names[option.Name] = true
+	}
+
+	product, err := service.productService.GetStoreProduct(userId, permissions, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, variant := range product.Variants {
+		if err := validateVariantOptions(options.Options, variant.Options); err != nil {
+			return nil, errors.New("Variant " + variant.SKU + " does not fit the options: " + err.Error())
+		}
+	}
+
+	product.Options = options.Options
+	product.UpdatedAt = time.Now()
+	updated, err := service.productRepo.SetProductOptions(product.ID, product.Options, product.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if !updated {
+		return nil, ErrProductNotFound
+	}
+
+	return product, nil
+}
+
+func (service *ProductVariantServiceImpl) CreateVariant(userId primitive.ObjectID, permissions models.Permissions, productId string, variant models.ProductVariantCreateRequest) (*models.ProductVariant, error) {
+	if err := validators.ValidateStruct(variant); err != nil {
+		return nil, err
+	}
+
+	product, err := service.productService.GetStoreProduct(userId, permissions, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(product.Variants) >= maxProductVariants {
+		return nil, errors.New("A product can have at most 100 variants")
+	}
+
+	if err := validateVariantOptions(product.Options, variant.Options); err != nil {
+		return nil, err
+	}
+
+	sku := normalizeSKU(variant.SKU)
+	if sku == "" {
+		return nil, errInvalidSKU
+	}
+
+	for _, existingVariant := range product.Variants {
+		if existingVariant.SKU == sku {
+			return nil, errSKUTaken
+		}
+
+		if variantOptionsKey(product.Options, existingVariant.Options) == variantOptionsKey(product.Options, variant.Options) {
+			return nil, errors.New("A variant with these options already exists")
+		}
+	}
+
+	now := time.Now()
+	newVariant := models.ProductVariant{
+		ID:        primitive.NewObjectID(),
+		SKU:       sku,
+		Barcode:   strings.TrimSpace(variant.Barcode),
+		Options:   variant.Options,
+		Price:     variant.Price,
+		Weight:    variant.Weight,
+		Stock:     variant.Stock,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := service.addVariants(product.ID, []models.ProductVariant{newVariant}); err != nil {
+		return nil, err
+	}
+
+	return &newVariant, nil
+}
+
+// GenerateVariants creates a variant for every combination of option values that has no variant yet.
+// The SKU of a generated variant is the prefix followed by its option values, e.g. SHIRT-M-RED.
+func (service *ProductVariantServiceImpl) GenerateVariants(userId primitive.ObjectID, permissions models.Permissions, productId string, request models.ProductVariantGenerateRequest) (*models.Product, error) {
+	if err := validators.ValidateStruct(request); err != nil {
+		return nil, err
+	}
+
+	product, err := service.productService.GetStoreProduct(userId, permissions, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(product.Options) == 0 {
+		return nil, errors.New("The product has no options to generate variants from")
+	}
+
+	combinations := 1
+	for _, option := range product.Options {
+		combinations *= len(option.Values)
+	}
+
+	if combinations > maxProductVariants {
+		return nil, errors.New("A product can have at most 100 variants")
+	}
+
+	existingKeys := make(map[string]bool, len(product.Variants))
+	skus := make(map[string]bool, len(product.Variants))
+	for _, variant := range product.Variants {
+		existingKeys[variantOptionsKey(product.Options, variant.Options)] = true
+		skus[variant.SKU] = true
+	}
+
+	now := time.Now()
+	prefix := normalizeSKU(request.SKUPrefix)
+	if prefix == "" {
+		return nil, errInvalidSKU
+	}
+
+	newVariants := []models.ProductVariant{}
+	for _, combination := range optionCombinations(product.Options) {
+		if existingKeys[variantOptionsKey(product.Options, combination)] {
+			continue
+		}
+
+		sku := prefix
+		for _, option := range product.Options {
+			sku += "-" + strings.ToUpper(helpers.Slugify(combination[option.Name]))
+		}
+
+		if skus[sku] {
+			return nil, errors.New("Generated SKU " + sku + " is already in use")
+		}
+		skus[sku] = true
+
+		newVariants = append(newVariants, models.ProductVariant{
+			ID:        primitive.NewObjectID(),
+			SKU:       sku,
+			Options:   combination,
+			Stock:     request.Stock,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+
+	if len(product.Variants)+len(newVariants) > maxProductVariants {
+		return nil, errors.New("A product can have at most 100 variants")
+	}
+
+	if len(newVariants) == 0 {
+		return product, nil
+	}
+
+	if err := service.addVariants(product.ID, newVariants); err != nil {
+		return nil, err
+	}
+
+	product.Variants = append(product.Variants, newVariants...)
+	product.UpdatedAt = now
+	return product, nil
+}
+
+func (service *ProductVariantServiceImpl) UpdateVariant(userId primitive.ObjectID, permissions models.Permissions, productId string, variantId string, variant models.ProductVariantUpdateRequest) (*models.ProductVariant, error) {
+	if err := validators.ValidateStruct(variant); err != nil {
+		return nil, err
+	}
+
+	product, err := service.productService.GetStoreProduct(userId, permissions, productId)
+	if err != nil {
+		return nil, err
+	}
+
+	existingVariant := product.GetVariant(variantId)
+	if existingVariant == nil {
+		return nil, ErrProductVariantNotFound
+	}
+
+	if variant.SKU != nil {
+		existingVariant.SKU = normalizeSKU(*variant.SKU)
+		if existingVariant.SKU == "" {
+			return nil, errInvalidSKU
+		}
+	}
+
+	if variant.Barcode != nil {
+		existingVariant.Barcode = strings.TrimSpace(*variant.Barcode)
+	}
+
+	if variant.Options != nil {
+		if err := validateVariantOptions(product.Options, *variant.Options); err != nil {
+			return nil, err
+		}
+		existingVariant.Options = *variant.Options
+	}
+
+	if variant.ClearPrice {
+		existingVariant.Price = nil
+	} else if variant.Price != nil {
+		existingVariant.Price = variant.Price
+	}
+
+	if variant.Weight != nil {
+		existingVariant.Weight = *variant.Weight
+	}
+
+	if variant.Stock != nil {
+		existingVariant.Stock = *variant.Stock
+	}
+
+	key := variantOptionsKey(product.Options, existingVariant.Options)
+	for _, otherVariant := range product.Variants {
+		if otherVariant.ID == existingVariant.ID {
+			continue
+		}
+
+		if otherVariant.SKU == existingVariant.SKU {
+			return nil, errSKUTaken
+		}
+
+		if variantOptionsKey(product.Options, otherVariant.Options) == key {
+			return nil, errors.New("A variant with these options already exists")
+		}
+	}
+
+	existingVariant.UpdatedAt = time.Now()
+	updated, err := service.productRepo.UpdateProductVariant(product.ID, existingVariant)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errSKUTaken
+		}
+
+		return nil, err
+	}
+
+	if !updated {
+		return nil, errProductVariantsChanged
+	}
+
+	return existingVariant, nil
+}
+
+func (service *ProductVariantServiceImpl) DeleteVariant(userId primitive.ObjectID, permissions models.Permissions, productId string, variantId string) error {
+	product, err := service.productService.GetStoreProduct(userId, permissions, productId)
+	if err != nil {
+		return err
+	}
+
+	variant := product.GetVariant(variantId)
+	if variant == nil {
+		return ErrProductVariantNotFound
+	}
+
+	removed, err := service.productRepo.RemoveProductVariant(product.ID, variant.ID)
+	if err != nil {
+		return err
+	}
+
+	if !removed {
+		return ErrProductVariantNotFound
+	}
+
+	return nil
+}
+
+// GetVariant returns a variant of an active product by its id or SKU, its price is resolved
+func (service *ProductVariantServiceImpl) GetVariant(productIdOrSlug string, variantIdOrSKU string) (*models.ProductVariant, error) {
+	product, err := service.productService.Get(productIdOrSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	variant := product.GetVariant(variantIdOrSKU)
+	if variant == nil {
+		variant = product.GetVariant(normalizeSKU(variantIdOrSKU))
+	}
+
+	if variant == nil {
+		return nil, ErrProductVariantNotFound
+	}
+
+	return variant, nil
+}
+
+func (service *ProductVariantServiceImpl) addVariants(productId primitive.ObjectID, variants []models.ProductVariant) error {
+	added, err := service.productRepo.AddProductVariants(productId, variants, maxProductVariants)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errSKUTaken
+		}
+
+		return err
+	}
+
+	// The product was checked before, it was changed or deleted since then
+	if !added {
+		return errProductVariantsChanged
+	}
+
+	return nil
+}
+
+// validateVariantOptions checks that the variant has exactly one valid value for every option of the product
+func validateVariantOptions(options []models.ProductOption, variantOptions map[string]string) error {
+	if len(variantOptions) != len(options) {
+		return errors.New("A variant must have a value for every option of the product")
+	}
+
+	for _, option := range options {
+		value, ok := variantOptions[option.Name]
+		if !ok {
+			return errors.New("Missing value for option " + option.Name)
+		}
+
+		if !option.HasValue(value) {
+			return errors.New("Invalid value for option " + option.Name)
+		}
+	}
+
+	return nil
+}
+
+// variantOptionsKey identifies the combination of option values of a variant
+func variantOptionsKey(options []models.ProductOption, variantOptions map[string]string) string {
+	values := make([]string, 0, len(options))
+	for _, option := range options {
+		values = append(values, variantOptions[option.Name])
+	}
+
+	return strings.Join(values, "\x00")
+}
+
+// optionCombinations returns every combination of option values in the order of the options
+func optionCombinations(options []models.ProductOption) []map[string]string {
+	combinations := []map[string]string{{}}
+	for _, option := range options {
+		next := make([]map[string]string, 0, len(combinations)*len(option.Values))
+		for _, combination := range combinations {
+			for _, value := range option.Values {
+				extended := make(map[string]string, len(combination)+1)
+				for name, existingValue := range combination {
+					extended[name] = existingValue
+				}
+				extended[option.Name] = value
+				next = append(next, extended)
+			}
+		}
+		combinations = next
+	}
+
+	return combinations
+}
+
+// normalizeSKU makes SKUs case insensitive so that "abc-1" and "ABC-1" can not both exist
+func normalizeSKU(sku string) string {
+	return strings.ToUpper(strings.TrimSpace(sku))
+}
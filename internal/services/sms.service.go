@@ -1,6 +1,9 @@
 package services
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/mercan/ecommerce/internal/config"
 	"github.com/mercan/ecommerce/internal/helpers"
 	"github.com/mercan/ecommerce/internal/repositories/redis"
@@ -10,6 +13,7 @@ import (
 
 type SMSService interface {
 	SendVerificationPhone(phoneNumber string) error
+	SendLoginCode(phoneNumber string) error
 }
 
 type SMSServiceImpl struct {
@@ -51,3 +55,39 @@ func (service *SMSServiceImpl) SendVerificationPhone(phoneNumber string) error {
 
 	return nil
 }
+
+func (service *SMSServiceImpl) SendLoginCode(phoneNumber string) error {
+	params := &openapi.CreateMessageParams{}
+	loginCode := helpers.GenerateVerificationCode()
+	expiresIn := formatExpiration(config.GetTimeConfig().LoginOTPExpireTime * time.Second)
+	message := "Your login code is: " + loginCode + "\nExpires in " + expiresIn + ". Never share this code.\n\nEcommerce Demo API"
+
+	if err := service.authRedisRepo.SetLoginOTP(phoneNumber, loginCode); err != nil {
+		return err
+	}
+
+	params.SetBody(message)
+	params.SetFrom(service.twilioFromNumber)
+	params.SetTo(phoneNumber)
+
+	if _, err := service.twilioClient.Api.CreateMessage(params); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// formatExpiration writes an expiration in whole minutes, rounded down so that the message never
+// promises more time than the code is valid for
+func formatExpiration(expiration time.Duration) string {
+	if expiration < time.Minute {
+		return strconv.Itoa(int(expiration/time.Second)) + " seconds"
+	}
+
+	minutes := int(expiration / time.Minute)
+	if minutes == 1 {
+		return "1 minute"
+	}
+
+	return strconv.Itoa(minutes) + " minutes"
+}
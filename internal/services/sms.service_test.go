@@ -0,0 +1,25 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatExpiration(t *testing.T) {
+	tests := []struct {
+		expiration time.Duration
+		want       string
+	}{
+		{expiration: 30 * time.Second, want: "30 seconds"},
+		{expiration: time.Minute, want: "1 minute"},
+		{expiration: 90 * time.Second, want: "1 minute"},
+		{expiration: 5 * time.Minute, want: "5 minutes"},
+		{expiration: 2 * time.Hour, want: "120 minutes"},
+	}
+
+	for _, test := range tests {
+		if got := formatExpiration(test.expiration); got != test.want {
+			t.Errorf("formatExpiration(%s) = %q, want %q", test.expiration, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/repositories/redis"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sessionTouchInterval limits how often a session's last seen time is written
+const sessionTouchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("Session not found")
+
+// ErrPasswordResetRequired rejects sign-ins of a user who reported a sign-in as not made by them
+var ErrPasswordResetRequired = errors.New("Your password has to be reset, please check your email for a reset link")
+
+// AuthTokens is the result of a successful authentication. When a second factor is
+// still required only MFAToken is set. NewDevice is set when the session was started
+// from a device or network the user never signed in from. MFAMethods lists the second
+// factors that can be used with the MFA token, "totp" and "webauthn".
+type AuthTokens struct {
+	AccessToken  string
+	RefreshToken string
+	MFAToken     string
+	MFAMethods   []string
+	NewDevice    *NewDeviceSignIn
+}
+
+// NewDeviceSignIn is a sign-in from an unknown device, the account owner has to be told
+// with a link to report it
+type NewDeviceSignIn struct {
+	Email      string
+	Device     string
+	IP         string
+	SignedInAt time.Time
+	ReportLink string
+}
+
+type TokenService interface {
+	IssueTokens(user *models.User, client models.ClientInfo) (*AuthTokens, error)
+	RefreshTokens(refreshToken string, client models.ClientInfo) (*AuthTokens, error)
+	ValidateSession(userId primitive.ObjectID, sessionId string, client models.ClientInfo) error
+	GetSessions(userId primitive.ObjectID) ([]*models.Session, error)
+	RevokeSession(sessionId string) error
+	RevokeUserSession(userId primitive.ObjectID, sessionId string) error
+	RevokeAllSessions(userId primitive.ObjectID) error
+}
+
+type TokenServiceImpl struct {
+	userRepo      mongodb.UserMongoRepository
+	authRedisRepo redis.AuthenticationRepository
+}
+
+func NewTokenService() TokenService {
+	return &TokenServiceImpl{
+		userRepo:      mongodb.NewUserMongoRepository(),
+		authRedisRepo: redis.NewAuthenticationRedisRepository(),
+	}
+}
+
+// IssueTokens starts a new session and returns its first token pair. Signing in reactivates
+// a deactivated account and cancels its scheduled deletion. Every sign-in method ends here, so
+// none of them works while a password reset is required.
+func (service *TokenServiceImpl) IssueTokens(user *models.User, client models.ClientInfo) (*AuthTokens, error) {
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
+	}
+
+	if !user.IsActive {
+		reactivated, err := service.userRepo.Reactivate(user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !reactivated {
+			return nil, errors.New("Account has been deleted")
+		}
+		user.IsActive = true
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:         primitive.NewObjectID().Hex(),
+		UserID:     user.ID.Hex(),
+		Device:     helpers.ParseDevice(client.UserAgent),
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+
+	if err := service.authRedisRepo.SetSession(session); err != nil {
+		return nil, err
+	}
+
+	tokens, err := service.issueSessionTokens(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens.NewDevice = service.detectNewDevice(user, session, client)
+
+	return tokens, nil
+}
+
+// detectNewDevice remembers the device of a new session and returns the sign-in to report when
+// the device is unknown. The first device of a user is never reported.
+func (service *TokenServiceImpl) detectNewDevice(user *models.User, session *models.Session, client models.ClientInfo) *NewDeviceSignIn {
+	isNew, hadDevices, err := service.authRedisRepo.AddKnownDevice(user.ID.Hex(), helpers.DeviceFingerprint(client))
+	if err != nil {
+		log.Println("Error while saving known device to redis: ", err.Error())
+		return nil
+	}
+
+	if !isNew || !hadDevices || user.Email == "" {
+		return nil
+	}
+
+	reportToken := helpers.GenerateRefreshToken()
+	report := &models.NewDeviceReport{UserID: user.ID.Hex(), SignedInAt: session.CreatedAt}
+	if err := service.authRedisRepo.SetNewDeviceReportToken(reportToken, report); err != nil {
+		log.Println("Error while saving new device report token to redis: ", err.Error())
+		return nil
+	}
+
+	return &NewDeviceSignIn{
+		Email:      user.Email,
+		Device:     session.Device,
+		IP:         session.IP,
+		SignedInAt: session.CreatedAt,
+		ReportLink: config.GetServerConfig().BaseURL + "/auth/new-device/report?token=" + reportToken,
+	}
+}
+
+// RefreshTokens rotates a refresh token. Replaying a token that was already rotated
+// is treated as theft and revokes its whole session.
+func (service *TokenServiceImpl) RefreshTokens(refreshToken string, client models.ClientInfo) (*AuthTokens, error) {
+	userIdHex, sessionId, err := service.authRedisRepo.GetRefreshToken(refreshToken)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return nil, errors.New("Invalid refresh token")
+		}
+
+		return nil, err
+	}
+
+	firstUse, err := service.authRedisRepo.MarkRefreshTokenUsed(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if !firstUse {
+		if err := service.authRedisRepo.DelSession(sessionId); err != nil {
+			log.Println("Error while revoking session in redis: ", err.Error())
+		}
+
+		return nil, errors.New("Refresh token reuse detected, please log in again")
+	}
+
+	session, err := service.authRedisRepo.GetSession(sessionId)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return nil, errors.New("Invalid refresh token")
+		}
+
+		return nil, err
+	}
+
+	userId, err := primitive.ObjectIDFromHex(userIdHex)
+	if err != nil {
+		return nil, errors.New("Invalid refresh token")
+	}
+
+	// The user is read again so that role changes are reflected in the new access token
+	user, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, errors.New("Invalid refresh token")
+	}
+
+	// Saving the session also extends its lifetime, a session revoked since it was read stays revoked
+	session.IP = client.IP
+	session.UserAgent = client.UserAgent
+	session.LastSeenAt = time.Now()
+	updated, err := service.authRedisRepo.UpdateSession(session)
+	if err != nil {
+		return nil, err
+	}
+
+	if !updated {
+		return nil, errors.New("Invalid refresh token")
+	}
+
+	return service.issueSessionTokens(user, sessionId)
+}
+
+// ValidateSession checks that the session of an access token is still active and records activity on it
+func (service *TokenServiceImpl) ValidateSession(userId primitive.ObjectID, sessionId string, client models.ClientInfo) error {
+	if sessionId == "" {
+		return ErrSessionNotFound
+	}
+
+	session, err := service.authRedisRepo.GetSession(sessionId)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return ErrSessionNotFound
+		}
+
+		return err
+	}
+
+	if session.UserID != userId.Hex() {
+		return ErrSessionNotFound
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		session.IP = client.IP
+		session.LastSeenAt = time.Now()
+		updated, err := service.authRedisRepo.UpdateSession(session)
+		if err != nil {
+			log.Println("Error while updating session in redis: ", err.Error())
+		} else if !updated {
+			return ErrSessionNotFound
+		}
+	}
+
+	return nil
+}
+
+func (service *TokenServiceImpl) GetSessions(userId primitive.ObjectID) ([]*models.Session, error) {
+	return service.authRedisRepo.GetUserSessions(userId.Hex())
+}
+
+// RevokeSession revokes a session together with its refresh tokens and access tokens
+func (service *TokenServiceImpl) RevokeSession(sessionId string) error {
+	if sessionId == "" {
+		return nil
+	}
+
+	return service.authRedisRepo.DelSession(sessionId)
+}
+
+// RevokeUserSession revokes a session after checking that it belongs to the user
+func (service *TokenServiceImpl) RevokeUserSession(userId primitive.ObjectID, sessionId string) error {
+	session, err := service.authRedisRepo.GetSession(sessionId)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return ErrSessionNotFound
+		}
+
+		return err
+	}
+
+	if session.UserID != userId.Hex() {
+		return ErrSessionNotFound
+	}
+
+	return service.authRedisRepo.DelSession(sessionId)
+}
+
+func (service *TokenServiceImpl) RevokeAllSessions(userId primitive.ObjectID) error {
+	return service.authRedisRepo.DelUserSessions(userId.Hex())
+}
+
+func (service *TokenServiceImpl) issueSessionTokens(user *models.User, sessionId string) (*AuthTokens, error) {
+	accessToken, err := helpers.GenerateJWT(user.ID, user.GetRole(), sessionId)
+	if err != nil {
+		return nil, errors.New("Token generation failed")
+	}
+
+	refreshToken := helpers.GenerateRefreshToken()
+	if err := service.authRedisRepo.SetRefreshToken(refreshToken, user.ID.Hex(), sessionId); err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/repositories/redis"
+	"github.com/mercan/ecommerce/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	recoveryCodeCount   = 10
+	maxMFALoginAttempts = 5
+
+	MFAMethodTOTP     = "totp"
+	MFAMethodWebAuthn = "webauthn"
+)
+
+type TOTPService interface {
+	Setup(userId primitive.ObjectID) (string, string, error)
+	Enable(userId primitive.ObjectID, user models.UserTwoFactorCodeRequest, client models.ClientInfo) ([]string, error)
+	Disable(userId primitive.ObjectID, user models.UserTwoFactorDisableRequest, client models.ClientInfo) error
+	RegenerateRecoveryCodes(userId primitive.ObjectID, user models.UserTwoFactorCodeRequest, client models.ClientInfo) ([]string, error)
+	CompleteLogin(user *models.User, client models.ClientInfo) (*AuthTokens, error)
+	VerifyLogin(user models.UserLoginTwoFactorRequest, client models.ClientInfo) (*AuthTokens, error)
+}
+
+type TOTPServiceImpl struct {
+	userRepo       mongodb.UserMongoRepository
+	authRedisRepo  redis.AuthenticationRepository
+	TokenService   TokenService
+	LockoutService LockoutService
+	AuditService   AuditService
+}
+
+func NewTOTPService() TOTPService {
+	return &TOTPServiceImpl{
+		userRepo:       mongodb.NewUserMongoRepository(),
+		authRedisRepo:  redis.NewAuthenticationRedisRepository(),
+		TokenService:   NewTokenService(),
+		LockoutService: NewLockoutService(),
+		AuditService:   NewAuditService(),
+	}
+}
+
+// Setup generates a new secret that only becomes active once confirmed with Enable.
+// It returns the secret and its otpauth URI.
+func (service *TOTPServiceImpl) Setup(userId primitive.ObjectID) (string, string, error) {
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return "", "", err
+	}
+
+	if userDoc == nil {
+		return "", "", errors.New("User not found")
+	}
+
+	if userDoc.TwoFactor.Enabled {
+		return "", "", errors.New("Two-factor authentication already enabled")
+	}
+
+	secret := helpers.GenerateTOTPSecret()
+	if err := service.userRepo.SetTwoFactorPendingSecret(userDoc.ID, secret); err != nil {
+		return "", "", err
+	}
+
+	return secret, helpers.TOTPURI(config.GetServerConfig().AppName, userDoc.Email, secret), nil
+}
+
+// Enable confirms the pending secret with a first code and returns the recovery codes,
+// which are never shown again
+func (service *TOTPServiceImpl) Enable(userId primitive.ObjectID, user models.UserTwoFactorCodeRequest, client models.ClientInfo) (recoveryCodes []string, err error) {
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionTwoFactorEnable, userId, client, err))
+	}()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return nil, err
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc == nil {
+		return nil, errors.New("User not found")
+	}
+
+	if userDoc.TwoFactor.Enabled {
+		return nil, errors.New("Two-factor authentication already enabled")
+	}
+
+	if userDoc.TwoFactor.PendingSecret == "" {
+		return nil, errors.New("Two-factor authentication setup not started")
+	}
+
+	if err := service.verifyTOTP(userDoc.ID, userDoc.TwoFactor.PendingSecret, user.Code); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, hashedRecoveryCodes := generateRecoveryCodes()
+	if err := service.userRepo.EnableTwoFactor(userDoc.ID, userDoc.TwoFactor.PendingSecret, hashedRecoveryCodes); err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+func (service *TOTPServiceImpl) Disable(userId primitive.ObjectID, user models.UserTwoFactorDisableRequest, client models.ClientInfo) (err error) {
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionTwoFactorDisable, userId, client, err))
+	}()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return err
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return err
+	}
+
+	if userDoc == nil {
+		return errors.New("User not found")
+	}
+
+	if !userDoc.TwoFactor.Enabled {
+		return errors.New("Two-factor authentication not enabled")
+	}
+
+	if result := helpers.VerifyPassword(userDoc.Password, user.Password); result != true {
+		return errors.New("Invalid password")
+	}
+
+	if err := service.verifyCode(userDoc, user.Code); err != nil {
+		return err
+	}
+
+	return service.userRepo.DisableTwoFactor(userDoc.ID)
+}
+
+func (service *TOTPServiceImpl) RegenerateRecoveryCodes(userId primitive.ObjectID, user models.UserTwoFactorCodeRequest, client models.ClientInfo) (recoveryCodes []string, err error) {
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionRecoveryCodesRegenerate, userId, client, err))
+	}()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return nil, err
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc == nil {
+		return nil, errors.New("User not found")
+	}
+
+	if !userDoc.TwoFactor.Enabled {
+		return nil, errors.New("Two-factor authentication not enabled")
+	}
+
+	if err := service.verifyTOTP(userDoc.ID, userDoc.TwoFactor.Secret, user.Code); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, hashedRecoveryCodes := generateRecoveryCodes()
+	if err := service.userRepo.SetRecoveryCodes(userDoc.ID, hashedRecoveryCodes); err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// CompleteLogin finishes a login whose first factor was verified. Users with two-factor
+// authentication get a short-lived "mfa pending" token instead of a token pair, which their
+// passkeys can redeem as well as a TOTP code.
+func (service *TOTPServiceImpl) CompleteLogin(user *models.User, client models.ClientInfo) (*AuthTokens, error) {
+	// Checked again by IssueTokens, rejecting early does not ask for a second factor in vain
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
+	}
+
+	if !user.TwoFactor.Enabled {
+		return service.TokenService.IssueTokens(user, client)
+	}
+
+	mfaToken := helpers.GenerateRefreshToken()
+	if err := service.authRedisRepo.SetMFAPendingToken(mfaToken, user.ID.Hex()); err != nil {
+		return nil, err
+	}
+
+	mfaMethods := []string{MFAMethodTOTP}
+	if len(user.WebAuthnCredentials) > 0 {
+		mfaMethods = append(mfaMethods, MFAMethodWebAuthn)
+	}
+
+	return &AuthTokens{MFAToken: mfaToken, MFAMethods: mfaMethods}, nil
+}
+
+// VerifyLogin exchanges an "mfa pending" token and a TOTP or recovery code for a full token pair
+func (service *TOTPServiceImpl) VerifyLogin(user models.UserLoginTwoFactorRequest, client models.ClientInfo) (tokens *AuthTokens, err error) {
+	// This is the last factor of the sign-in, its event records whether the sign-in succeeded
+	var userId primitive.ObjectID
+	defer func() { service.AuditService.Record(newAuditEvent(models.AuditActionMFAVerify, userId, client, err)) }()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return nil, err
+	}
+
+	userIdHex, err := service.authRedisRepo.GetMFAPendingToken(user.MFAToken)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return nil, errors.New("Invalid or expired MFA token")
+		}
+
+		return nil, err
+	}
+
+	attempts, err := service.authRedisRepo.IncrMFAPendingAttempts(user.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if attempts > maxMFALoginAttempts {
+		if err := service.authRedisRepo.DelMFAPendingToken(user.MFAToken); err != nil {
+			log.Println("Error while deleting mfa pending token from redis: ", err.Error())
+		}
+
+		return nil, errors.New("Too many attempts, please log in again")
+	}
+
+	userId, err = primitive.ObjectIDFromHex(userIdHex)
+	if err != nil {
+		return nil, errors.New("Invalid or expired MFA token")
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc == nil || !userDoc.TwoFactor.Enabled {
+		return nil, errors.New("Invalid or expired MFA token")
+	}
+
+	if err := service.LockoutService.CheckSecondFactor(userIdHex); err != nil {
+		return nil, err
+	}
+
+	if err := service.verifyCode(userDoc, user.Code); err != nil {
+		if lockoutErr := service.LockoutService.RegisterSecondFactorFailure(userIdHex); lockoutErr != nil {
+			return nil, lockoutErr
+		}
+
+		return nil, err
+	}
+
+	if err := service.authRedisRepo.DelMFAPendingToken(user.MFAToken); err != nil {
+		log.Println("Error while deleting mfa pending token from redis: ", err.Error())
+	}
+
+	resetSignInFailures(service.LockoutService, userDoc)
+
+	return service.TokenService.IssueTokens(userDoc, client)
+}
+
+// verifyCode accepts either a TOTP code or an unused recovery code
+func (service *TOTPServiceImpl) verifyCode(userDoc *models.User, code string) error {
+	if len(code) != 6 {
+		used, err := service.userRepo.UseRecoveryCode(userDoc.ID, helpers.HashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+
+		if !used {
+			return errors.New("Invalid recovery code")
+		}
+
+		return nil
+	}
+
+	return service.verifyTOTP(userDoc.ID, userDoc.TwoFactor.Secret, code)
+}
+
+// verifyTOTP validates a TOTP code and rejects it if it was already used
+func (service *TOTPServiceImpl) verifyTOTP(userId primitive.ObjectID, secret string, code string) error {
+	step, ok := helpers.ValidateTOTPCode(secret, code, time.Now())
+	if !ok {
+		return errors.New("Invalid two-factor code")
+	}
+
+	firstUse, err := service.authRedisRepo.MarkTOTPStepUsed(userId.Hex(), step)
+	if err != nil {
+		return err
+	}
+
+	if !firstUse {
+		return errors.New("Two-factor code already used")
+	}
+
+	return nil
+}
+
+// generateRecoveryCodes returns recovery codes together with the hashes to store
+func generateRecoveryCodes() ([]string, []string) {
+	recoveryCodes := helpers.GenerateRecoveryCodes(recoveryCodeCount)
+	hashedRecoveryCodes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		hashedRecoveryCodes[i] = helpers.HashRecoveryCode(code)
+	}
+
+	return recoveryCodes, hashedRecoveryCodes
+}
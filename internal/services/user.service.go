@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"github.com/mercan/ecommerce/internal/config"
 	"github.com/mercan/ecommerce/internal/helpers"
 	"github.com/mercan/ecommerce/internal/models"
 	"github.com/mercan/ecommerce/internal/repositories/mongodb"
@@ -9,97 +10,243 @@ import (
 	"github.com/mercan/ecommerce/internal/validators"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
 	"time"
 )
 
 type UserService interface {
-	Register(user *models.User) (string, error)
-	Login(user models.UserLoginRequest) (string, error)
-	Logout(token string, expFloat64 float64) error
+	Register(user *models.User, client models.ClientInfo) (*AuthTokens, error)
+	Login(user models.UserLoginRequest, client models.ClientInfo) (*AuthTokens, error)
+	Refresh(user models.UserRefreshRequest, client models.ClientInfo) (*AuthTokens, error)
+	Logout(userId primitive.ObjectID, token string, sessionId string, expFloat64 float64, client models.ClientInfo) error
+	LogoutAll(userId primitive.ObjectID, client models.ClientInfo) error
+	GetSessions(userId primitive.ObjectID) ([]*models.Session, error)
+	RevokeSession(userId primitive.ObjectID, sessionId string, client models.ClientInfo) error
 	ChangePassword(userId primitive.ObjectID, user models.UserChangePasswordRequest, token string,
-		expFloat64 float64) (string, error)
-	ChangeEmail(userId primitive.ObjectID, user models.UserChangeEmailRequest, token string, expFloat64 float64) (string, error)
-	VerifyEmail(userId primitive.ObjectID, user models.UserVerificationRequest) error
+		sessionId string, expFloat64 float64, client models.ClientInfo) (*AuthTokens, error)
+	ChangeEmail(userId primitive.ObjectID, user models.UserChangeEmailRequest, client models.ClientInfo) (*EmailChange, error)
+	VerifyEmailChange(userId primitive.ObjectID, user models.UserVerificationRequest, token string,
+		sessionId string, expFloat64 float64, client models.ClientInfo) (*AuthTokens, error)
+	RevertEmailChange(user models.UserRevertEmailChangeRequest, client models.ClientInfo) (string, error)
+	ReportNewDevice(user models.UserReportNewDeviceRequest, client models.ClientInfo) (string, error)
+	VerifyEmail(userId primitive.ObjectID, user models.UserVerificationRequest, client models.ClientInfo) error
 	ResendEmailVerification(userId primitive.ObjectID) error
-	VerifyPhone(userId primitive.ObjectID, user models.UserVerificationRequest) error
+	VerifyPhone(userId primitive.ObjectID, user models.UserVerificationRequest, client models.ClientInfo) error
 	ResendPhoneVerification(userId primitive.ObjectID) error
+	ChangePhone(userId primitive.ObjectID, user models.UserChangePhoneRequest, client models.ClientInfo) error
+	ChangeRole(adminId primitive.ObjectID, userId primitive.ObjectID, user models.UserChangeRoleRequest, client models.ClientInfo) error
+	Impersonate(adminId primitive.ObjectID, sessionId string, userId primitive.ObjectID, client models.ClientInfo) (*Impersonation, error)
+	EndImpersonation(adminId primitive.ObjectID, userId primitive.ObjectID, token string, expFloat64 float64, client models.ClientInfo) error
+	ForgotPassword(user models.UserForgotPasswordRequest, client models.ClientInfo) (bool, error)
+	ResetPassword(token string, user models.UserResetPasswordRequest, client models.ClientInfo) error
+}
+
+// EmailChange is a staged email change. The confirmation code has to be sent to NewEmail
+// and the revert link to OldEmail.
+type EmailChange struct {
+	FirstName  string
+	OldEmail   string
+	NewEmail   string
+	RevertLink string
+}
+
+// Impersonation is a short-lived access token that lets an admin act as a user, it can't be refreshed
+type Impersonation struct {
+	Token     string
+	ExpiresAt time.Time
 }
 
 type UserServiceImpl struct {
 	userRepo            mongodb.UserMongoRepository
+	apiKeyRepo          mongodb.APIKeyMongoRepository
 	authRedisRepo       redis.AuthenticationRepository
 	MailService         MailService
 	SMSService          SMSService
 	VerificationService VerificationService
+	TokenService        TokenService
+	TOTPService         TOTPService
+	LockoutService      LockoutService
+	PasswordPolicy      PasswordPolicyService
+	AuditService        AuditService
 }
 
 func NewUserService() UserService {
 	return &UserServiceImpl{
 		userRepo:            mongodb.NewUserMongoRepository(),
+		apiKeyRepo:          mongodb.NewAPIKeyMongoRepository(),
 		authRedisRepo:       redis.NewAuthenticationRedisRepository(),
 		MailService:         NewMailService(),
 		SMSService:          NewSMSService(),
 		VerificationService: NewVerificationService(),
+		TokenService:        NewTokenService(),
+		TOTPService:         NewTOTPService(),
+		LockoutService:      NewLockoutService(),
+		PasswordPolicy:      NewPasswordPolicyService(),
+		AuditService:        NewAuditService(),
 	}
 }
 
-func (service *UserServiceImpl) Register(user *models.User) (string, error) {
+func (service *UserServiceImpl) Register(user *models.User, client models.ClientInfo) (tokens *AuthTokens, err error) {
+	defer func() {
+		// The account only exists when the registration succeeded
+		userId := user.ID
+		if err != nil {
+			userId = primitive.NilObjectID
+		}
+
+		event := newAuditEvent(models.AuditActionRegister, userId, client, err)
+		event.Email = user.Email
+		service.AuditService.Record(event)
+	}()
+
 	if emailExists, err := service.userRepo.CheckEmailExists(user.Email); err != nil {
-		return "", err
+		return nil, err
 	} else if emailExists {
-		return "", errors.New("Email already exists")
+		return nil, errors.New("Email already exists")
+	}
+
+	if user.PhoneNumber != "" {
+		if phoneExists, err := service.userRepo.CheckPhoneExists(user.PhoneNumber); err != nil {
+			return nil, err
+		} else if phoneExists {
+			return nil, errors.New("Phone number already exists")
+		}
+	}
+
+	if err := service.PasswordPolicy.Validate(user.Password, user); err != nil {
+		return nil, err
 	}
 
 	hashedPassword, err := helpers.HashPassword(user.Password)
 	if err != nil {
-		return "", errors.New("Password hashing failed")
+		return nil, errors.New("Password hashing failed")
 	}
 	user.Password = hashedPassword
 
-	token, err := helpers.GenerateJWT(user.ID)
-	if err != nil {
-		return "", errors.New("Token generation failed")
-	}
+	// Roles are granted by admins only and verification happens later, never taken from the request body
+	user.Role = models.RoleCustomer
+	user.EmailVerified = false
+	user.PhoneNumberVerified = false
+	user.PendingEmail = ""
+	user.IsActive = true
+	user.DeactivatedAt = nil
+	user.DeletionScheduledAt = nil
 
 	if err := service.userRepo.CreateUser(user); err != nil {
-		return "", err
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("Email or phone number already exists")
+		}
+
+		return nil, err
 	}
 
-	return token, nil
+	return service.TokenService.IssueTokens(user, client)
 }
 
-func (service *UserServiceImpl) Login(user models.UserLoginRequest) (string, error) {
+func (service *UserServiceImpl) Login(user models.UserLoginRequest, client models.ClientInfo) (tokens *AuthTokens, err error) {
+	var userId primitive.ObjectID
+	// The response does not tell unknown emails and wrong passwords apart, the audit log does
+	var reason string
+	defer func() {
+		event := newLoginAuditEvent(models.AuditActionLogin, userId, client, tokens, err)
+		event.Email = user.Email
+		if err != nil && reason != "" {
+			event.Reason = reason
+		}
+		service.AuditService.Record(event)
+	}()
+
 	if err := validators.ValidateStruct(user); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	project := bson.D{{"email", 1}, {"password", 1}}
+	// Locked accounts are rejected before the password is checked
+	if err := service.LockoutService.CheckLogin(user.Email); err != nil {
+		return nil, err
+	}
+
+	project := bson.D{
+		{Key: "email", Value: 1},
+		{Key: "password", Value: 1},
+		{Key: "role", Value: 1},
+		{Key: "is_active", Value: 1},
+		{Key: "password_reset_required", Value: 1},
+		{Key: "two_factor.enabled", Value: 1},
+		{Key: "webauthn_credentials.credential_id", Value: 1},
+	}
 	findOneOptions := options.FindOne().SetProjection(project)
 
 	userDoc, err := service.userRepo.GetUserByEmail(user.Email, findOneOptions)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if userDoc == nil {
-		return "", errors.New("Invalid email or password")
+		reason = "Unknown email"
+		return nil, service.registerLoginFailure(user.Email, false)
 	}
 
+	userId = userDoc.ID
 	if result := helpers.VerifyPassword(userDoc.Password, user.Password); result != true {
-		return "", errors.New("Invalid email or password")
+		reason = "Invalid password"
+		return nil, service.registerLoginFailure(user.Email, true)
+	}
+
+	// Set when a sign-in was reported as not made by the owner, the password may be known to someone else
+	if userDoc.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
+	}
+
+	// The plain text password is only available here, so outdated hashes are upgraded on login
+	if helpers.PasswordNeedsRehash(userDoc.Password) {
+		if hashedPassword, err := helpers.HashPassword(user.Password); err != nil {
+			log.Println("Error while rehashing password: ", err.Error())
+		} else if err := service.userRepo.UpgradePasswordHash(userDoc.ID, userDoc.Password, hashedPassword); err != nil {
+			log.Println("Error while upgrading password hash: ", err.Error())
+		}
 	}
 
-	token, err := helpers.GenerateJWT(userDoc.ID)
+	// Tokens are only issued once the second factor is verified, failures are forgiven by that step
+	tokens, err = service.TOTPService.CompleteLogin(userDoc, client)
 	if err != nil {
-		return "", errors.New("Token generation failed")
+		return nil, err
 	}
 
-	return token, nil
+	if tokens.MFAToken == "" {
+		resetSignInFailures(service.LockoutService, userDoc)
+	}
+
+	return tokens, nil
 }
 
-func (service *UserServiceImpl) Logout(token string, expFloat64 float64) error {
+// registerLoginFailure counts a failed login and returns the error to answer with.
+// Unknown emails are counted as well so that they behave like existing accounts.
+func (service *UserServiceImpl) registerLoginFailure(email string, accountExists bool) error {
+	if err := service.LockoutService.RegisterLoginFailure(email); err != nil {
+		var lockoutErr *LockoutError
+		if errors.As(err, &lockoutErr) {
+			lockoutErr.JustLocked = lockoutErr.JustLocked && accountExists
+		}
+
+		return err
+	}
+
+	return errors.New("Invalid email or password")
+}
+
+func (service *UserServiceImpl) Refresh(user models.UserRefreshRequest, client models.ClientInfo) (*AuthTokens, error) {
+	if err := validators.ValidateStruct(user); err != nil {
+		return nil, err
+	}
+
+	return service.TokenService.RefreshTokens(user.RefreshToken, client)
+}
+
+func (service *UserServiceImpl) Logout(userId primitive.ObjectID, token string, sessionId string, expFloat64 float64, client models.ClientInfo) (err error) {
+	defer func() { service.AuditService.Record(newAuditEvent(models.AuditActionLogout, userId, client, err)) }()
+
 	// Convert to time.Time type from float64
 	expiration := time.Unix(int64(expFloat64), 0)
 	// Calculate remaining time
@@ -112,34 +259,74 @@ func (service *UserServiceImpl) Logout(token string, expFloat64 float64) error {
 		return err
 	}
 
+	// Refresh tokens of the session can no longer be used
+	if err := service.TokenService.RevokeSession(sessionId); err != nil {
+		log.Println("Error while revoking session in redis: ", err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+func (service *UserServiceImpl) LogoutAll(userId primitive.ObjectID, client models.ClientInfo) (err error) {
+	defer func() { service.AuditService.Record(newAuditEvent(models.AuditActionLogoutAll, userId, client, err)) }()
+
+	if err := service.TokenService.RevokeAllSessions(userId); err != nil {
+		log.Println("Error while revoking sessions in redis: ", err.Error())
+
+		return err
+	}
+
 	return nil
 }
 
-func (service *UserServiceImpl) ChangePassword(userId primitive.ObjectID, user models.UserChangePasswordRequest, token string, expFloat64 float64) (string, error) {
+func (service *UserServiceImpl) GetSessions(userId primitive.ObjectID) ([]*models.Session, error) {
+	return service.TokenService.GetSessions(userId)
+}
+
+func (service *UserServiceImpl) RevokeSession(userId primitive.ObjectID, sessionId string, client models.ClientInfo) error {
+	err := service.TokenService.RevokeUserSession(userId, sessionId)
+	service.AuditService.Record(newAuditEvent(models.AuditActionSessionRevoke, userId, client, err))
+
+	return err
+}
+
+func (service *UserServiceImpl) ChangePassword(userId primitive.ObjectID, user models.UserChangePasswordRequest, token string, sessionId string, expFloat64 float64, client models.ClientInfo) (tokens *AuthTokens, err error) {
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionPasswordChange, userId, client, err))
+	}()
+
 	if err := validators.ValidateStruct(user); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	userDoc, err := service.userRepo.GetUserByID(userId)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if userDoc == nil {
-		return "", errors.New("User not found")
+		return nil, errors.New("User not found")
 	}
 
 	if result := helpers.VerifyPassword(userDoc.Password, user.Password); result != true {
-		return "", errors.New("Invalid password")
+		return nil, errors.New("Invalid password")
 	}
 
 	if user.Password == user.NewPassword {
-		return "", errors.New("Old password and new password cannot be the same")
+		return nil, errors.New("Old password and new password cannot be the same")
 	}
 
-	if err := service.userRepo.ChangePassword(userDoc.ID, user.NewPassword); err != nil {
-		return "", err
+	if err := service.PasswordPolicy.Validate(user.NewPassword, userDoc); err != nil {
+		return nil, err
+	}
+
+	if err := service.userRepo.ChangePassword(userDoc.ID, user.NewPassword, service.PasswordPolicy.AppendHistory(userDoc)); err != nil {
+		return nil, err
 	}
+	// Changing the password satisfies a required reset
+	userDoc.PasswordResetRequired = false
 
 	// Convert to time.Time type from float64
 	expiration := time.Unix(int64(expFloat64), 0)
@@ -150,46 +337,118 @@ func (service *UserServiceImpl) ChangePassword(userId primitive.ObjectID, user m
 	if err := service.authRedisRepo.SetBlacklistToken(token, remainingTime); err != nil {
 		log.Println("Error while saving token to redis: ", err.Error())
 
-		return "", err
+		return nil, err
 	}
 
-	newToken, err := helpers.GenerateJWT(userDoc.ID)
-	if err != nil {
-		return "", errors.New("Token generation failed")
+	// The current session is replaced by a new one
+	if err := service.TokenService.RevokeSession(sessionId); err != nil {
+		log.Println("Error while revoking session in redis: ", err.Error())
+
+		return nil, err
 	}
 
-	return newToken, nil
+	return service.TokenService.IssueTokens(userDoc, client)
 }
 
-func (service *UserServiceImpl) ChangeEmail(userId primitive.ObjectID, user models.UserChangeEmailRequest, token string, expFloat64 float64) (string, error) {
+// ChangeEmail stages an email change. The email is only replaced once the new address is
+// verified with VerifyEmailChange, until then the old address can cancel the change.
+func (service *UserServiceImpl) ChangeEmail(userId primitive.ObjectID, user models.UserChangeEmailRequest, client models.ClientInfo) (emailChange *EmailChange, err error) {
+	defer func() {
+		event := newAuditEvent(models.AuditActionEmailChangeRequest, userId, client, err)
+		event.Email = user.Email
+		service.AuditService.Record(event)
+	}()
+
 	if err := validators.ValidateStruct(user); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	userDoc, err := service.userRepo.GetUserByID(userId)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if userDoc == nil {
-		return "", errors.New("User not found")
+		return nil, errors.New("User not found")
 	}
 
 	if userDoc.Email == user.Email {
-		return "", errors.New("Old email and new email cannot be the same")
+		return nil, errors.New("Old email and new email cannot be the same")
 	}
 
 	existingEmail, err := service.userRepo.CheckEmailExists(user.Email)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if existingEmail {
-		return "", errors.New("Email already exists")
+		return nil, errors.New("Email already exists")
 	}
 
-	if err := service.userRepo.ChangeEmail(userDoc.ID, user.Email); err != nil {
-		return "", err
+	if err := service.userRepo.SetPendingEmail(userDoc.ID, user.Email); err != nil {
+		return nil, err
+	}
+
+	// Only the revert link of the latest change is valid
+	if err := service.authRedisRepo.DelEmailChangeRevertTokens(userDoc.ID.Hex()); err != nil {
+		return nil, err
+	}
+
+	revertToken := helpers.GenerateRefreshToken()
+	revert := &models.EmailChangeRevert{
+		UserID:        userDoc.ID.Hex(),
+		Email:         userDoc.Email,
+		EmailVerified: userDoc.EmailVerified,
+		NewEmail:      user.Email,
+	}
+	if err := service.authRedisRepo.SetEmailChangeRevertToken(revertToken, revert); err != nil {
+		return nil, err
+	}
+
+	return &EmailChange{
+		FirstName:  userDoc.FirstName,
+		OldEmail:   userDoc.Email,
+		NewEmail:   user.Email,
+		RevertLink: config.GetServerConfig().BaseURL + "/auth/change-email/revert?token=" + revertToken,
+	}, nil
+}
+
+// VerifyEmailChange commits a staged email change with the code sent to the new address
+func (service *UserServiceImpl) VerifyEmailChange(userId primitive.ObjectID, user models.UserVerificationRequest, token string, sessionId string, expFloat64 float64, client models.ClientInfo) (tokens *AuthTokens, err error) {
+	defer func() { service.AuditService.Record(newAuditEvent(models.AuditActionEmailChange, userId, client, err)) }()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return nil, err
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc == nil {
+		return nil, errors.New("User not found")
+	}
+
+	if userDoc.PendingEmail == "" {
+		return nil, errors.New("No email change pending")
+	}
+
+	if err := service.VerificationService.VerifyEmail(userDoc.PendingEmail, user.Code); err != nil {
+		return nil, err
+	}
+
+	committed, err := service.userRepo.CommitPendingEmail(userDoc.ID, userDoc.PendingEmail)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("Email already exists")
+		}
+
+		return nil, err
+	}
+
+	if !committed {
+		return nil, errors.New("No email change pending")
 	}
 
 	// Convert to time.Time type from float64
@@ -201,18 +460,156 @@ func (service *UserServiceImpl) ChangeEmail(userId primitive.ObjectID, user mode
 	if err := service.authRedisRepo.SetBlacklistToken(token, remainingTime); err != nil {
 		log.Println("Error while saving token to redis: ", err.Error())
 
+		return nil, err
+	}
+
+	// The current session is replaced by a new one
+	if err := service.TokenService.RevokeSession(sessionId); err != nil {
+		log.Println("Error while revoking session in redis: ", err.Error())
+
+		return nil, err
+	}
+
+	return service.TokenService.IssueTokens(userDoc, client)
+}
+
+// RevertEmailChange cancels a pending email change or restores the old email after it was
+// committed. As the change may have been made by someone else, every session is revoked.
+// It returns the restored email.
+func (service *UserServiceImpl) RevertEmailChange(user models.UserRevertEmailChangeRequest, client models.ClientInfo) (email string, err error) {
+	var userId primitive.ObjectID
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionEmailChangeRevert, userId, client, err))
+	}()
+
+	if err := validators.ValidateStruct(user); err != nil {
 		return "", err
 	}
 
-	newToken, err := helpers.GenerateJWT(userDoc.ID)
+	revert, err := service.authRedisRepo.ConsumeEmailChangeRevertToken(user.Token)
 	if err != nil {
-		return "", errors.New("Token generation failed")
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return "", errors.New("Invalid or expired revert link")
+		}
+
+		return "", err
+	}
+
+	revertUserId, err := primitive.ObjectIDFromHex(revert.UserID)
+	if err != nil {
+		return "", errors.New("Invalid or expired revert link")
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(revertUserId)
+	if err != nil {
+		return "", err
+	}
+
+	if userDoc == nil {
+		return "", errors.New("Invalid or expired revert link")
+	}
+
+	userId = userDoc.ID
+	reverted, err := service.userRepo.RevertEmail(userDoc.ID, revert.Email, revert.NewEmail, revert.EmailVerified)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return "", errors.New("Email already exists")
+		}
+
+		return "", err
+	}
+
+	// The email was changed again or reverted since the link was sent
+	if !reverted {
+		return "", errors.New("Invalid or expired revert link")
+	}
+
+	if err := service.authRedisRepo.DelEmailChangeRevertTokens(userDoc.ID.Hex()); err != nil {
+		log.Println("Error while deleting email change revert tokens from redis: ", err.Error())
+	}
+
+	if userDoc.PendingEmail != "" {
+		if err := service.authRedisRepo.DelVerificationEmail(userDoc.PendingEmail); err != nil {
+			log.Println("Error while deleting email verification code from redis: ", err.Error())
+		}
+	}
+
+	if err := service.TokenService.RevokeAllSessions(userDoc.ID); err != nil {
+		log.Println("Error while revoking sessions in redis: ", err.Error())
+
+		return "", err
+	}
+
+	return revert.Email, nil
+}
+
+// ReportNewDevice handles the "this wasn't me" link of a new sign-in notification. Every session
+// and API key is revoked, passkeys registered since the sign-in are removed, known devices are
+// forgotten and sign-ins are blocked until the password is reset. It returns the email the reset
+// link has to be sent to.
+func (service *UserServiceImpl) ReportNewDevice(user models.UserReportNewDeviceRequest, client models.ClientInfo) (email string, err error) {
+	var userId primitive.ObjectID
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionNewDeviceReport, userId, client, err))
+	}()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return "", err
+	}
+
+	report, err := service.authRedisRepo.ConsumeNewDeviceReportToken(user.Token)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return "", errors.New("Invalid or expired report link")
+		}
+
+		return "", err
+	}
+
+	reportUserId, err := primitive.ObjectIDFromHex(report.UserID)
+	if err != nil {
+		return "", errors.New("Invalid or expired report link")
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(reportUserId)
+	if err != nil {
+		return "", err
+	}
+
+	if userDoc == nil {
+		return "", errors.New("Invalid or expired report link")
+	}
+
+	userId = userDoc.ID
+	if err := service.userRepo.RequirePasswordReset(userDoc.ID); err != nil {
+		return "", err
+	}
+
+	if err := service.TokenService.RevokeAllSessions(userDoc.ID); err != nil {
+		log.Println("Error while revoking sessions in redis: ", err.Error())
+
+		return "", err
+	}
+
+	// API keys and passkeys work without a session, the reported sign-in may have added its own
+	if err := service.apiKeyRepo.DeleteAPIKeysByUserID(userDoc.ID); err != nil {
+		return "", err
+	}
+
+	if err := service.userRepo.RemoveWebAuthnCredentialsSince(userDoc.ID, report.SignedInAt); err != nil {
+		return "", err
+	}
+
+	if err := service.authRedisRepo.DelKnownDevices(userDoc.ID.Hex()); err != nil {
+		log.Println("Error while deleting known devices from redis: ", err.Error())
 	}
 
-	return newToken, nil
+	return userDoc.Email, nil
 }
 
-func (service *UserServiceImpl) VerifyEmail(userId primitive.ObjectID, user models.UserVerificationRequest) error {
+func (service *UserServiceImpl) VerifyEmail(userId primitive.ObjectID, user models.UserVerificationRequest, client models.ClientInfo) (err error) {
+	defer func() { service.AuditService.Record(newAuditEvent(models.AuditActionEmailVerify, userId, client, err)) }()
+
 	if err := validators.ValidateStruct(user); err != nil {
 		return err
 	}
@@ -262,7 +659,9 @@ func (service *UserServiceImpl) ResendEmailVerification(userId primitive.ObjectI
 	return nil
 }
 
-func (service *UserServiceImpl) VerifyPhone(userId primitive.ObjectID, user models.UserVerificationRequest) error {
+func (service *UserServiceImpl) VerifyPhone(userId primitive.ObjectID, user models.UserVerificationRequest, client models.ClientInfo) (err error) {
+	defer func() { service.AuditService.Record(newAuditEvent(models.AuditActionPhoneVerify, userId, client, err)) }()
+
 	if err := validators.ValidateStruct(user); err != nil {
 		return err
 	}
@@ -276,6 +675,10 @@ func (service *UserServiceImpl) VerifyPhone(userId primitive.ObjectID, user mode
 		return errors.New("User not found")
 	}
 
+	if userDoc.PhoneNumber == "" {
+		return errors.New("Phone number not set")
+	}
+
 	if userDoc.PhoneNumberVerified == true {
 		return errors.New("Phone number already verified")
 	}
@@ -284,7 +687,12 @@ func (service *UserServiceImpl) VerifyPhone(userId primitive.ObjectID, user mode
 		return err
 	}
 
+	// Another user may have verified the number since it was entered
 	if err := service.userRepo.UpdatePhoneVerificationStatus(userDoc.ID); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("Phone number already exists")
+		}
+
 		return err
 	}
 
@@ -301,6 +709,10 @@ func (service *UserServiceImpl) ResendPhoneVerification(userId primitive.ObjectI
 		return errors.New("User not found")
 	}
 
+	if userDoc.PhoneNumber == "" {
+		return errors.New("Phone number not set")
+	}
+
 	if userDoc.PhoneNumberVerified == true {
 		return errors.New("Phone number already verified")
 	}
@@ -311,3 +723,232 @@ func (service *UserServiceImpl) ResendPhoneVerification(userId primitive.ObjectI
 
 	return nil
 }
+
+// ChangePhone sets a new, unverified phone number. Callers enqueue the verification SMS.
+func (service *UserServiceImpl) ChangePhone(userId primitive.ObjectID, user models.UserChangePhoneRequest, client models.ClientInfo) (err error) {
+	defer func() { service.AuditService.Record(newAuditEvent(models.AuditActionPhoneChange, userId, client, err)) }()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return err
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return err
+	}
+
+	if userDoc == nil {
+		return errors.New("User not found")
+	}
+
+	if userDoc.PhoneNumber == user.PhoneNumber {
+		return errors.New("Old phone number and new phone number cannot be the same")
+	}
+
+	phoneExists, err := service.userRepo.CheckPhoneExists(user.PhoneNumber)
+	if err != nil {
+		return err
+	}
+
+	if phoneExists {
+		return errors.New("Phone number already exists")
+	}
+
+	if err := service.userRepo.ChangePhone(userDoc.ID, user.PhoneNumber); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("Phone number already exists")
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// ChangeRole grants a new role to a user and signs out all of their sessions
+// so that tokens carrying the previous role stop working immediately.
+func (service *UserServiceImpl) ChangeRole(adminId primitive.ObjectID, userId primitive.ObjectID, user models.UserChangeRoleRequest, client models.ClientInfo) (err error) {
+	defer func() {
+		event := newAuditEvent(models.AuditActionRoleChange, userId, client, err)
+		event.ActorID = &adminId
+		service.AuditService.Record(event)
+	}()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return err
+	}
+
+	if !user.Role.IsValid() {
+		return errors.New("Invalid role")
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return err
+	}
+
+	if userDoc == nil {
+		return errors.New("User not found")
+	}
+
+	if userDoc.GetRole() == user.Role {
+		return errors.New("User already has this role")
+	}
+
+	if err := service.userRepo.ChangeRole(userDoc.ID, user.Role); err != nil {
+		return err
+	}
+
+	if err := service.TokenService.RevokeAllSessions(userDoc.ID); err != nil {
+		log.Println("Error while revoking sessions in redis: ", err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// Impersonate issues an impersonation token for an admin, bound to the admin's current session
+func (service *UserServiceImpl) Impersonate(adminId primitive.ObjectID, sessionId string, userId primitive.ObjectID, client models.ClientInfo) (impersonation *Impersonation, err error) {
+	defer func() {
+		event := newAuditEvent(models.AuditActionImpersonationStart, userId, client, err)
+		event.ActorID = &adminId
+		service.AuditService.Record(event)
+	}()
+
+	if adminId == userId {
+		return nil, errors.New("You can't impersonate yourself")
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc == nil {
+		return nil, errors.New("User not found")
+	}
+
+	// Acting as another admin would hide who made an admin change
+	if userDoc.GetRole() == models.RoleAdmin {
+		return nil, errors.New("Admins can't be impersonated")
+	}
+
+	if !userDoc.IsActive {
+		return nil, errors.New("User is deactivated")
+	}
+
+	token, expiresAt, err := helpers.GenerateImpersonationJWT(userDoc.ID, userDoc.GetRole(), adminId, sessionId)
+	if err != nil {
+		return nil, errors.New("Token generation failed")
+	}
+
+	return &Impersonation{
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// EndImpersonation revokes an impersonation token before it expires
+func (service *UserServiceImpl) EndImpersonation(adminId primitive.ObjectID, userId primitive.ObjectID, token string, expFloat64 float64, client models.ClientInfo) (err error) {
+	defer func() {
+		event := newAuditEvent(models.AuditActionImpersonationEnd, userId, client, err)
+		event.ActorID = &adminId
+		service.AuditService.Record(event)
+	}()
+
+	remainingTime := time.Unix(int64(expFloat64), 0).Sub(time.Now())
+	if err := service.authRedisRepo.SetBlacklistToken(token, remainingTime); err != nil {
+		log.Println("Error while saving token to redis: ", err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+// ForgotPassword reports whether a reset email should be sent for the given address.
+// Callers must answer identically in both cases so that accounts cannot be enumerated.
+func (service *UserServiceImpl) ForgotPassword(user models.UserForgotPasswordRequest, client models.ClientInfo) (emailExists bool, err error) {
+	defer func() {
+		event := newAuditEvent(models.AuditActionPasswordResetRequest, primitive.NilObjectID, client, err)
+		event.Email = user.Email
+		if err == nil && !emailExists {
+			event.Outcome = models.AuditOutcomeFailure
+			event.Reason = "Unknown email"
+		}
+		service.AuditService.Record(event)
+	}()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return false, err
+	}
+
+	emailExists, err = service.userRepo.CheckEmailExists(user.Email)
+	if err != nil {
+		return false, err
+	}
+
+	return emailExists, nil
+}
+
+func (service *UserServiceImpl) ResetPassword(token string, user models.UserResetPasswordRequest, client models.ClientInfo) (err error) {
+	var userId primitive.ObjectID
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionPasswordReset, userId, client, err))
+	}()
+
+	if err := validators.ValidateStruct(user); err != nil {
+		return err
+	}
+
+	email, err := service.authRedisRepo.GetForgotPasswordEmail(token)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return errors.New("Invalid or expired reset token")
+		}
+
+		return err
+	}
+
+	userDoc, err := service.userRepo.GetUserByEmail(email, nil)
+	if err != nil {
+		return err
+	}
+
+	if userDoc == nil {
+		return errors.New("Invalid or expired reset token")
+	}
+
+	userId = userDoc.ID
+	if err := service.PasswordPolicy.Validate(user.NewPassword, userDoc); err != nil {
+		return err
+	}
+
+	// The token stays valid when the password is rejected, it is used up right before the change
+	consumedEmail, err := service.authRedisRepo.ConsumeForgotPasswordToken(token)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return errors.New("Invalid or expired reset token")
+		}
+
+		return err
+	}
+
+	if consumedEmail != email {
+		return errors.New("Invalid or expired reset token")
+	}
+
+	if err := service.userRepo.ChangePassword(userDoc.ID, user.NewPassword, service.PasswordPolicy.AppendHistory(userDoc)); err != nil {
+		return err
+	}
+
+	// Every session opened before the reset is no longer valid
+	if err := service.TokenService.RevokeAllSessions(userDoc.ID); err != nil {
+		log.Println("Error while revoking sessions in redis: ", err.Error())
+
+		return err
+	}
+
+	return nil
+}
@@ -12,12 +12,14 @@ type VerificationService interface {
 }
 
 type VerificationServiceImpl struct {
-	authRedisRepo redis.AuthenticationRepository
+	authRedisRepo  redis.AuthenticationRepository
+	LockoutService LockoutService
 }
 
 func NewVerificationService() VerificationService {
 	return &VerificationServiceImpl{
-		authRedisRepo: redis.NewAuthenticationRedisRepository(),
+		authRedisRepo:  redis.NewAuthenticationRedisRepository(),
+		LockoutService: NewLockoutService(),
 	}
 }
 
@@ -31,6 +33,20 @@ func (service *VerificationServiceImpl) VerifyEmail(email, verificationCode stri
 	}
 
 	if result != verificationCode {
+		exceeded, err := service.LockoutService.RegisterVerificationFailure("email", email)
+		if err != nil {
+			return err
+		}
+
+		// The code can't be guessed any further, a new one has to be requested
+		if exceeded {
+			if err := service.authRedisRepo.DelVerificationEmail(email); err != nil {
+				return fmt.Errorf("error deleting email verification code from redis: %v", err)
+			}
+
+			return errors.New("too many invalid attempts, please request a new verification code")
+		}
+
 		return errors.New("invalid verification code")
 	}
 
@@ -52,6 +68,20 @@ func (service *VerificationServiceImpl) VerifyPhone(phone string, verificationCo
 	}
 
 	if result != verificationCode {
+		exceeded, err := service.LockoutService.RegisterVerificationFailure("phone", phone)
+		if err != nil {
+			return err
+		}
+
+		// The code can't be guessed any further, a new one has to be requested
+		if exceeded {
+			if err := service.authRedisRepo.DelVerificationPhone(phone); err != nil {
+				return fmt.Errorf("error deleting phone verification code from redis: %v", err)
+			}
+
+			return errors.New("too many invalid attempts, please request a new verification code")
+		}
+
 		return errors.New("invalid verification code")
 	}
 
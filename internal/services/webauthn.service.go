@@ -0,0 +1,606 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mercan/ecommerce/internal/config"
+	"github.com/mercan/ecommerce/internal/helpers"
+	"github.com/mercan/ecommerce/internal/models"
+	"github.com/mercan/ecommerce/internal/repositories/mongodb"
+	"github.com/mercan/ecommerce/internal/repositories/redis"
+	"github.com/mercan/ecommerce/internal/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxWebAuthnCredentials    = 10
+	defaultWebAuthnCredential = "Passkey"
+)
+
+var (
+	ErrWebAuthnCredentialNotFound = errors.New("Passkey not found")
+	errInvalidWebAuthnChallenge   = errors.New("Invalid or expired challenge")
+	errInvalidWebAuthnCredential  = errors.New("Invalid passkey")
+)
+
+// WebAuthnService runs the WebAuthn ceremonies. Passkeys verify the user themselves, so they sign in
+// without a password or a second factor, and users with two-factor authentication enabled can use
+// them instead of a TOTP code after signing in with a password.
+type WebAuthnService interface {
+	BeginRegistration(userId primitive.ObjectID, user models.UserPasswordConfirmRequest) (*models.WebAuthnCreationOptions, error)
+	FinishRegistration(userId primitive.ObjectID, request models.WebAuthnRegistrationRequest, client models.ClientInfo) (*models.WebAuthnCredential, error)
+	ListCredentials(userId primitive.ObjectID) ([]models.WebAuthnCredential, error)
+	DeleteCredential(userId primitive.ObjectID, credentialId string, client models.ClientInfo) error
+	BeginLogin() (*models.WebAuthnRequestOptions, error)
+	FinishLogin(request models.WebAuthnLoginRequest, client models.ClientInfo) (*AuthTokens, error)
+	BeginSecondFactor(request models.WebAuthnSecondFactorOptionsRequest) (*models.WebAuthnRequestOptions, error)
+	FinishSecondFactor(request models.WebAuthnSecondFactorRequest, client models.ClientInfo) (*AuthTokens, error)
+}
+
+type WebAuthnServiceImpl struct {
+	userRepo       mongodb.UserMongoRepository
+	authRedisRepo  redis.AuthenticationRepository
+	webAuthnConfig config.WebAuthnConfig
+	TokenService   TokenService
+	LockoutService LockoutService
+	AuditService   AuditService
+}
+
+func NewWebAuthnService() WebAuthnService {
+	return &WebAuthnServiceImpl{
+		userRepo:       mongodb.NewUserMongoRepository(),
+		authRedisRepo:  redis.NewAuthenticationRedisRepository(),
+		webAuthnConfig: config.GetWebAuthnConfig(),
+		TokenService:   NewTokenService(),
+		LockoutService: NewLockoutService(),
+		AuditService:   NewAuditService(),
+	}
+}
+
+// BeginRegistration asks for a discoverable credential that verifies the user, so that it can be
+// used for passwordless logins. A passkey outlives the session it was added from, so the password
+// is confirmed first and FinishRegistration only accepts the challenge issued here.
+func (service *WebAuthnServiceImpl) BeginRegistration(userId primitive.ObjectID, user models.UserPasswordConfirmRequest) (*models.WebAuthnCreationOptions, error) {
+	if err := validators.ValidateStruct(user); err != nil {
+		return nil, err
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc == nil {
+		return nil, errors.New("User not found")
+	}
+
+	if result := helpers.VerifyPassword(userDoc.Password, user.Password); result != true {
+		return nil, errors.New("Invalid password")
+	}
+
+	if len(userDoc.WebAuthnCredentials) >= maxWebAuthnCredentials {
+		return nil, fmt.Errorf("You can't have more than %d passkeys", maxWebAuthnCredentials)
+	}
+
+	challenge, err := service.startCeremony(&models.WebAuthnSession{
+		Ceremony:         models.WebAuthnCeremonyRegistration,
+		UserID:           userId.Hex(),
+		UserVerification: "required",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	credentialParameters := make([]models.WebAuthnCredentialParameter, len(helpers.WebAuthnAlgorithms))
+	for i, alg := range helpers.WebAuthnAlgorithms {
+		credentialParameters[i] = models.WebAuthnCredentialParameter{Type: "public-key", Alg: alg}
+	}
+
+	displayName := strings.TrimSpace(userDoc.FirstName + " " + userDoc.LastName)
+	if displayName == "" {
+		displayName = userDoc.Email
+	}
+
+	return &models.WebAuthnCreationOptions{
+		Challenge: challenge,
+		RP: models.WebAuthnRelyingParty{
+			ID:   service.webAuthnConfig.RPID,
+			Name: service.webAuthnConfig.RPName,
+		},
+		// The user handle is stored by the authenticator, the id doesn't reveal anything about the user
+		User: models.WebAuthnUserEntity{
+			ID:          helpers.EncodeBase64URL(userId[:]),
+			Name:        userDoc.Email,
+			DisplayName: displayName,
+		},
+		PubKeyCredParams:   credentialParameters,
+		Timeout:            service.timeout(),
+		ExcludeCredentials: credentialDescriptors(userDoc.WebAuthnCredentials),
+		AuthenticatorSelection: models.WebAuthnAuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}, nil
+}
+
+func (service *WebAuthnServiceImpl) FinishRegistration(userId primitive.ObjectID, request models.WebAuthnRegistrationRequest, client models.ClientInfo) (credential *models.WebAuthnCredential, err error) {
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionWebAuthnRegister, userId, client, err))
+	}()
+
+	if err := validators.ValidateStruct(request); err != nil {
+		return nil, err
+	}
+
+	rawClientData, session, err := service.finishCeremony(request.Credential.Response.ClientDataJSON, "webauthn.create", models.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.UserID != userId.Hex() {
+		return nil, errInvalidWebAuthnChallenge
+	}
+
+	rawAttestationObject, err := helpers.DecodeBase64URL(request.Credential.Response.AttestationObject)
+	if err != nil {
+		return nil, errors.New("Invalid attestation object")
+	}
+
+	attestation, err := helpers.ParseAttestationObject(rawAttestationObject)
+	if err != nil {
+		return nil, err
+	}
+
+	authData := attestation.AuthData
+	if err := service.verifyAuthenticatorData(authData, session); err != nil {
+		return nil, err
+	}
+
+	if !authData.HasFlag(helpers.WebAuthnFlagAttestedCredentialData) {
+		return nil, errors.New("Invalid attestation object")
+	}
+
+	if credentialId, err := helpers.DecodeBase64URL(request.Credential.ID); err != nil || !bytes.Equal(credentialId, authData.CredentialID) {
+		return nil, errInvalidWebAuthnCredential
+	}
+
+	clientDataHash := sha256.Sum256(rawClientData)
+	if err := helpers.VerifyAttestation(attestation, clientDataHash[:]); err != nil {
+		return nil, err
+	}
+
+	if _, _, err := helpers.ParseCOSEKey(authData.CredentialPublicKey); err != nil {
+		return nil, err
+	}
+
+	name := request.Name
+	if name == "" {
+		name = defaultWebAuthnCredential
+	}
+
+	newCredential := models.WebAuthnCredential{
+		ID:             helpers.EncodeBase64URL(authData.CredentialID),
+		Name:           name,
+		PublicKey:      authData.CredentialPublicKey,
+		SignCount:      authData.SignCount,
+		AAGUID:         formatAAGUID(authData.AAGUID),
+		Transports:     request.Credential.Response.Transports,
+		BackupEligible: authData.HasFlag(helpers.WebAuthnFlagBackupEligible),
+		CreatedAt:      time.Now(),
+	}
+
+	added, err := service.userRepo.AddWebAuthnCredential(userId, newCredential, maxWebAuthnCredentials)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("Passkey already registered")
+		}
+
+		return nil, err
+	}
+
+	if !added {
+		return nil, fmt.Errorf("You can't have more than %d passkeys", maxWebAuthnCredentials)
+	}
+
+	return &newCredential, nil
+}
+
+func (service *WebAuthnServiceImpl) ListCredentials(userId primitive.ObjectID) ([]models.WebAuthnCredential, error) {
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc == nil {
+		return nil, errors.New("User not found")
+	}
+
+	if userDoc.WebAuthnCredentials == nil {
+		return []models.WebAuthnCredential{}, nil
+	}
+
+	return userDoc.WebAuthnCredentials, nil
+}
+
+func (service *WebAuthnServiceImpl) DeleteCredential(userId primitive.ObjectID, credentialId string, client models.ClientInfo) (err error) {
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionWebAuthnDelete, userId, client, err))
+	}()
+
+	credentialId, err = normalizeCredentialID(credentialId)
+	if err != nil {
+		return ErrWebAuthnCredentialNotFound
+	}
+
+	removed, err := service.userRepo.RemoveWebAuthnCredential(userId, credentialId)
+	if err != nil {
+		return err
+	}
+
+	if !removed {
+		return ErrWebAuthnCredentialNotFound
+	}
+
+	return nil
+}
+
+// BeginLogin starts a passwordless login, the authenticator offers the passkeys it has for the site
+func (service *WebAuthnServiceImpl) BeginLogin() (*models.WebAuthnRequestOptions, error) {
+	challenge, err := service.startCeremony(&models.WebAuthnSession{
+		Ceremony:         models.WebAuthnCeremonyLogin,
+		UserVerification: "required",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnRequestOptions{
+		Challenge:        challenge,
+		RPID:             service.webAuthnConfig.RPID,
+		Timeout:          service.timeout(),
+		AllowCredentials: []models.WebAuthnCredentialDescriptor{},
+		UserVerification: "required",
+	}, nil
+}
+
+func (service *WebAuthnServiceImpl) FinishLogin(request models.WebAuthnLoginRequest, client models.ClientInfo) (tokens *AuthTokens, err error) {
+	var userId primitive.ObjectID
+	defer func() {
+		service.AuditService.Record(newAuditEvent(models.AuditActionWebAuthnLogin, userId, client, err))
+	}()
+
+	if err := validators.ValidateStruct(request); err != nil {
+		return nil, err
+	}
+
+	rawClientData, session, err := service.finishCeremony(request.Credential.Response.ClientDataJSON, "webauthn.get", models.WebAuthnCeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialId, err := normalizeCredentialID(request.Credential.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	userDoc, err := service.userRepo.GetUserByWebAuthnCredentialID(credentialId)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc == nil {
+		return nil, errInvalidWebAuthnCredential
+	}
+	userId = userDoc.ID
+
+	// Discoverable credentials return the user handle they were registered with
+	if request.Credential.Response.UserHandle != "" {
+		userHandle, err := helpers.DecodeBase64URL(request.Credential.Response.UserHandle)
+		if err != nil || !bytes.Equal(userHandle, userDoc.ID[:]) {
+			return nil, errInvalidWebAuthnCredential
+		}
+	}
+
+	if err := service.verifyAssertion(userDoc, credentialId, request.Credential, rawClientData, session); err != nil {
+		return nil, err
+	}
+
+	return service.TokenService.IssueTokens(userDoc, client)
+}
+
+// BeginSecondFactor starts an assertion with a passkey of a user that signed in with a password
+func (service *WebAuthnServiceImpl) BeginSecondFactor(request models.WebAuthnSecondFactorOptionsRequest) (*models.WebAuthnRequestOptions, error) {
+	if err := validators.ValidateStruct(request); err != nil {
+		return nil, err
+	}
+
+	userDoc, err := service.getMFAPendingUser(request.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(userDoc.WebAuthnCredentials) == 0 {
+		return nil, errors.New("No passkeys registered")
+	}
+
+	// The password was the first factor, the authenticator only has to prove possession
+	challenge, err := service.startCeremony(&models.WebAuthnSession{
+		Ceremony:         models.WebAuthnCeremonySecondFactor,
+		UserID:           userDoc.ID.Hex(),
+		UserVerification: "discouraged",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnRequestOptions{
+		Challenge:        challenge,
+		RPID:             service.webAuthnConfig.RPID,
+		Timeout:          service.timeout(),
+		AllowCredentials: credentialDescriptors(userDoc.WebAuthnCredentials),
+		UserVerification: "discouraged",
+	}, nil
+}
+
+// FinishSecondFactor exchanges an "mfa pending" token and an assertion for a full token pair
+func (service *WebAuthnServiceImpl) FinishSecondFactor(request models.WebAuthnSecondFactorRequest, client models.ClientInfo) (tokens *AuthTokens, err error) {
+	var userId primitive.ObjectID
+	defer func() { service.AuditService.Record(newAuditEvent(models.AuditActionMFAVerify, userId, client, err)) }()
+
+	if err := validators.ValidateStruct(request); err != nil {
+		return nil, err
+	}
+
+	userDoc, err := service.getMFAPendingUser(request.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	userId = userDoc.ID
+
+	if err := service.LockoutService.CheckSecondFactor(userDoc.ID.Hex()); err != nil {
+		return nil, err
+	}
+
+	attempts, err := service.authRedisRepo.IncrMFAPendingAttempts(request.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if attempts > maxMFALoginAttempts {
+		if err := service.authRedisRepo.DelMFAPendingToken(request.MFAToken); err != nil {
+			log.Println("Error while deleting mfa pending token from redis: ", err.Error())
+		}
+
+		return nil, errors.New("Too many attempts, please log in again")
+	}
+
+	rawClientData, session, err := service.finishCeremony(request.Credential.Response.ClientDataJSON, "webauthn.get", models.WebAuthnCeremonySecondFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.UserID != userDoc.ID.Hex() {
+		return nil, errInvalidWebAuthnChallenge
+	}
+
+	credentialId, err := normalizeCredentialID(request.Credential.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.verifyAssertion(userDoc, credentialId, request.Credential, rawClientData, session); err != nil {
+		if lockoutErr := service.LockoutService.RegisterSecondFactorFailure(userDoc.ID.Hex()); lockoutErr != nil {
+			return nil, lockoutErr
+		}
+
+		return nil, err
+	}
+
+	if err := service.authRedisRepo.DelMFAPendingToken(request.MFAToken); err != nil {
+		log.Println("Error while deleting mfa pending token from redis: ", err.Error())
+	}
+
+	resetSignInFailures(service.LockoutService, userDoc)
+
+	return service.TokenService.IssueTokens(userDoc, client)
+}
+
+// getMFAPendingUser returns the user of an "mfa pending" token, which is only valid while two-factor
+// authentication is still enabled
+func (service *WebAuthnServiceImpl) getMFAPendingUser(mfaToken string) (*models.User, error) {
+	userIdHex, err := service.authRedisRepo.GetMFAPendingToken(mfaToken)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return nil, errors.New("Invalid or expired MFA token")
+		}
+
+		return nil, err
+	}
+
+	userId, err := primitive.ObjectIDFromHex(userIdHex)
+	if err != nil {
+		return nil, errors.New("Invalid or expired MFA token")
+	}
+
+	userDoc, err := service.userRepo.GetUserByID(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if userDoc == nil || !userDoc.TwoFactor.Enabled {
+		return nil, errors.New("Invalid or expired MFA token")
+	}
+
+	return userDoc, nil
+}
+
+// startCeremony stores the session of a ceremony under a new challenge and returns the challenge
+func (service *WebAuthnServiceImpl) startCeremony(session *models.WebAuthnSession) (string, error) {
+	challenge := helpers.GenerateWebAuthnChallenge()
+	if err := service.authRedisRepo.SetWebAuthnSession(challenge, session); err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+// finishCeremony verifies the client data of a response and consumes the session of its challenge.
+// It returns the raw client data, which is part of the signed data.
+func (service *WebAuthnServiceImpl) finishCeremony(clientDataJSON string, clientDataType string, ceremony string) ([]byte, *models.WebAuthnSession, error) {
+	rawClientData, err := helpers.DecodeBase64URL(clientDataJSON)
+	if err != nil {
+		return nil, nil, errors.New("Invalid client data")
+	}
+
+	var clientData helpers.WebAuthnClientData
+	if err := json.Unmarshal(rawClientData, &clientData); err != nil {
+		return nil, nil, errors.New("Invalid client data")
+	}
+
+	if clientData.Type != clientDataType || clientData.CrossOrigin {
+		return nil, nil, errors.New("Invalid client data")
+	}
+
+	// A phishing site can relay the challenge but the browser reports its own origin
+	if clientData.Origin != service.webAuthnConfig.Origin {
+		return nil, nil, errors.New("Invalid origin")
+	}
+
+	session, err := service.authRedisRepo.ConsumeWebAuthnSession(clientData.Challenge)
+	if err != nil {
+		if errors.Is(err, service.authRedisRepo.NilError()) {
+			return nil, nil, errInvalidWebAuthnChallenge
+		}
+
+		return nil, nil, err
+	}
+
+	if session.Ceremony != ceremony {
+		return nil, nil, errInvalidWebAuthnChallenge
+	}
+
+	return rawClientData, session, nil
+}
+
+// verifyAuthenticatorData checks that the authenticator data was made for this site and that the
+// user was present, and verified when the ceremony requires it
+func (service *WebAuthnServiceImpl) verifyAuthenticatorData(authData *helpers.AuthenticatorData, session *models.WebAuthnSession) error {
+	if !authData.MatchesRPID(service.webAuthnConfig.RPID) {
+		return errors.New("Invalid relying party")
+	}
+
+	if !authData.HasFlag(helpers.WebAuthnFlagUserPresent) {
+		return errors.New("User presence is required")
+	}
+
+	if session.UserVerification == "required" && !authData.HasFlag(helpers.WebAuthnFlagUserVerified) {
+		return errors.New("User verification is required")
+	}
+
+	return nil
+}
+
+// verifyAssertion verifies the signature of an assertion with a credential of the user and records
+// its sign counter. A counter that doesn't grow means the credential may have been cloned.
+func (service *WebAuthnServiceImpl) verifyAssertion(userDoc *models.User, credentialId string, assertion models.WebAuthnAssertionCredential,
+	rawClientData []byte, session *models.WebAuthnSession) error {
+	var credential *models.WebAuthnCredential
+	for i := range userDoc.WebAuthnCredentials {
+		if userDoc.WebAuthnCredentials[i].ID == credentialId {
+			credential = &userDoc.WebAuthnCredentials[i]
+			break
+		}
+	}
+
+	if credential == nil {
+		return errInvalidWebAuthnCredential
+	}
+
+	rawAuthData, err := helpers.DecodeBase64URL(assertion.Response.AuthenticatorData)
+	if err != nil {
+		return errInvalidWebAuthnCredential
+	}
+
+	authData, err := helpers.ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return err
+	}
+
+	if err := service.verifyAuthenticatorData(authData, session); err != nil {
+		return err
+	}
+
+	signature, err := helpers.DecodeBase64URL(assertion.Response.Signature)
+	if err != nil {
+		return errInvalidWebAuthnCredential
+	}
+
+	clientDataHash := sha256.Sum256(rawClientData)
+	signedData := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	if err := helpers.VerifyCOSESignature(credential.PublicKey, signedData, signature); err != nil {
+		return errInvalidWebAuthnCredential
+	}
+
+	// Authenticators that don't count always report zero
+	if (authData.SignCount != 0 || credential.SignCount != 0) && authData.SignCount <= credential.SignCount {
+		log.Printf("Sign counter of passkey %s of user %s did not grow, it may have been cloned", credential.ID, userDoc.ID.Hex())
+		return errInvalidWebAuthnCredential
+	}
+
+	updated, err := service.userRepo.UpdateWebAuthnSignCount(userDoc.ID, credential.ID, credential.SignCount, authData.SignCount)
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		return errInvalidWebAuthnCredential
+	}
+
+	return nil
+}
+
+func (service *WebAuthnServiceImpl) timeout() int64 {
+	return (config.GetTimeConfig().WebAuthnChallengeTime * time.Second).Milliseconds()
+}
+
+func credentialDescriptors(credentials []models.WebAuthnCredential) []models.WebAuthnCredentialDescriptor {
+	descriptors := make([]models.WebAuthnCredentialDescriptor, len(credentials))
+	for i, credential := range credentials {
+		descriptors[i] = models.WebAuthnCredentialDescriptor{
+			Type:       "public-key",
+			ID:         credential.ID,
+			Transports: credential.Transports,
+		}
+	}
+
+	return descriptors
+}
+
+// normalizeCredentialID re-encodes a credential id the way it is stored, without padding
+func normalizeCredentialID(credentialId string) (string, error) {
+	rawCredentialId, err := helpers.DecodeBase64URL(credentialId)
+	if err != nil || len(rawCredentialId) == 0 {
+		return "", errInvalidWebAuthnCredential
+	}
+
+	return helpers.EncodeBase64URL(rawCredentialId), nil
+}
+
+// formatAAGUID formats the authenticator model id as a UUID
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", aaguid[0:4], aaguid[4:6], aaguid[6:8], aaguid[8:10], aaguid[10:16])
+}
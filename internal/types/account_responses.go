@@ -0,0 +1,19 @@
+package types
+
+import "time"
+
+type AccountDeactivateResponse struct {
+	BaseResponse
+	Message string `json:"message,omitempty"`
+}
+
+type AccountDeleteResponse struct {
+	BaseResponse
+	Message             string    `json:"message,omitempty"`
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+type AccountExportResponse struct {
+	BaseResponse
+	Message string `json:"message,omitempty"`
+}
@@ -0,0 +1,20 @@
+package types
+
+import "time"
+
+type AdminChangeUserRoleResponse struct {
+	BaseResponse
+}
+
+// AdminImpersonateResponse carries an access token to act as another user. It has no refresh
+// token and expires quickly.
+type AdminImpersonateResponse struct {
+	BaseResponse
+	Token         string    `json:"token"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	Impersonating string    `json:"impersonating"`
+}
+
+type AdminEndImpersonationResponse struct {
+	BaseResponse
+}
@@ -0,0 +1,24 @@
+package types
+
+import "github.com/mercan/ecommerce/internal/models"
+
+type APIKeyCreateResponse struct {
+	BaseResponse
+	APIKey *models.APIKey `json:"api_key"`
+	// Key is the plain text key, it is only shown once
+	Key string `json:"key"`
+}
+
+type APIKeyResponse struct {
+	BaseResponse
+	APIKey *models.APIKey `json:"api_key"`
+}
+
+type APIKeysResponse struct {
+	BaseResponse
+	APIKeys []*models.APIKey `json:"api_keys"`
+}
+
+type APIKeyDeleteResponse struct {
+	BaseResponse
+}
@@ -0,0 +1,15 @@
+package types
+
+import "github.com/mercan/ecommerce/internal/models"
+
+type Pagination struct {
+	Page  int64 `json:"page"`
+	Limit int64 `json:"limit"`
+	Total int64 `json:"total"`
+}
+
+type AuditEventsResponse struct {
+	BaseResponse
+	Events     []*models.AuditEvent `json:"events"`
+	Pagination Pagination           `json:"pagination"`
+}
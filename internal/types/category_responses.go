@@ -0,0 +1,18 @@
+package types
+
+import "github.com/mercan/ecommerce/internal/models"
+
+type CategoryTreeResponse struct {
+	BaseResponse
+	Categories []*models.CategoryNode `json:"categories"`
+}
+
+type CategoryResponse struct {
+	BaseResponse
+	Category    *models.Category    `json:"category"`
+	Breadcrumbs []models.Breadcrumb `json:"breadcrumbs,omitempty"`
+}
+
+type CategoryDeleteResponse struct {
+	BaseResponse
+}
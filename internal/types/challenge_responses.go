@@ -0,0 +1,18 @@
+package types
+
+import "time"
+
+// Challenge tells the client how to solve a challenge, the solution is sent back in the
+// X-Challenge-Response header of the retried request
+type Challenge struct {
+	Provider   string     `json:"provider"`
+	SiteKey    string     `json:"site_key,omitempty"`
+	Nonce      string     `json:"nonce,omitempty"`
+	Difficulty int        `json:"difficulty,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+type ChallengeRequiredResponse struct {
+	BaseResponse
+	Challenge Challenge `json:"challenge"`
+}
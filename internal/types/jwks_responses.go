@@ -0,0 +1,9 @@
+package types
+
+import "github.com/mercan/ecommerce/internal/models"
+
+// JWKSResponse is a JWK Set (RFC 7517), it has no BaseResponse fields so that
+// standard JWT libraries can consume it
+type JWKSResponse struct {
+	Keys []models.JSONWebKey `json:"keys"`
+}
@@ -0,0 +1,12 @@
+package types
+
+import "github.com/mercan/ecommerce/internal/models"
+
+type MediaResponse struct {
+	BaseResponse
+	Media *models.Media `json:"media"`
+}
+
+type MediaDeleteResponse struct {
+	BaseResponse
+}
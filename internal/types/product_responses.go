@@ -0,0 +1,34 @@
+package types
+
+import "github.com/mercan/ecommerce/internal/models"
+
+type ProductResponse struct {
+	BaseResponse
+	Product *models.Product `json:"product"`
+}
+
+type ProductsResponse struct {
+	BaseResponse
+	Products   []*models.Product `json:"products"`
+	Pagination Pagination        `json:"pagination"`
+}
+
+type ProductDeleteResponse struct {
+	BaseResponse
+}
+
+type ProductVariantResponse struct {
+	BaseResponse
+	Variant *models.ProductVariant `json:"variant"`
+}
+
+type ProductVariantDeleteResponse struct {
+	BaseResponse
+}
+
+type ProductSearchResponse struct {
+	BaseResponse
+	Products   []*models.Product           `json:"products"`
+	NextCursor string                      `json:"next_cursor,omitempty"`
+	Facets     *models.ProductSearchFacets `json:"facets,omitempty"`
+}
@@ -1,18 +1,41 @@
 package types
 
+import (
+	"time"
+
+	"github.com/mercan/ecommerce/internal/models"
+)
+
 type BaseResponse struct {
 	Success bool   `json:"success"`
 	Error   string `json:"error,omitempty"`
 }
 
+// PasswordPolicyErrorResponse is returned when a new password does not meet the password policy
+type PasswordPolicyErrorResponse struct {
+	BaseResponse
+	Violations []models.PasswordPolicyViolation `json:"violations"`
+}
+
 type UserRegisterResponse struct {
 	BaseResponse
-	Token string `json:"token,omitempty"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
 type UserLoginResponse struct {
 	BaseResponse
-	Token string `json:"token,omitempty"`
+	Token        string   `json:"token,omitempty"`
+	RefreshToken string   `json:"refresh_token,omitempty"`
+	MFARequired  bool     `json:"mfa_required,omitempty"`
+	MFAToken     string   `json:"mfa_token,omitempty"`
+	MFAMethods   []string `json:"mfa_methods,omitempty"`
+}
+
+type UserRefreshResponse struct {
+	BaseResponse
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
 type UserLogoutResponse struct {
@@ -21,12 +44,33 @@ type UserLogoutResponse struct {
 
 type UserChangePasswordResponse struct {
 	BaseResponse
-	Token string `json:"token,omitempty"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
 type UserChangeEmailResponse struct {
 	BaseResponse
-	Token string `json:"token,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type UserVerifyEmailChangeResponse struct {
+	BaseResponse
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+type UserRevertEmailChangeResponse struct {
+	BaseResponse
+	Message string `json:"message,omitempty"`
+}
+
+type UserReportNewDeviceResponse struct {
+	BaseResponse
+	Message string `json:"message,omitempty"`
+}
+
+type UserChangePhoneResponse struct {
+	BaseResponse
 }
 
 type UserVerifyEmailResponse struct {
@@ -44,3 +88,61 @@ type UserVerifyPhoneResponse struct {
 type UserResendPhoneVerificationResponse struct {
 	BaseResponse
 }
+
+type UserForgotPasswordResponse struct {
+	BaseResponse
+	Message string `json:"message,omitempty"`
+}
+
+type UserPasswordlessResponse struct {
+	BaseResponse
+	Message string `json:"message,omitempty"`
+}
+
+type UserResetPasswordResponse struct {
+	BaseResponse
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+type UserSessionsResponse struct {
+	BaseResponse
+	Sessions []SessionResponse `json:"sessions"`
+}
+
+type UserRevokeSessionResponse struct {
+	BaseResponse
+}
+
+type UserTwoFactorSetupResponse struct {
+	BaseResponse
+	Secret string `json:"secret,omitempty"`
+	URI    string `json:"uri,omitempty"`
+}
+
+type UserTwoFactorRecoveryCodesResponse struct {
+	BaseResponse
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type UserTwoFactorDisableResponse struct {
+	BaseResponse
+}
+
+type UserOAuthAuthorizationResponse struct {
+	BaseResponse
+	AuthorizationURL string `json:"authorization_url,omitempty"`
+}
+
+type UserOAuthLinkResponse struct {
+	BaseResponse
+	Message string `json:"message,omitempty"`
+}
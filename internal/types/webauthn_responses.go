@@ -0,0 +1,29 @@
+package types
+
+import "github.com/mercan/ecommerce/internal/models"
+
+// WebAuthnCreationOptionsResponse carries the options to pass as "publicKey" to navigator.credentials.create
+type WebAuthnCreationOptionsResponse struct {
+	BaseResponse
+	Options *models.WebAuthnCreationOptions `json:"options"`
+}
+
+// WebAuthnRequestOptionsResponse carries the options to pass as "publicKey" to navigator.credentials.get
+type WebAuthnRequestOptionsResponse struct {
+	BaseResponse
+	Options *models.WebAuthnRequestOptions `json:"options"`
+}
+
+type WebAuthnCredentialResponse struct {
+	BaseResponse
+	Credential *models.WebAuthnCredential `json:"credential"`
+}
+
+type WebAuthnCredentialsResponse struct {
+	BaseResponse
+	Credentials []models.WebAuthnCredential `json:"credentials"`
+}
+
+type WebAuthnCredentialDeleteResponse struct {
+	BaseResponse
+}
@@ -3,13 +3,18 @@ package validators
 import (
 	"github.com/go-playground/validator/v10"
 	url2 "net/url"
+	"regexp"
 )
 
 var validate = validator.New()
 
+// e164Regex matches phone numbers in E.164 format, e.g. +905551234567
+var e164Regex = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
 func init() {
 	// Register custom validation
 	validate.RegisterValidation("customURL", customURLValidation)
+	validate.RegisterValidation("customPhone", customPhoneValidation)
 }
 
 func ValidateStruct(s interface{}) error {
@@ -38,3 +43,13 @@ func customURLValidation(fl validator.FieldLevel) bool {
 
 	return true
 }
+
+func customPhoneValidation(fl validator.FieldLevel) bool {
+	phone := fl.Field().String()
+
+	if phone == "" {
+		return true
+	}
+
+	return e164Regex.MatchString(phone)
+}